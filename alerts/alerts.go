@@ -0,0 +1,130 @@
+// Package alerts stores price alerts: an API key's request to be told when
+// the cheapest price at a zipcode drops below a target. Checking alerts
+// after each tracked-zipcode refresh and delivering them is not built yet;
+// see the Roadmap in the README.
+package alerts
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrAlertNotFound means the key has no alert with the given ID
+var ErrAlertNotFound = errors.New("alert not found")
+
+// Alert is one key's target price at one zipcode
+type Alert struct {
+	ID          string
+	KeyID       string // the API key that owns the alert
+	Zipcode     string
+	TargetPrice float64
+	CreatedAt   time.Time
+	FiredAt     *time.Time // when the alert last fired, nil if never
+}
+
+// NewAlert returns an alert for keyID with a new random ID
+func NewAlert(keyID, zipcode string, targetPrice float64) (*Alert, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	return &Alert{
+		ID:          hex.EncodeToString(id),
+		KeyID:       keyID,
+		Zipcode:     zipcode,
+		TargetPrice: targetPrice,
+		CreatedAt:   time.Now().UTC(),
+	}, nil
+}
+
+// Store persists alerts. Implementations are safe for concurrent use.
+type Store interface {
+	Create(ctx context.Context, alert *Alert) error
+	// List returns keyID's alerts, newest first
+	List(ctx context.Context, keyID string) ([]*Alert, error)
+	// ForZipcode returns every key's alerts on zipcode, oldest first, for
+	// checking after the zipcode's prices are refreshed
+	ForZipcode(ctx context.Context, zipcode string) ([]*Alert, error)
+	// MarkFired records that the alert fired at at, or returns
+	// ErrAlertNotFound
+	MarkFired(ctx context.Context, id string, at time.Time) error
+	// Delete removes keyID's alert, or returns ErrAlertNotFound if keyID
+	// has no alert with that ID
+	Delete(ctx context.Context, keyID, id string) error
+}
+
+// MemoryStore keeps alerts in memory, so they are lost on restart and each
+// replica has its own
+type MemoryStore struct {
+	mu     sync.RWMutex
+	alerts map[string]*Alert
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{alerts: map[string]*Alert{}}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, alert *Alert) error {
+	stored := *alert
+	s.mu.Lock()
+	s.alerts[alert.ID] = &stored
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, keyID string) ([]*Alert, error) {
+	alerts := s.filter(func(a *Alert) bool { return a.KeyID == keyID })
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].CreatedAt.After(alerts[j].CreatedAt) })
+	return alerts, nil
+}
+
+func (s *MemoryStore) ForZipcode(ctx context.Context, zipcode string) ([]*Alert, error) {
+	alerts := s.filter(func(a *Alert) bool { return a.Zipcode == zipcode })
+	sort.Slice(alerts, func(i, j int) bool { return alerts[i].CreatedAt.Before(alerts[j].CreatedAt) })
+	return alerts, nil
+}
+
+func (s *MemoryStore) MarkFired(ctx context.Context, id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, ok := s.alerts[id]
+	if !ok {
+		return ErrAlertNotFound
+	}
+	at = at.UTC()
+	alert.FiredAt = &at
+	return nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, keyID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alert, ok := s.alerts[id]
+	if !ok || alert.KeyID != keyID {
+		return ErrAlertNotFound
+	}
+	delete(s.alerts, id)
+	return nil
+}
+
+// filter returns copies of the alerts keep accepts
+func (s *MemoryStore) filter(keep func(*Alert) bool) []*Alert {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alerts := []*Alert{}
+	for _, alert := range s.alerts {
+		if keep(alert) {
+			a := *alert
+			alerts = append(alerts, &a)
+		}
+	}
+	return alerts
+}
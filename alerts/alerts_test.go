@@ -0,0 +1,112 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestPostgresStore(t *testing.T) {
+	testStore(t, NewPostgresStore(dbtest.Open(t)))
+}
+
+// newAlert returns an alert created age ago
+func newAlert(t *testing.T, keyID, zipcode string, target float64, age time.Duration) *Alert {
+	t.Helper()
+	alert, err := NewAlert(keyID, zipcode, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Postgres keeps microseconds
+	alert.CreatedAt = alert.CreatedAt.Add(-age).Truncate(time.Microsecond)
+	return alert
+}
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	older := newAlert(t, "key-a", "94102", 3.50, time.Hour)
+	newer := newAlert(t, "key-a", "10001", 4.00, 0)
+	other := newAlert(t, "key-b", "94102", 2.99, 30*time.Minute)
+	for _, alert := range []*Alert{older, newer, other} {
+		if err := store.Create(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	checkList(t, store, "key-a", newer.ID, older.ID)
+	checkList(t, store, "key-c")
+	checkForZipcode(t, store, "94102", older.ID, other.ID)
+
+	got, err := store.List(ctx, "key-b")
+	if err != nil || len(got) != 1 {
+		t.Fatalf("List(key-b) = %v, %v; want one alert", got, err)
+	}
+	if a := got[0]; a.Zipcode != "94102" || a.TargetPrice != 2.99 || !a.CreatedAt.Equal(other.CreatedAt) || a.FiredAt != nil {
+		t.Errorf("stored alert = %+v, want %+v", a, other)
+	}
+
+	firedAt := time.Now().Truncate(time.Microsecond)
+	if err := store.MarkFired(ctx, other.ID, firedAt); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = store.List(ctx, "key-b"); err != nil || len(got) != 1 {
+		t.Fatalf("List(key-b) = %v, %v; want one alert", got, err)
+	}
+	if got[0].FiredAt == nil || !got[0].FiredAt.Equal(firedAt) {
+		t.Errorf("FiredAt = %v, want %s", got[0].FiredAt, firedAt)
+	}
+	if err := store.MarkFired(ctx, "unknown", firedAt); !errors.Is(err, ErrAlertNotFound) {
+		t.Errorf("MarkFired of an unknown alert = %v, want ErrAlertNotFound", err)
+	}
+
+	// A key can only delete its own alerts
+	if err := store.Delete(ctx, "key-b", older.ID); !errors.Is(err, ErrAlertNotFound) {
+		t.Errorf("deleting another key's alert = %v, want ErrAlertNotFound", err)
+	}
+	if err := store.Delete(ctx, "key-a", older.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, "key-a", older.ID); !errors.Is(err, ErrAlertNotFound) {
+		t.Errorf("deleting twice = %v, want ErrAlertNotFound", err)
+	}
+	checkList(t, store, "key-a", newer.ID)
+	checkForZipcode(t, store, "94102", other.ID)
+}
+
+func checkList(t *testing.T, store Store, keyID string, want ...string) {
+	t.Helper()
+	alerts, err := store.List(context.Background(), keyID)
+	checkIDs(t, "List("+keyID+")", alerts, err, want)
+}
+
+func checkForZipcode(t *testing.T, store Store, zipcode string, want ...string) {
+	t.Helper()
+	alerts, err := store.ForZipcode(context.Background(), zipcode)
+	checkIDs(t, "ForZipcode("+zipcode+")", alerts, err, want)
+}
+
+func checkIDs(t *testing.T, name string, alerts []*Alert, err error, want []string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	got := []string{}
+	for _, alert := range alerts {
+		got = append(got, alert.ID)
+	}
+	if want == nil {
+		want = []string{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", name, got, want)
+	}
+}
@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps alerts in the price_alerts table, shared by every
+// replica. The pool is shared; close it separately.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const alertColumns = `id, key_id, zipcode, target_price, created_at, fired_at`
+
+func (s *PostgresStore) Create(ctx context.Context, alert *Alert) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO price_alerts (`+alertColumns+`) VALUES ($1, $2, $3, $4, $5, $6)`,
+		alert.ID, alert.KeyID, alert.Zipcode, alert.TargetPrice, alert.CreatedAt, alert.FiredAt)
+	if err != nil {
+		return fmt.Errorf("alerts: failed to store alert: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) List(ctx context.Context, keyID string) ([]*Alert, error) {
+	alerts, err := s.query(ctx, `SELECT `+alertColumns+` FROM price_alerts WHERE key_id = $1 ORDER BY created_at DESC`, keyID)
+	if err != nil {
+		return nil, fmt.Errorf("alerts: failed to list alerts: %w", err)
+	}
+	return alerts, nil
+}
+
+func (s *PostgresStore) ForZipcode(ctx context.Context, zipcode string) ([]*Alert, error) {
+	alerts, err := s.query(ctx, `SELECT `+alertColumns+` FROM price_alerts WHERE zipcode = $1 ORDER BY created_at`, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("alerts: failed to list alerts for %s: %w", zipcode, err)
+	}
+	return alerts, nil
+}
+
+func (s *PostgresStore) MarkFired(ctx context.Context, id string, at time.Time) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE price_alerts SET fired_at = $2 WHERE id = $1`, id, at.UTC())
+	if err != nil {
+		return fmt.Errorf("alerts: failed to mark alert fired: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlertNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) Delete(ctx context.Context, keyID, id string) error {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM price_alerts WHERE id = $1 AND key_id = $2`, id, keyID)
+	if err != nil {
+		return fmt.Errorf("alerts: failed to delete alert: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrAlertNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) query(ctx context.Context, sql string, args ...interface{}) ([]*Alert, error) {
+	rows, err := s.pool.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	alerts, err := pgx.CollectRows(rows, scanAlert)
+	if err != nil {
+		return nil, err
+	}
+	if alerts == nil {
+		alerts = []*Alert{}
+	}
+	return alerts, nil
+}
+
+func scanAlert(row pgx.CollectableRow) (*Alert, error) {
+	var alert Alert
+	if err := row.Scan(&alert.ID, &alert.KeyID, &alert.Zipcode, &alert.TargetPrice, &alert.CreatedAt, &alert.FiredAt); err != nil {
+		return nil, err
+	}
+	alert.CreatedAt = alert.CreatedAt.UTC()
+	if alert.FiredAt != nil {
+		firedAt := alert.FiredAt.UTC()
+		alert.FiredAt = &firedAt
+	}
+	return &alert, nil
+}
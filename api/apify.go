@@ -0,0 +1,138 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// ApifySource runs a retailer scraper actor on Apify and reads its dataset.
+// Actors are community-maintained, so the actor per retailer is configured
+// with APIFY_<RETAILER>_ACTOR (e.g. APIFY_WALGREENS_ACTOR=username~walgreens-scraper)
+// or the retailer's apifyActor config key.
+// Documentation: https://docs.apify.com/api/v2#/reference/actors/run-actor-synchronously-and-get-dataset-items
+type ApifySource struct {
+	token   *config.Secret
+	baseURL string
+	actors  map[string]string
+	client  *http.Client
+}
+
+// ApifyProductItem is one dataset item. Scraper actors disagree on field
+// names, so the common variants are all accepted.
+type ApifyProductItem struct {
+	Title        string  `json:"title"`
+	Name         string  `json:"name"`
+	Price        float64 `json:"price"`
+	RegularPrice float64 `json:"regularPrice"`
+	ListPrice    float64 `json:"listPrice"`
+	URL          string  `json:"url"`
+	SKU          string  `json:"sku"`
+	UPC          string  `json:"upc"`
+	InStock      *bool   `json:"inStock"`
+}
+
+// ApifyActorInput is the input passed to the scraper actor
+type ApifyActorInput struct {
+	Search   string `json:"search"`
+	Zipcode  string `json:"zipcode"`
+	MaxItems int    `json:"maxItems"`
+}
+
+// NewApifySource runs the actors given per lowercase retailer name
+func NewApifySource(token *config.Secret, actors map[string]string, client *http.Client) *ApifySource {
+	return &ApifySource{
+		token:   token,
+		baseURL: "https://api.apify.com/v2/acts",
+		actors:  actors,
+		client:  client,
+	}
+}
+
+func (s *ApifySource) Name() string { return "apify" }
+
+// FetchPrice runs the retailer's actor synchronously and returns the first priced item
+func (s *ApifySource) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	actor, ok := s.actors[strings.ToLower(retailer)]
+	if !ok {
+		return nil, fmt.Errorf("no actor configured for retailer %q", retailer)
+	}
+
+	input, err := json.Marshal(ApifyActorInput{Search: eggSearchQuery, Zipcode: zipcode, MaxItems: 5})
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("token", s.token.Get())
+	requestURL := fmt.Sprintf("%s/%s/run-sync-get-dataset-items?%s", s.baseURL, url.PathEscape(actor), params.Encode())
+
+	req, err := http.NewRequestWithContext(ctx, "POST", requestURL, bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, providerError("apify", ErrUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, providerError("apify", ErrUpstreamUnavailable, err)
+	}
+
+	// run-sync-get-dataset-items answers 201 Created once the run finishes
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, statusError("apify", resp.StatusCode, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	var items []ApifyProductItem
+	if err := json.Unmarshal(body, &items); err != nil {
+		return nil, providerError("apify", ErrUpstreamUnavailable, err)
+	}
+
+	for _, item := range items {
+		if item.Price <= 0 {
+			continue
+		}
+
+		name := item.Title
+		if name == "" {
+			name = item.Name
+		}
+		regular := item.RegularPrice
+		if regular == 0 {
+			regular = item.ListPrice
+		}
+		available := true
+		if item.InStock != nil {
+			available = *item.InStock
+		}
+
+		return &ThirdPartyPriceResponse{
+			ProductName:  name,
+			Price:        item.Price,
+			RegularPrice: regular,
+			OnSale:       regular > item.Price,
+			UPC:          item.UPC,
+			SKU:          item.SKU,
+			ProductURL:   item.URL,
+			Available:    available,
+			RequestID:    providerRequestID(resp.Header),
+			FetchedAt:    time.Now(),
+		}, nil
+	}
+
+	return nil, ErrNotFound
+}
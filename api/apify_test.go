@@ -0,0 +1,30 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+func TestApifyReplay(t *testing.T) {
+	cfg := config.Walgreens{PriceSources: []string{"apify"}, ApifyActor: "sample~walgreens-scraper"}
+	walgreens := NewWalgreensAPI(cfg, config.ThirdParty{ApifyKey: replayKey}, replayClient(t, "apify.yaml"), nil, nil)
+
+	price, err := walgreens.GetEggPrice(context.Background(), "94102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPrice(t, price, "Walgreens", parsedPrice{
+		Sku:         "prod6378461",
+		Upc:         "041220993758",
+		BasePrice:   4.79,
+		PromoPrice:  4.49,
+		FinalPrice:  4.49,
+		ProductName: "Walgreens Grade A Large White Eggs - 12 ct",
+		ProductURL:  "https://www.walgreens.com/store/c/walgreens-grade-a-large-white-eggs/ID=prod6378461",
+		InStock:     true,
+		PickupEta:   "Check store availability",
+		Source:      "apify",
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import "github.com/jkzilla/egg-price-compare/graph/model"
+
+// Quantity thresholds for stock bands. A dozen-egg SKU rarely has more than
+// a few dozen cartons on the shelf, so 20+ is treated as well stocked.
+const (
+	lowStockMax    = 5
+	mediumStockMax = 20
+)
+
+// stockLevelFromQuantity buckets an on-hand quantity into a StockLevel
+func stockLevelFromQuantity(quantity int) model.StockLevel {
+	switch {
+	case quantity <= 0:
+		return model.StockLevelOutOfStock
+	case quantity <= lowStockMax:
+		return model.StockLevelLow
+	case quantity <= mediumStockMax:
+		return model.StockLevelMedium
+	default:
+		return model.StockLevelHigh
+	}
+}
+
+// stockLevelFromWalmart maps the Walmart Affiliates "stock" string, which
+// reports availability but never a quantity
+func stockLevelFromWalmart(stock string) model.StockLevel {
+	switch stock {
+	case "Available":
+		return model.StockLevelUnknown
+	case "Limited Supply", "Last few items":
+		return model.StockLevelLow
+	default:
+		return model.StockLevelOutOfStock
+	}
+}
+
+// thirdPartyStockLevel maps the in-stock flag from a PriceSource, which
+// never reports quantity
+func thirdPartyStockLevel(available bool) model.StockLevel {
+	if available {
+		return model.StockLevelUnknown
+	}
+	return model.StockLevelOutOfStock
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+func TestStockLevelFromQuantity(t *testing.T) {
+	tests := []struct {
+		quantity int
+		want     model.StockLevel
+	}{
+		{-1, model.StockLevelOutOfStock},
+		{0, model.StockLevelOutOfStock},
+		{1, model.StockLevelLow},
+		{lowStockMax, model.StockLevelLow},
+		{lowStockMax + 1, model.StockLevelMedium},
+		{mediumStockMax, model.StockLevelMedium},
+		{mediumStockMax + 1, model.StockLevelHigh},
+	}
+	for _, tt := range tests {
+		if got := stockLevelFromQuantity(tt.quantity); got != tt.want {
+			t.Errorf("stockLevelFromQuantity(%d) = %s, want %s", tt.quantity, got, tt.want)
+		}
+	}
+}
+
+func TestStockLevelFromWalmart(t *testing.T) {
+	tests := []struct {
+		stock string
+		want  model.StockLevel
+	}{
+		{"Available", model.StockLevelUnknown},
+		{"Limited Supply", model.StockLevelLow},
+		{"Last few items", model.StockLevelLow},
+		{"Not available", model.StockLevelOutOfStock},
+		{"", model.StockLevelOutOfStock},
+	}
+	for _, tt := range tests {
+		if got := stockLevelFromWalmart(tt.stock); got != tt.want {
+			t.Errorf("stockLevelFromWalmart(%q) = %s, want %s", tt.stock, got, tt.want)
+		}
+	}
+}
+
+func TestThirdPartyStockLevel(t *testing.T) {
+	if got := thirdPartyStockLevel(true); got != model.StockLevelUnknown {
+		t.Errorf("thirdPartyStockLevel(true) = %s, want UNKNOWN", got)
+	}
+	if got := thirdPartyStockLevel(false); got != model.StockLevelOutOfStock {
+		t.Errorf("thirdPartyStockLevel(false) = %s, want OUT_OF_STOCK", got)
+	}
+}
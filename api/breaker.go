@@ -0,0 +1,117 @@
+package api
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// BreakerState is where a retailer's circuit breaker stands
+type BreakerState string
+
+const (
+	// BreakerClosed lets every call through
+	BreakerClosed BreakerState = "CLOSED"
+	// BreakerOpen fails calls without calling the provider
+	BreakerOpen BreakerState = "OPEN"
+	// BreakerHalfOpen lets one trial call through to see if the provider is back
+	BreakerHalfOpen BreakerState = "HALF_OPEN"
+)
+
+// outcome is what a call says about the provider's health
+type outcome int
+
+const (
+	// outcomeUnknown is a call that says nothing, such as one the caller
+	// gave up on
+	outcomeUnknown outcome = iota
+	outcomeHealthy
+	outcomeFailed
+)
+
+// callOutcome classifies err. Only unavailable and rate-limited providers
+// count as failures: not finding eggs or rejecting a zipcode is a working
+// provider answering.
+func callOutcome(err error) outcome {
+	switch {
+	case err == nil:
+		return outcomeHealthy
+	case errors.Is(err, ErrUpstreamUnavailable), errors.Is(err, ErrRateLimited):
+		return outcomeFailed
+	default:
+		return outcomeHealthy
+	}
+}
+
+// breaker trips after cfg.FailureThreshold consecutive failures and lets a
+// trial call through after cfg.Cooldown. It is not safe for concurrent use;
+// StatusRetailer guards it with its mutex.
+type breaker struct {
+	cfg config.Breaker
+
+	state    BreakerState
+	failures int
+	openedAt time.Time
+}
+
+func newBreaker(cfg config.Breaker) breaker {
+	return breaker{cfg: cfg, state: BreakerClosed}
+}
+
+// allow reports whether a call may go ahead, and whether it is the trial
+// call of a half-open breaker. Every other call waits for the trial.
+func (b *breaker) allow(now time.Time) (ok, trial bool) {
+	switch b.state {
+	case BreakerOpen:
+		if now.Sub(b.openedAt) < b.cfg.Cooldown {
+			return false, false
+		}
+		b.state = BreakerHalfOpen
+		return true, true
+	case BreakerHalfOpen:
+		return false, false
+	default:
+		return true, false
+	}
+}
+
+// record folds in the outcome of an allowed call
+func (b *breaker) record(o outcome, trial bool, now time.Time) {
+	if b.cfg.FailureThreshold <= 0 {
+		return
+	}
+
+	switch {
+	case trial:
+		switch o {
+		case outcomeHealthy:
+			b.state, b.failures = BreakerClosed, 0
+		case outcomeFailed:
+			b.state, b.openedAt = BreakerOpen, now
+		default:
+			// The trial proved nothing; the next call tries again
+			b.state = BreakerOpen
+		}
+	case b.state == BreakerClosed:
+		switch o {
+		case outcomeHealthy:
+			b.failures = 0
+		case outcomeFailed:
+			b.failures++
+			if b.failures >= b.cfg.FailureThreshold {
+				b.state, b.openedAt = BreakerOpen, now
+			}
+		}
+	}
+	// Other calls started before the breaker opened don't change it
+}
+
+// current is the state a status report shows: an open breaker whose
+// cooldown has passed lets the next call through
+func (b *breaker) current(now time.Time) BreakerState {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.cfg.Cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
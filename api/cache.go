@@ -0,0 +1,74 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// CacheRetailer returns r with its prices cached in prices for ttl. Mock
+// adapters, as reported by config, aren't cached: fixtures cost nothing and
+// depend on the request's mock scenario. A ttl of 0 returns r unchanged.
+func CacheRetailer(name string, r Retailer, config Configurable, prices *cache.Group, ttl time.Duration) Retailer {
+	if ttl <= 0 {
+		return r
+	}
+	return &cachedRetailer{name: name, next: r, config: config, prices: prices, ttl: ttl}
+}
+
+type cachedRetailer struct {
+	name   string
+	next   Retailer
+	config Configurable
+	prices *cache.Group
+	ttl    time.Duration
+}
+
+func (r *cachedRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	if r.config != nil && r.config.Mock() {
+		return r.next.GetEggPrice(ctx, zipcode)
+	}
+
+	var price *model.RetailerPrice
+	err := r.do(ctx, r.name+":price:"+zipcode, &price, func(ctx context.Context) (interface{}, error) {
+		return r.next.GetEggPrice(ctx, zipcode)
+	})
+	return price, err
+}
+
+func (r *cachedRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	if r.config != nil && r.config.Mock() {
+		return r.next.GetStorePrices(ctx, zipcode, radiusMiles)
+	}
+
+	key := r.name + ":stores:" + zipcode + ":" + strconv.FormatFloat(radiusMiles, 'f', -1, 64)
+	var prices []*model.RetailerPrice
+	err := r.do(ctx, key, &prices, func(ctx context.Context) (interface{}, error) {
+		return r.next.GetStorePrices(ctx, zipcode, radiusMiles)
+	})
+	return prices, err
+}
+
+// do loads key through the cache into out, which gets a copy of its own so
+// callers sharing a load can't see each other's changes
+func (r *cachedRetailer) do(ctx context.Context, key string, out interface{}, fetch func(context.Context) (interface{}, error)) error {
+	data, err := r.prices.Do(ctx, key, r.ttl, func(ctx context.Context) ([]byte, error) {
+		v, err := fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(v)
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: corrupt cached price: %w", r.name, err)
+	}
+	return nil
+}
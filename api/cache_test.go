@@ -0,0 +1,77 @@
+package api
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// fixedRetailer serves copies of price and counts the calls
+type fixedRetailer struct {
+	price *model.RetailerPrice
+	calls int
+}
+
+func (r *fixedRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	r.calls++
+	price := *r.price
+	return &price, nil
+}
+
+func (r *fixedRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	price, _ := r.GetEggPrice(ctx, zipcode)
+	return []*model.RetailerPrice{price}, nil
+}
+
+// Availability and provenance survive the JSON cache, so a cached price
+// reports its stock band, source and real age
+func TestCacheRetailerKeepsAvailabilityAndProvenance(t *testing.T) {
+	fetchedAt := time.Now().Add(-90 * time.Second)
+	price := &model.RetailerPrice{
+		Store:      "Walgreens",
+		StoreID:    strPtr("15196"),
+		Zipcode:    "94102",
+		BasePrice:  4.49,
+		FinalPrice: 4.49,
+		InStock:    true,
+		Availability: &model.Availability{
+			InStore:     true,
+			Pickup:      true,
+			PickupReady: true,
+			StockLevel:  model.StockLevelLow,
+			Quantity:    intPtr(3),
+		},
+	}
+	setProvenance(price, "searchapi", "req-123", fetchedAt)
+	next := &fixedRetailer{price: price}
+	r := CacheRetailer("walgreens", next, nil, cache.NewGroup("prices", cache.NewMemoryCache(), nil, time.Second), time.Minute)
+	ctx := context.Background()
+
+	r.GetEggPrice(ctx, "94102")
+	got, err := r.GetEggPrice(ctx, "94102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.calls != 1 {
+		t.Fatalf("retailer called %d times, want the second price from cache", next.calls)
+	}
+	if !reflect.DeepEqual(got, price) {
+		t.Errorf("cached price = %+v, want %+v", got, price)
+	}
+	if age := got.AgeSeconds(); age < 90 || age > 95 {
+		t.Errorf("cached price is %ds old, want the age since it was fetched", age)
+	}
+
+	stores, err := r.GetStorePrices(ctx, "94102", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stores, _ = r.GetStorePrices(ctx, "94102", 5)
+	if len(stores) != 1 || !reflect.DeepEqual(stores[0].Availability, price.Availability) || stores[0].Source != "searchapi" {
+		t.Errorf("cached store prices = %+v, want the store's availability and source", stores)
+	}
+}
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// Retailer is a price adapter for one retailer
+type Retailer interface {
+	GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error)
+	GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error)
+}
+
+// Chaos injects configured faults. A nil *Chaos injects nothing.
+type Chaos struct {
+	config config.Chaos
+
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewChaos builds a fault injector, or returns nil when cfg has no faults.
+// Set cfg.Seed for repeatable faults in tests.
+func NewChaos(cfg config.Chaos) *Chaos {
+	if !cfg.Enabled() {
+		return nil
+	}
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	providers := make(map[string]config.ChaosFault, len(cfg.Providers))
+	for name, fault := range cfg.Providers {
+		providers[strings.ToLower(name)] = fault
+	}
+	cfg.Providers = providers
+
+	return &Chaos{config: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+// WrapRetailer returns r with faults injected when chaos is configured for name
+func (c *Chaos) WrapRetailer(name string, r Retailer) Retailer {
+	if _, ok := c.fault(name); !ok {
+		return r
+	}
+	return &chaosRetailer{name: strings.ToLower(name), next: r, chaos: c}
+}
+
+// WrapPriceSource returns s with faults injected when chaos is configured for it
+func (c *Chaos) WrapPriceSource(s PriceSource) PriceSource {
+	if _, ok := c.fault(s.Name()); !ok {
+		return s
+	}
+	return &chaosPriceSource{next: s, chaos: c}
+}
+
+func (c *Chaos) fault(name string) (config.ChaosFault, bool) {
+	if c == nil {
+		return config.ChaosFault{}, false
+	}
+	fault, ok := c.config.Providers[strings.ToLower(name)]
+	return fault, ok
+}
+
+// inject waits out the latency fault, then returns an injected provider
+// error, or nil to let the call through. Callers prefix the provider name,
+// as the price source chain does.
+func (c *Chaos) inject(ctx context.Context, name string) error {
+	fault, ok := c.fault(name)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	delay := drawLatency(fault.Latency, c.rng)
+	roll := c.rng.Float64()
+	status := http.StatusInternalServerError
+	if len(fault.StatusCodes) > 0 {
+		status = fault.StatusCodes[c.rng.Intn(len(fault.StatusCodes))]
+	}
+	c.mu.Unlock()
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	switch {
+	case roll < fault.ErrorRate:
+		return statusError(name, status, fmt.Errorf("API returned status %d: %s (injected fault)", status, http.StatusText(status)))
+	case roll < fault.ErrorRate+fault.EmptyRate:
+		return providerError(name, ErrNotFound, fmt.Errorf("no products found (injected fault)"))
+	}
+	return nil
+}
+
+// malformedBody stands in for a response body cut off by a proxy or replaced
+// by its error page
+const malformedBody = "<html><body>Bad Gateway</body>"
+
+// Transport returns next with malformed response bodies injected for the
+// providers with a malformed rate. The adapters decode the corrupt body as
+// they would a real one. Requests to hosts that aren't a known provider pass
+// through untouched.
+func (c *Chaos) Transport(next http.RoundTripper) http.RoundTripper {
+	if c == nil {
+		return next
+	}
+	return &chaosTransport{next: next, chaos: c}
+}
+
+type chaosTransport struct {
+	next  http.RoundTripper
+	chaos *Chaos
+}
+
+func (t *chaosTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	fault, ok := t.chaos.fault(providerForRequest(req))
+	if !ok || fault.MalformedRate <= 0 {
+		return resp, nil
+	}
+
+	t.chaos.mu.Lock()
+	roll := t.chaos.rng.Float64()
+	t.chaos.mu.Unlock()
+	if roll >= fault.MalformedRate {
+		return resp, nil
+	}
+
+	resp.Body.Close()
+	resp.Body = io.NopCloser(strings.NewReader(malformedBody))
+	resp.ContentLength = int64(len(malformedBody))
+	resp.Header = resp.Header.Clone()
+	resp.Header.Del("Content-Length")
+	return resp, nil
+}
+
+func drawLatency(l config.ChaosLatency, rng *rand.Rand) time.Duration {
+	switch l.Distribution {
+	case "exponential":
+		d := l.Min + time.Duration(rng.ExpFloat64()*float64(l.Mean))
+		if l.Max > 0 && d > l.Max {
+			d = l.Max
+		}
+		return d
+	default:
+		if l.Max <= l.Min {
+			return l.Min
+		}
+		return l.Min + time.Duration(rng.Int63n(int64(l.Max-l.Min)))
+	}
+}
+
+type chaosRetailer struct {
+	name  string
+	next  Retailer
+	chaos *Chaos
+}
+
+func (r *chaosRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	if err := r.chaos.inject(ctx, r.name); err != nil {
+		return nil, fmt.Errorf("%s: %w", r.name, err)
+	}
+	return r.next.GetEggPrice(ctx, zipcode)
+}
+
+func (r *chaosRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	if err := r.chaos.inject(ctx, r.name); err != nil {
+		return nil, fmt.Errorf("%s: %w", r.name, err)
+	}
+	return r.next.GetStorePrices(ctx, zipcode, radiusMiles)
+}
+
+type chaosPriceSource struct {
+	next  PriceSource
+	chaos *Chaos
+}
+
+func (s *chaosPriceSource) Name() string { return s.next.Name() }
+
+func (s *chaosPriceSource) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	if err := s.chaos.inject(ctx, s.next.Name()); err != nil {
+		return nil, err
+	}
+	return s.next.FetchPrice(ctx, retailer, zipcode)
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/cassette"
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+func TestChaosMalformedBodyIsDecoded(t *testing.T) {
+	chaos := NewChaos(config.Chaos{Seed: 1, Providers: map[string]config.ChaosFault{
+		"Walmart": {MalformedRate: 1},
+	}})
+	recorder, err := cassette.New("testdata/cassettes/walmart-affiliates.yaml", cassette.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: chaos.Transport(recorder)}
+	walmart := NewWalmartAPI(config.Walmart{APIKey: replayKey}, config.ThirdParty{}, client, chaos, nil, nil)
+
+	_, err = walmart.GetEggPrice(context.Background(), "94102")
+	if !errors.Is(err, ErrUpstreamUnavailable) || !strings.Contains(err.Error(), "failed to parse response") {
+		t.Errorf("got %v, want the adapter's own parse error", err)
+	}
+}
+
+func TestChaosDisabled(t *testing.T) {
+	if chaos := NewChaos(config.Chaos{}); chaos != nil {
+		t.Fatal("NewChaos without providers should return nil")
+	}
+	var chaos *Chaos
+	if transport := chaos.Transport(http.DefaultTransport); transport != http.DefaultTransport {
+		t.Error("a nil *Chaos should leave the transport alone")
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Error kinds returned by the adapters. Match them with errors.Is; the
+// errors wrapping them carry upstream details meant for server logs only.
+var (
+	ErrUpstreamUnavailable = errors.New("upstream provider unavailable")
+	ErrRateLimited         = errors.New("upstream provider rate limited")
+	ErrNotFound            = errors.New("no products found")
+	ErrInvalidZipcode      = errors.New("invalid zipcode")
+	ErrAuthMisconfigured   = errors.New("upstream authentication misconfigured")
+	ErrInvalidInput        = errors.New("invalid input")
+)
+
+// ProviderError is a failed call to an upstream provider, classified by Kind
+type ProviderError struct {
+	Provider   string
+	Kind       error // one of the Err* kinds above
+	StatusCode int   // 0 when no HTTP response was received
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ProviderError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// InvalidZipcodeError reports a zipcode that is malformed or not in the geodatabase
+type InvalidZipcodeError struct {
+	Zipcode string
+	Reason  string
+}
+
+func (e *InvalidZipcodeError) Error() string {
+	return e.Reason
+}
+
+func (e *InvalidZipcodeError) Is(target error) bool {
+	return target == ErrInvalidZipcode
+}
+
+// InputError reports a request value the adapters can't act on, such as an
+// unknown X-Mock-Scenario. Reason is safe to show to clients.
+type InputError struct {
+	Field  string
+	Reason string
+}
+
+func (e *InputError) Error() string {
+	return e.Reason
+}
+
+func (e *InputError) Is(target error) bool {
+	return target == ErrInvalidInput
+}
+
+// providerError classifies err as kind
+func providerError(provider string, kind, err error) *ProviderError {
+	return &ProviderError{Provider: provider, Kind: kind, Err: err}
+}
+
+// statusError classifies a non-success HTTP response by its status code
+func statusError(provider string, statusCode int, err error) *ProviderError {
+	kind := ErrUpstreamUnavailable
+	switch statusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		kind = ErrAuthMisconfigured
+	case http.StatusTooManyRequests:
+		kind = ErrRateLimited
+	case http.StatusNotFound:
+		kind = ErrNotFound
+	}
+	return &ProviderError{Provider: provider, Kind: kind, StatusCode: statusCode, Err: err}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/cassette"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/logging"
+	"github.com/jkzilla/egg-price-compare/metrics"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+)
+
+// NewHTTPClient returns a client for upstream provider calls. Build one per
+// process and share it between adapters so a cassette (cfg) captures every
+// provider in a single file. secrets are scrubbed from recorded cassettes
+// by their value at the time of each call.
+// chaos corrupts response bodies when configured to.
+func NewHTTPClient(cfg config.Cassette, chaos *Chaos, secrets ...*config.Secret) *http.Client {
+	transport, err := cassette.Open(cfg, secrets...)
+	if err != nil {
+		// Fail every upstream call rather than silently going live
+		slog.Error("api: cassette unavailable", "error", err)
+		transport = failingTransport{err}
+	}
+	transport = metrics.Transport(chaos.Transport(transport), providerForRequest)
+
+	return &http.Client{Timeout: 15 * time.Second, Transport: otelhttp.NewTransport(logging.Transport(transport))}
+}
+
+// providerHosts maps upstream hosts to the provider names used in metrics
+var providerHosts = map[string]string{
+	"developer.api.walmart.com": "walmart",
+	"services.walgreens.com":    "walgreens",
+	"www.searchapi.io":          "searchapi",
+	"serpapi.com":               "serpapi",
+	"api.apify.com":             "apify",
+}
+
+func providerForRequest(req *http.Request) string {
+	if name, ok := providerHosts[req.URL.Hostname()]; ok {
+		return name
+	}
+	return "other"
+}
+
+// failingTransport rejects every request with a fixed error
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, t.err
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"gopkg.in/yaml.v3"
+)
+
+// MockScenarioHeader selects a mock scenario for a single request
+const MockScenarioHeader = "X-Mock-Scenario"
+
+// defaultMockScenario is used when neither the header nor mock.scenario is set
+const defaultMockScenario = "default"
+
+//go:embed fixtures/*.yaml
+var bundledFixtures embed.FS
+
+// MockScenario is a named set of canned retailer responses loaded from YAML
+type MockScenario struct {
+	Name        string                  `yaml:"name"`
+	Description string                  `yaml:"description"`
+	Retailers   map[string]MockRetailer `yaml:"retailers"`
+}
+
+// MockRetailer holds a retailer's product details, the fixture used for any
+// zipcode, and per-zipcode overrides
+type MockRetailer struct {
+	Product  MockProduct            `yaml:"product"`
+	Default  MockFixture            `yaml:"default"`
+	Zipcodes map[string]MockFixture `yaml:"zipcodes"`
+}
+
+type MockProduct struct {
+	Name    string `yaml:"name"`
+	SKU     string `yaml:"sku"`
+	UPC     string `yaml:"upc"`
+	URL     string `yaml:"url"`
+	StoreID string `yaml:"storeId"`
+}
+
+// MockFixture describes one canned response. Unset fields in a zipcode
+// override fall back to the retailer default.
+type MockFixture struct {
+	BasePrice   *float64      `yaml:"basePrice"`
+	PromoPrice  *float64      `yaml:"promoPrice"`
+	FinalPrice  *float64      `yaml:"finalPrice"` // computed from promo/base and offers when unset
+	InStock     *bool         `yaml:"inStock"`
+	Quantity    *int          `yaml:"quantity"`
+	PickupReady *bool         `yaml:"pickupReady"`
+	Delivery    *bool         `yaml:"delivery"`
+	PickupETA   string        `yaml:"pickupEta"`
+	Offers      []MockOffer   `yaml:"offers"`
+	Latency     time.Duration `yaml:"latency"`
+	Error       string        `yaml:"error"`
+	ErrorStatus int           `yaml:"errorStatus"` // HTTP status used to classify Error, default 503
+}
+
+type MockOffer struct {
+	OfferID         string   `yaml:"offerId"`
+	Description     string   `yaml:"description"`
+	DiscountAmount  *float64 `yaml:"discountAmount"`
+	DiscountPercent *float64 `yaml:"discountPercent"`
+	ExpiresInDays   int      `yaml:"expiresInDays"`
+	Clippable       bool     `yaml:"clippable"`
+}
+
+// MockProvider serves retailer prices from fixture scenarios
+type MockProvider struct {
+	scenarios map[string]*MockScenario
+	scenario  string // served when the request doesn't pick one
+}
+
+type mockScenarioKey struct{}
+
+// NewMockProvider loads the bundled scenarios and, if cfg.ScenariosDir is
+// set, every *.yaml file in it. A file in the directory replaces a bundled
+// scenario of the same name.
+func NewMockProvider(cfg config.Mock) (*MockProvider, error) {
+	p := &MockProvider{scenarios: map[string]*MockScenario{}, scenario: cfg.Scenario}
+	if p.scenario == "" {
+		p.scenario = defaultMockScenario
+	}
+
+	if err := p.loadFS(bundledFixtures, "fixtures"); err != nil {
+		return nil, err
+	}
+	if cfg.ScenariosDir != "" {
+		if err := p.loadFS(os.DirFS(cfg.ScenariosDir), "."); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, ok := p.scenarios[defaultMockScenario]; !ok {
+		return nil, fmt.Errorf("mock: no %q scenario defined", defaultMockScenario)
+	}
+	return p, nil
+}
+
+func (p *MockProvider) loadFS(fsys fs.FS, dir string) error {
+	paths, err := fs.Glob(fsys, path.Join(dir, "*.yaml"))
+	if err != nil {
+		return err
+	}
+
+	for _, file := range paths {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return fmt.Errorf("mock: failed to read %s: %w", file, err)
+		}
+
+		var scenario MockScenario
+		if err := yaml.Unmarshal(data, &scenario); err != nil {
+			return fmt.Errorf("mock: failed to parse %s: %w", file, err)
+		}
+		if scenario.Name == "" {
+			scenario.Name = strings.TrimSuffix(filepath.Base(file), ".yaml")
+		}
+		p.scenarios[scenario.Name] = &scenario
+	}
+	return nil
+}
+
+// Scenarios returns the names of all loaded scenarios
+func (p *MockProvider) Scenarios() []string {
+	names := make([]string, 0, len(p.scenarios))
+	for name := range p.scenarios {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithMockScenario selects the scenario used for mock responses in ctx
+func WithMockScenario(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, mockScenarioKey{}, name)
+}
+
+// MockScenarioMiddleware copies the X-Mock-Scenario header into the request context
+func MockScenarioMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if name := r.Header.Get(MockScenarioHeader); name != "" {
+			r = r.WithContext(WithMockScenario(r.Context(), name))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scenarioName picks the request header scenario, then the configured one
+func (p *MockProvider) scenarioName(ctx context.Context) string {
+	if name, ok := ctx.Value(mockScenarioKey{}).(string); ok && name != "" {
+		return name
+	}
+	return p.scenario
+}
+
+// GetEggPrice returns the fixture response for a retailer and zipcode,
+// waiting out any configured latency and returning any configured error
+func (p *MockProvider) GetEggPrice(ctx context.Context, retailer, zipcode string) (*model.RetailerPrice, error) {
+	if p == nil {
+		return nil, fmt.Errorf("mock: no fixtures configured for %s", retailer)
+	}
+	name := p.scenarioName(ctx)
+	scenario, ok := p.scenarios[name]
+	if !ok {
+		if requested, _ := ctx.Value(mockScenarioKey{}).(string); requested != name {
+			// A bad mock.scenario is the server's fault, not the client's
+			return nil, fmt.Errorf("mock: unknown scenario %q", name)
+		}
+		return nil, &InputError{
+			Field:  MockScenarioHeader,
+			Reason: fmt.Sprintf("unknown mock scenario %q, valid scenarios: %s", name, strings.Join(p.Scenarios(), ", ")),
+		}
+	}
+
+	key := strings.ToLower(retailer)
+	r, ok := scenario.Retailers[key]
+	if !ok {
+		// Scenarios only need to describe the retailers they change
+		r, ok = p.scenarios[defaultMockScenario].Retailers[key]
+		if !ok {
+			return nil, fmt.Errorf("mock: scenario %q has no %s fixture", name, retailer)
+		}
+	}
+
+	fixture := r.Default
+	if override, ok := r.Zipcodes[zipcode]; ok {
+		fixture = fixture.merge(override)
+	}
+
+	if fixture.Latency > 0 {
+		select {
+		case <-time.After(fixture.Latency):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	if fixture.Error != "" {
+		status := fixture.ErrorStatus
+		if status == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		return nil, statusError(key, status, fmt.Errorf("%s: %s", key, fixture.Error))
+	}
+
+	return fixture.toRetailerPrice(retailer, zipcode, r.Product), nil
+}
+
+// merge overlays the fields set in override onto f
+func (f MockFixture) merge(override MockFixture) MockFixture {
+	if override.BasePrice != nil {
+		f.BasePrice = override.BasePrice
+	}
+	if override.PromoPrice != nil {
+		f.PromoPrice = override.PromoPrice
+	}
+	if override.FinalPrice != nil {
+		f.FinalPrice = override.FinalPrice
+	}
+	if override.InStock != nil {
+		f.InStock = override.InStock
+	}
+	if override.Quantity != nil {
+		f.Quantity = override.Quantity
+	}
+	if override.PickupReady != nil {
+		f.PickupReady = override.PickupReady
+	}
+	if override.Delivery != nil {
+		f.Delivery = override.Delivery
+	}
+	if override.PickupETA != "" {
+		f.PickupETA = override.PickupETA
+	}
+	if override.Offers != nil {
+		f.Offers = override.Offers
+	}
+	if override.Latency != 0 {
+		f.Latency = override.Latency
+	}
+	if override.Error != "" {
+		f.Error = override.Error
+	}
+	if override.ErrorStatus != 0 {
+		f.ErrorStatus = override.ErrorStatus
+	}
+	return f
+}
+
+func (f MockFixture) toRetailerPrice(retailer, zipcode string, product MockProduct) *model.RetailerPrice {
+	basePrice := 0.0
+	if f.BasePrice != nil {
+		basePrice = *f.BasePrice
+	}
+
+	// promoPrice: 0 in a zipcode override clears the default promo
+	var promoPrice *float64
+	finalPrice := basePrice
+	if f.PromoPrice != nil && *f.PromoPrice > 0 {
+		promoPrice = f.PromoPrice
+		finalPrice = *f.PromoPrice
+	}
+
+	var offers []*model.DigitalOffer
+	for _, o := range f.Offers {
+		offer := &model.DigitalOffer{
+			OfferID:         o.OfferID,
+			Description:     o.Description,
+			DiscountAmount:  o.DiscountAmount,
+			DiscountPercent: o.DiscountPercent,
+			Clippable:       o.Clippable,
+		}
+		if o.ExpiresInDays > 0 {
+			offer.ExpiresAt = strPtr(time.Now().AddDate(0, 0, o.ExpiresInDays).Format(time.RFC3339))
+		}
+		offers = append(offers, offer)
+
+		if o.DiscountAmount != nil {
+			finalPrice -= *o.DiscountAmount
+		}
+		if o.DiscountPercent != nil {
+			finalPrice *= 1 - *o.DiscountPercent/100
+		}
+	}
+	if f.FinalPrice != nil {
+		finalPrice = *f.FinalPrice
+	}
+
+	inStock := f.InStock == nil || *f.InStock
+	availability := &model.Availability{
+		InStore:     inStock,
+		Pickup:      inStock,
+		PickupReady: inStock && (f.PickupReady == nil || *f.PickupReady),
+		Delivery:    inStock && f.Delivery != nil && *f.Delivery,
+		StockLevel:  model.StockLevelUnknown,
+	}
+	switch {
+	case !inStock:
+		availability.StockLevel = model.StockLevelOutOfStock
+	case f.Quantity != nil:
+		availability.StockLevel = stockLevelFromQuantity(*f.Quantity)
+		availability.Quantity = intPtr(*f.Quantity)
+	}
+
+	pickupEta := f.PickupETA
+	if pickupEta == "" {
+		pickupEta = "Available today"
+		if !inStock {
+			pickupEta = "Out of stock"
+		}
+	}
+
+	price := &model.RetailerPrice{
+		Store:         retailer,
+		Zipcode:       zipcode,
+		BasePrice:     basePrice,
+		PromoPrice:    promoPrice,
+		FinalPrice:    roundCents(finalPrice),
+		ProductName:   product.Name,
+		InStock:       inStock,
+		PickupEta:     strPtr(pickupEta),
+		DigitalOffers: offers,
+		Availability:  availability,
+		LastUpdated:   time.Now().Format(time.RFC3339),
+	}
+	if product.SKU != "" {
+		price.Sku = strPtr(product.SKU)
+	}
+	if product.UPC != "" {
+		price.Upc = strPtr(product.UPC)
+	}
+	if product.URL != "" {
+		price.ProductURL = strPtr(product.URL)
+	}
+	if product.StoreID != "" {
+		price.StoreID = strPtr(product.StoreID)
+	}
+	setProvenance(price, SourceMock, "", time.Now())
+	return price
+}
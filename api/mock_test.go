@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+func TestMockUnknownScenario(t *testing.T) {
+	p, err := NewMockProvider(config.Mock{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithMockScenario(context.Background(), "no-such-scenario")
+	_, err = p.GetEggPrice(ctx, "Walmart", "94102")
+	var inputErr *InputError
+	if !errors.As(err, &inputErr) || !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("got %v, want an InputError", err)
+	}
+	if inputErr.Field != MockScenarioHeader {
+		t.Errorf("field = %q, want %q", inputErr.Field, MockScenarioHeader)
+	}
+	for _, name := range p.Scenarios() {
+		if !strings.Contains(inputErr.Reason, name) {
+			t.Errorf("reason %q doesn't list scenario %q", inputErr.Reason, name)
+		}
+	}
+}
+
+func TestMockUnknownConfiguredScenario(t *testing.T) {
+	p, err := NewMockProvider(config.Mock{Scenario: "no-such-scenario"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = p.GetEggPrice(context.Background(), "Walmart", "94102")
+	if err == nil || errors.Is(err, ErrInvalidInput) {
+		t.Errorf("got %v, want a server error for a bad mock.scenario", err)
+	}
+}
+
+// A scenario in mock.scenariosDir is served when mock.scenario names it;
+// retailers it doesn't describe come from the default scenario
+func TestMockScenariosDir(t *testing.T) {
+	dir := t.TempDir()
+	data := "name: flash-sale\nretailers:\n  walmart:\n    default:\n      basePrice: 1.23\n"
+	if err := os.WriteFile(filepath.Join(dir, "flash-sale.yaml"), []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewMockProvider(config.Mock{ScenariosDir: dir, Scenario: "flash-sale"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	walmart, err := p.GetEggPrice(context.Background(), "Walmart", "94102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if walmart.FinalPrice != 1.23 {
+		t.Errorf("walmart price = %v, want 1.23 from flash-sale", walmart.FinalPrice)
+	}
+	if _, err := p.GetEggPrice(context.Background(), "Walgreens", "94102"); err != nil {
+		t.Errorf("walgreens: %v, want the default fixture", err)
+	}
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// PriceSource is a third-party provider that can look up retail egg pricing
+// for a retailer near a zipcode (SearchAPI, SerpApi, Apify, etc.)
+type PriceSource interface {
+	Name() string
+	FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error)
+}
+
+// PriceSourceChain tries each source in order and returns the first result.
+// A source that errors or finds no products falls through to the next one.
+type PriceSourceChain []PriceSource
+
+// eggSearchQuery is the product search sent to every provider
+const eggSearchQuery = "eggs dozen large white"
+
+// NewPriceSourceChain builds a retailer's chain from the source names in
+// its config (e.g. walgreens.priceSources: [serpapi, searchapi]).
+// Sources without credentials are left out, so an empty chain means no
+// third-party pricing is available; keys that are set can be rotated later.
+// apifyActor is the retailer's Apify actor.
+func NewPriceSourceChain(retailer string, names []string, keys config.ThirdParty, apifyActor string, client *http.Client, chaos *Chaos) PriceSourceChain {
+	var chain PriceSourceChain
+	for _, name := range names {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "searchapi":
+			if keys.SearchAPIKey.Configured() {
+				chain = append(chain, NewSearchAPISource(keys.SearchAPIKey, client))
+			}
+		case "serpapi":
+			if keys.SerpAPIKey.Configured() {
+				chain = append(chain, NewSerpAPISource(keys.SerpAPIKey, client))
+			}
+		case "apify":
+			if keys.ApifyKey.Configured() {
+				actors := map[string]string{}
+				if apifyActor != "" {
+					actors[strings.ToLower(retailer)] = apifyActor
+				}
+				chain = append(chain, NewApifySource(keys.ApifyKey, actors, client))
+			}
+		}
+	}
+
+	for i, source := range chain {
+		chain[i] = TracePriceSource(chaos.WrapPriceSource(source))
+	}
+	return chain
+}
+
+// FetchPrice returns the first successful result in chain order, or all
+// source errors joined together if every source fails
+func (c PriceSourceChain) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	if len(c) == 0 {
+		return nil, providerError("", ErrAuthMisconfigured, fmt.Errorf("third-party API key not configured"))
+	}
+
+	var errs []error
+	for _, source := range c {
+		price, err := source.FetchPrice(ctx, retailer, zipcode)
+		if err == nil {
+			price.Source = source.Name()
+			if price.FetchedAt.IsZero() {
+				price.FetchedAt = time.Now()
+			}
+			return price, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", source.Name(), err))
+
+		// Don't keep burning provider credits after the caller gave up
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, errors.Join(errs...)
+}
+
+// getJSON issues a GET and decodes a JSON response body into out, returning
+// the provider's request ID header if any. Failures are classified as
+// provider errors.
+func getJSON(ctx context.Context, client *http.Client, provider, requestURL string, out interface{}) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", providerError(provider, ErrUpstreamUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", providerError(provider, ErrUpstreamUnavailable, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError(provider, resp.StatusCode, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body)))
+	}
+
+	if err := json.Unmarshal(body, out); err != nil {
+		return "", providerError(provider, ErrUpstreamUnavailable, err)
+	}
+	return providerRequestID(resp.Header), nil
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// Sources for prices that don't come from a third-party price source, which
+// report their PriceSource name instead
+const (
+	SourceWalmartAffiliates = "walmart-affiliates"
+	SourceMock              = "mock"
+)
+
+// requestIDHeaders are response headers providers use for their request or
+// correlation ID, in order of preference
+var requestIDHeaders = []string{"X-Request-Id", "X-Correlation-Id", "WM_QOS.CORRELATION_ID", "X-Amzn-Requestid"}
+
+// providerRequestID returns the upstream request ID from response headers,
+// or "" if the provider didn't send one
+func providerRequestID(header http.Header) string {
+	for _, name := range requestIDHeaders {
+		if id := header.Get(name); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// setProvenance records where a price came from and when it was fetched
+func setProvenance(price *model.RetailerPrice, source, requestID string, fetchedAt time.Time) {
+	price.Source = source
+	price.FetchedAt = fetchedAt.UTC().Format(time.RFC3339)
+	price.IsMock = source == SourceMock
+	price.ProviderRequestID = nil
+	if requestID != "" {
+		price.ProviderRequestID = strPtr(requestID)
+	}
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/cassette"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/geo"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// replayKey enables an adapter's live code path; cassettes store every
+// credential as cassette.Redacted, so any value matches
+var replayKey = config.NewSecret("replay-key")
+
+// replayClient serves requests from the named cassette in testdata/cassettes
+// and fails the test if any recorded request is never made
+func replayClient(t *testing.T, name string) *http.Client {
+	t.Helper()
+	recorder, err := cassette.New("testdata/cassettes/"+name, cassette.ModeReplay, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, req := range recorder.Unplayed() {
+			t.Errorf("%s: %s %s was never requested", name, req.Method, req.URL)
+		}
+	})
+	return &http.Client{Transport: recorder}
+}
+
+// zipcodeDB returns the embedded geodatabase store distances are measured with
+func zipcodeDB(t *testing.T) *geo.ZipcodeDB {
+	t.Helper()
+	db, err := geo.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// parsedPrice is the part of a RetailerPrice an adapter parses from the
+// provider response, with nil pointers as zero values
+type parsedPrice struct {
+	Sku, Upc, StoreID       string
+	BasePrice, PromoPrice   float64
+	FinalPrice              float64
+	ProductName, ProductURL string
+	InStock                 bool
+	PickupEta               string
+	Offers                  []string
+	Source, RequestID       string
+}
+
+func parsed(p *model.RetailerPrice) parsedPrice {
+	deref := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	got := parsedPrice{
+		Sku:         deref(p.Sku),
+		Upc:         deref(p.Upc),
+		StoreID:     deref(p.StoreID),
+		BasePrice:   p.BasePrice,
+		FinalPrice:  p.FinalPrice,
+		ProductName: p.ProductName,
+		ProductURL:  deref(p.ProductURL),
+		InStock:     p.InStock,
+		PickupEta:   deref(p.PickupEta),
+		Source:      p.Source,
+		RequestID:   deref(p.ProviderRequestID),
+	}
+	if p.PromoPrice != nil {
+		got.PromoPrice = *p.PromoPrice
+	}
+	for _, o := range p.DigitalOffers {
+		got.Offers = append(got.Offers, o.OfferID)
+	}
+	return got
+}
+
+// checkPrice compares the parsed fields of got with want and checks the
+// provenance every live price must carry
+func checkPrice(t *testing.T, got *model.RetailerPrice, store string, want parsedPrice) {
+	t.Helper()
+	if got.Store != store || got.Zipcode != "94102" || got.IsMock || got.FetchedAt == "" {
+		t.Errorf("got store %q, zipcode %q, isMock %t, fetchedAt %q; want a live %s price for 94102",
+			got.Store, got.Zipcode, got.IsMock, got.FetchedAt, store)
+	}
+	if g := parsed(got); !reflect.DeepEqual(g, want) {
+		t.Errorf("parsed %s price:\n got  %+v\n want %+v", store, g, want)
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// searchAPIEngines maps a retailer to its SearchAPI engine
+// Documentation: https://www.searchapi.io/docs/walgreens, https://www.searchapi.io/docs/walmart-search
+var searchAPIEngines = map[string]string{
+	"walgreens": "walgreens",
+	"walmart":   "walmart_search",
+}
+
+// SearchAPISource looks up prices through SearchAPI's retailer search engines
+type SearchAPISource struct {
+	apiKey  *config.Secret
+	baseURL string
+	client  *http.Client
+}
+
+// SearchAPIResult is one entry of SearchAPI's organic_results
+type SearchAPIResult struct {
+	ProductID              string  `json:"product_id"`
+	Title                  string  `json:"title"`
+	Link                   string  `json:"link"`
+	UPC                    string  `json:"upc"`
+	ExtractedPrice         float64 `json:"extracted_price"`
+	ExtractedOriginalPrice float64 `json:"extracted_original_price"`
+	InStock                *bool   `json:"in_stock"`
+}
+
+// SearchAPIResponse represents a SearchAPI retailer search response
+type SearchAPIResponse struct {
+	SearchMetadata struct {
+		ID string `json:"id"`
+	} `json:"search_metadata"`
+	OrganicResults []SearchAPIResult `json:"organic_results"`
+}
+
+func NewSearchAPISource(apiKey *config.Secret, client *http.Client) *SearchAPISource {
+	return &SearchAPISource{
+		apiKey:  apiKey,
+		baseURL: "https://www.searchapi.io/api/v1/search",
+		client:  client,
+	}
+}
+
+func (s *SearchAPISource) Name() string { return "searchapi" }
+
+// FetchPrice searches the retailer's catalog and returns the first priced result
+func (s *SearchAPISource) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	engine, ok := searchAPIEngines[strings.ToLower(retailer)]
+	if !ok {
+		return nil, fmt.Errorf("retailer %q not supported", retailer)
+	}
+
+	params := url.Values{}
+	params.Add("engine", engine)
+	params.Add("q", eggSearchQuery)
+	params.Add("api_key", s.apiKey.Get())
+	params.Add("location", zipcode)
+
+	var result SearchAPIResponse
+	requestID, err := getJSON(ctx, s.client, "searchapi", fmt.Sprintf("%s?%s", s.baseURL, params.Encode()), &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.SearchMetadata.ID != "" {
+		requestID = result.SearchMetadata.ID
+	}
+
+	for _, r := range result.OrganicResults {
+		if r.ExtractedPrice <= 0 {
+			continue
+		}
+
+		available := true
+		if r.InStock != nil {
+			available = *r.InStock
+		}
+
+		return &ThirdPartyPriceResponse{
+			ProductName:  r.Title,
+			Price:        r.ExtractedPrice,
+			RegularPrice: r.ExtractedOriginalPrice,
+			OnSale:       r.ExtractedOriginalPrice > r.ExtractedPrice,
+			UPC:          r.UPC,
+			SKU:          r.ProductID,
+			ProductURL:   r.Link,
+			Available:    available,
+			RequestID:    requestID,
+			FetchedAt:    time.Now(),
+		}, nil
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// SerpAPISource looks up prices through SerpApi. Walmart has a dedicated
+// engine; Walgreens is found via Google Shopping results sold by Walgreens.
+// Documentation: https://serpapi.com/walmart-search-api, https://serpapi.com/google-shopping-api
+type SerpAPISource struct {
+	apiKey  *config.Secret
+	baseURL string
+	client  *http.Client
+}
+
+// SerpAPIWalmartResult is one entry of the walmart engine's organic_results
+type SerpAPIWalmartResult struct {
+	UsItemID       string `json:"us_item_id"`
+	Title          string `json:"title"`
+	ProductPageURL string `json:"product_page_url"`
+	OutOfStock     bool   `json:"out_of_stock"`
+	PrimaryOffer   struct {
+		OfferPrice float64 `json:"offer_price"`
+		WasPrice   float64 `json:"was_price"`
+	} `json:"primary_offer"`
+}
+
+// SerpAPIShoppingResult is one entry of the google_shopping engine's shopping_results
+type SerpAPIShoppingResult struct {
+	ProductID         string  `json:"product_id"`
+	Title             string  `json:"title"`
+	Source            string  `json:"source"`
+	ProductLink       string  `json:"product_link"`
+	ExtractedPrice    float64 `json:"extracted_price"`
+	ExtractedOldPrice float64 `json:"extracted_old_price"`
+}
+
+// SerpAPIResponse holds the result lists used by the supported engines
+type SerpAPIResponse struct {
+	SearchMetadata struct {
+		ID string `json:"id"`
+	} `json:"search_metadata"`
+	OrganicResults  []SerpAPIWalmartResult  `json:"organic_results"`
+	ShoppingResults []SerpAPIShoppingResult `json:"shopping_results"`
+}
+
+func NewSerpAPISource(apiKey *config.Secret, client *http.Client) *SerpAPISource {
+	return &SerpAPISource{
+		apiKey:  apiKey,
+		baseURL: "https://serpapi.com/search.json",
+		client:  client,
+	}
+}
+
+func (s *SerpAPISource) Name() string { return "serpapi" }
+
+// FetchPrice searches the retailer and returns the first priced result
+func (s *SerpAPISource) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	params := url.Values{}
+	params.Add("api_key", s.apiKey.Get())
+
+	switch strings.ToLower(retailer) {
+	case "walmart":
+		params.Add("engine", "walmart")
+		params.Add("query", eggSearchQuery)
+	case "walgreens":
+		params.Add("engine", "google_shopping")
+		params.Add("q", "walgreens "+eggSearchQuery)
+		params.Add("location", zipcode)
+	default:
+		return nil, fmt.Errorf("retailer %q not supported", retailer)
+	}
+
+	var result SerpAPIResponse
+	requestID, err := getJSON(ctx, s.client, "serpapi", fmt.Sprintf("%s?%s", s.baseURL, params.Encode()), &result)
+	if err != nil {
+		return nil, err
+	}
+	if result.SearchMetadata.ID != "" {
+		requestID = result.SearchMetadata.ID
+	}
+	fetchedAt := time.Now()
+
+	for _, r := range result.OrganicResults {
+		if r.PrimaryOffer.OfferPrice <= 0 {
+			continue
+		}
+		return &ThirdPartyPriceResponse{
+			ProductName:  r.Title,
+			Price:        r.PrimaryOffer.OfferPrice,
+			RegularPrice: r.PrimaryOffer.WasPrice,
+			OnSale:       r.PrimaryOffer.WasPrice > r.PrimaryOffer.OfferPrice,
+			SKU:          r.UsItemID,
+			ProductURL:   r.ProductPageURL,
+			Available:    !r.OutOfStock,
+			RequestID:    requestID,
+			FetchedAt:    fetchedAt,
+		}, nil
+	}
+
+	for _, r := range result.ShoppingResults {
+		// Google Shopping mixes sellers; only Walgreens' own listing is 1P pricing
+		if r.ExtractedPrice <= 0 || !soldBy(r.Source, retailer) {
+			continue
+		}
+		return &ThirdPartyPriceResponse{
+			ProductName:  r.Title,
+			Price:        r.ExtractedPrice,
+			RegularPrice: r.ExtractedOldPrice,
+			OnSale:       r.ExtractedOldPrice > r.ExtractedPrice,
+			SKU:          r.ProductID,
+			ProductURL:   r.ProductLink,
+			Available:    true,
+			RequestID:    requestID,
+			FetchedAt:    fetchedAt,
+		}, nil
+	}
+
+	return nil, ErrNotFound
+}
+
+// soldBy reports whether a Google Shopping source names retailer. Sources
+// vary by listing ("Walgreens", "Walgreens.com", "Walmart - Store"), so the
+// retailer only has to start the source as a whole word.
+func soldBy(source, retailer string) bool {
+	source, retailer = strings.ToLower(strings.TrimSpace(source)), strings.ToLower(retailer)
+	if retailer == "" || !strings.HasPrefix(source, retailer) {
+		return false
+	}
+	rest := source[len(retailer):]
+	return rest == "" || !unicode.IsLetter(rune(rest[0]))
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// The cassette has SerpApi's Walmart engine answer for Walmart, and Google
+// Shopping stand in for SearchAPI's failing Walgreens engine
+func TestSerpAPIReplay(t *testing.T) {
+	client := replayClient(t, "serpapi-fallback.yaml")
+	keys := config.ThirdParty{SearchAPIKey: replayKey, SerpAPIKey: replayKey}
+	ctx := context.Background()
+
+	walmart := NewWalmartAPI(config.Walmart{PriceSources: []string{"serpapi"}}, keys, client, nil, nil, nil)
+	price, err := walmart.GetEggPrice(ctx, "94102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPrice(t, price, "Walmart", parsedPrice{
+		Sku:         "10450114",
+		BasePrice:   4.27,
+		PromoPrice:  3.88,
+		FinalPrice:  3.88,
+		ProductName: "Great Value Large White Eggs, 12 Count",
+		ProductURL:  "https://www.walmart.com/ip/10450114",
+		InStock:     true,
+		PickupEta:   "Check store availability",
+		Source:      "serpapi",
+		RequestID:   "sample",
+	})
+
+	// Google Shopping lists Target first, but only Walgreens.com is 1P pricing
+	walgreens := NewWalgreensAPI(config.Walgreens{PriceSources: []string{"searchapi", "serpapi"}}, keys, client, nil, nil)
+	price, err = walgreens.GetEggPrice(ctx, "94102")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPrice(t, price, "Walgreens", parsedPrice{
+		Sku:         "9187",
+		BasePrice:   4.79,
+		PromoPrice:  4.29,
+		FinalPrice:  4.29,
+		ProductName: "Walgreens Grade A Large White Eggs, 12 ct",
+		ProductURL:  "https://www.google.com/shopping/product/9187",
+		InStock:     true,
+		PickupEta:   "Check store availability",
+		Source:      "serpapi",
+		RequestID:   "sample",
+	})
+}
+
+func TestSoldBy(t *testing.T) {
+	tests := []struct {
+		source, retailer string
+		want             bool
+	}{
+		{"Walgreens", "Walgreens", true},
+		{"Walgreens.com", "Walgreens", true},
+		{"walgreens", "Walgreens", true},
+		{"Walmart - Store", "Walmart", true},
+		{" Walmart", "Walmart", true},
+		{"Target", "Walgreens", false},
+		{"Walmartian Foods", "Walmart", false},
+		{"eBay - walgreens_deals", "Walgreens", false},
+		{"", "Walmart", false},
+	}
+	for _, tt := range tests {
+		if got := soldBy(tt.source, tt.retailer); got != tt.want {
+			t.Errorf("soldBy(%q, %q) = %t, want %t", tt.source, tt.retailer, got, tt.want)
+		}
+	}
+}
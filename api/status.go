@@ -0,0 +1,121 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// Configurable is implemented by adapters that can report how they are set up
+type Configurable interface {
+	// Mock reports whether the adapter serves fixture data instead of calling providers
+	Mock() bool
+	// ConfigError describes credentials that are set but unusable, or nil
+	ConfigError() error
+}
+
+// ProviderStatus is a snapshot of a retailer adapter's setup and recent outcomes
+type ProviderStatus struct {
+	Provider    string
+	Mock        bool
+	ConfigError error
+	LastSuccess time.Time // zero if no call has succeeded yet
+	LastFailure time.Time // zero if no call has failed yet
+	LastError   error
+	Breaker     BreakerState
+}
+
+// StatusRetailer wraps a Retailer, remembers the outcome of its last calls
+// and fails calls fast while its circuit breaker is open
+type StatusRetailer struct {
+	name   string
+	next   Retailer
+	config Configurable
+
+	mu          sync.RWMutex
+	lastSuccess time.Time
+	lastFailure time.Time
+	lastError   error
+	breaker     breaker
+}
+
+// NewStatusRetailer tracks calls through r. config is the underlying adapter,
+// which may be nil if it doesn't implement Configurable.
+func NewStatusRetailer(name string, r Retailer, config Configurable, breaker config.Breaker) *StatusRetailer {
+	return &StatusRetailer{name: name, next: r, config: config, breaker: newBreaker(breaker)}
+}
+
+func (r *StatusRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	trial, err := r.allow()
+	if err != nil {
+		return nil, err
+	}
+	price, err := r.next.GetEggPrice(ctx, zipcode)
+	r.record(ctx, err, trial)
+	return price, err
+}
+
+func (r *StatusRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	trial, err := r.allow()
+	if err != nil {
+		return nil, err
+	}
+	prices, err := r.next.GetStorePrices(ctx, zipcode, radiusMiles)
+	r.record(ctx, err, trial)
+	return prices, err
+}
+
+// allow asks the breaker whether a call may go ahead, and returns the error
+// calls fail with while it is open
+func (r *StatusRetailer) allow() (trial bool, err error) {
+	r.mu.Lock()
+	ok, trial := r.breaker.allow(time.Now())
+	r.mu.Unlock()
+	if !ok {
+		return false, providerError(r.name, ErrUpstreamUnavailable, fmt.Errorf("%s: circuit breaker open", r.name))
+	}
+	return trial, nil
+}
+
+// Status returns the adapter's current status
+func (r *StatusRetailer) Status() ProviderStatus {
+	r.mu.RLock()
+	status := ProviderStatus{
+		Provider:    r.name,
+		LastSuccess: r.lastSuccess,
+		LastFailure: r.lastFailure,
+		LastError:   r.lastError,
+		Breaker:     r.breaker.current(time.Now()),
+	}
+	r.mu.RUnlock()
+
+	if r.config != nil {
+		status.Mock = r.config.Mock()
+		status.ConfigError = r.config.ConfigError()
+	}
+	return status
+}
+
+func (r *StatusRetailer) record(ctx context.Context, err error, trial bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	// A caller hanging up says nothing about the provider
+	if err != nil && ctx.Err() != nil {
+		r.breaker.record(outcomeUnknown, trial, now)
+		return
+	}
+	r.breaker.record(callOutcome(err), trial, now)
+
+	if err != nil {
+		r.lastFailure = now
+		r.lastError = err
+		return
+	}
+	r.lastSuccess = now
+}
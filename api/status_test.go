@@ -0,0 +1,131 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// stubRetailer answers every call with err and counts the calls
+type stubRetailer struct {
+	err   error
+	calls int
+}
+
+func (s *stubRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &model.RetailerPrice{FinalPrice: 3.99}, nil
+}
+
+func (s *stubRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	price, err := s.GetEggPrice(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+	return []*model.RetailerPrice{price}, nil
+}
+
+func TestBreakerTripsAndRecovers(t *testing.T) {
+	stub := &stubRetailer{err: providerError("stub", ErrUpstreamUnavailable, errors.New("503"))}
+	r := NewStatusRetailer("stub", stub, nil, config.Breaker{FailureThreshold: 3, Cooldown: time.Hour})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		r.GetEggPrice(ctx, "94102")
+	}
+	if got := r.Status().Breaker; got != BreakerOpen {
+		t.Fatalf("breaker = %s after 3 failures, want OPEN", got)
+	}
+
+	_, err := r.GetEggPrice(ctx, "94102")
+	if !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Errorf("open breaker returned %v, want ErrUpstreamUnavailable", err)
+	}
+	if stub.calls != 3 {
+		t.Errorf("provider called %d times, want an open breaker to fail fast", stub.calls)
+	}
+
+	// Skip the cooldown
+	r.mu.Lock()
+	r.breaker.openedAt = time.Now().Add(-time.Hour)
+	r.mu.Unlock()
+	if got := r.Status().Breaker; got != BreakerHalfOpen {
+		t.Fatalf("breaker = %s after the cooldown, want HALF_OPEN", got)
+	}
+
+	stub.err = nil
+	if _, err := r.GetEggPrice(ctx, "94102"); err != nil {
+		t.Fatalf("trial call: %v", err)
+	}
+	if got := r.Status().Breaker; got != BreakerClosed {
+		t.Errorf("breaker = %s after a successful trial, want CLOSED", got)
+	}
+}
+
+func TestBreakerHalfOpenAllowsOneTrial(t *testing.T) {
+	b := newBreaker(config.Breaker{FailureThreshold: 1, Cooldown: time.Minute})
+	now := time.Now()
+	b.record(outcomeFailed, false, now)
+
+	if ok, _ := b.allow(now); ok {
+		t.Fatal("open breaker allowed a call")
+	}
+	later := now.Add(time.Minute)
+	if ok, trial := b.allow(later); !ok || !trial {
+		t.Fatalf("allow after the cooldown = %t, %t, want a trial call", ok, trial)
+	}
+	if ok, _ := b.allow(later); ok {
+		t.Error("half-open breaker allowed a second call during the trial")
+	}
+
+	b.record(outcomeFailed, true, later)
+	if got := b.current(later); got != BreakerOpen {
+		t.Errorf("breaker = %s after a failed trial, want OPEN", got)
+	}
+}
+
+func TestBreakerIgnoresProviderAnswers(t *testing.T) {
+	stub := &stubRetailer{err: providerError("stub", ErrNotFound, errors.New("no eggs"))}
+	r := NewStatusRetailer("stub", stub, nil, config.Breaker{FailureThreshold: 1, Cooldown: time.Hour})
+
+	for i := 0; i < 3; i++ {
+		r.GetEggPrice(context.Background(), "94102")
+	}
+	if got := r.Status().Breaker; got != BreakerClosed {
+		t.Errorf("breaker = %s after ErrNotFound, want CLOSED", got)
+	}
+	if stub.calls != 3 {
+		t.Errorf("provider called %d times, want 3", stub.calls)
+	}
+}
+
+func TestBreakerIgnoresCancelledCalls(t *testing.T) {
+	stub := &stubRetailer{err: providerError("stub", ErrUpstreamUnavailable, context.Canceled)}
+	r := NewStatusRetailer("stub", stub, nil, config.Breaker{FailureThreshold: 1, Cooldown: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	r.GetEggPrice(ctx, "94102")
+	if got := r.Status().Breaker; got != BreakerClosed {
+		t.Errorf("breaker = %s after a cancelled call, want CLOSED", got)
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	stub := &stubRetailer{err: providerError("stub", ErrUpstreamUnavailable, errors.New("503"))}
+	r := NewStatusRetailer("stub", stub, nil, config.Breaker{})
+
+	for i := 0; i < 10; i++ {
+		r.GetEggPrice(context.Background(), "94102")
+	}
+	if stub.calls != 10 || r.Status().Breaker != BreakerClosed {
+		t.Errorf("provider called %d times, breaker %s; want a threshold of 0 to disable the breaker", stub.calls, r.Status().Breaker)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// DefaultRadiusMiles is the store search radius when the caller doesn't pass one
+const DefaultRadiusMiles = 10.0
+
+// mockStreets gives mock stores plausible addresses
+var mockStreets = []string{"Main St", "Market St", "Broadway", "Oak Ave", "Elm St", "Park Blvd"}
+
+// withStore copies a zip-level price onto a specific store
+func withStore(price *model.RetailerPrice, location *model.StoreLocation) *model.RetailerPrice {
+	storePrice := *price
+	storePrice.StoreID = strPtr(location.StoreID)
+	storePrice.Location = location
+	return &storePrice
+}
+
+// rankStores drops stores known to be outside the radius and orders the rest
+// by final price, nearest first on ties. Stores without a distance are kept,
+// since the provider already searched by zipcode.
+func rankStores(prices []*model.RetailerPrice, radiusMiles float64) []*model.RetailerPrice {
+	ranked := make([]*model.RetailerPrice, 0, len(prices))
+	for _, p := range prices {
+		if d := storeDistance(p); d != nil && *d > radiusMiles {
+			continue
+		}
+		ranked = append(ranked, p)
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].FinalPrice != ranked[j].FinalPrice {
+			return ranked[i].FinalPrice < ranked[j].FinalPrice
+		}
+		di, dj := storeDistance(ranked[i]), storeDistance(ranked[j])
+		return di != nil && (dj == nil || *di < *dj)
+	})
+	return ranked
+}
+
+func roundCents(f float64) float64 {
+	return math.Round(f*100) / 100
+}
+
+func storeDistance(p *model.RetailerPrice) *float64 {
+	if p.Location == nil {
+		return nil
+	}
+	return p.Location.DistanceMiles
+}
+
+// mockStorePrices spreads a mock zip-level price across nearby mock stores.
+// Distances and per-store price adjustments are derived from the zipcode so
+// results stay stable between requests.
+func mockStorePrices(base *model.RetailerPrice, storeName, hours string, radiusMiles float64) []*model.RetailerPrice {
+	// Salt with the store name so each retailer gets its own set of stores
+	zipcodeHash := 0
+	for _, c := range base.Zipcode + storeName {
+		zipcodeHash += int(c)
+	}
+
+	var prices []*model.RetailerPrice
+	for i := 0; i < 5; i++ {
+		storeNumber := 1000 + (zipcodeHash*(i+3))%9000
+		distance := float64(i)*3.0 + float64((zipcodeHash+i*7)%25)/10.0
+		// The nearest store matches the zip-level price exactly so mock
+		// scenarios stay reproducible; others cost up to $0.49 more
+		adjustment := 0.0
+		if i > 0 {
+			adjustment = float64((zipcodeHash+i*13)%50) / 100.0
+		}
+
+		location := &model.StoreLocation{
+			StoreID:       fmt.Sprintf("%d", storeNumber),
+			Name:          fmt.Sprintf("%s #%d", storeName, storeNumber),
+			Address:       fmt.Sprintf("%d %s", 100+(zipcodeHash*(i+1))%4900, mockStreets[(zipcodeHash+i)%len(mockStreets)]),
+			Zipcode:       strPtr(base.Zipcode),
+			DistanceMiles: floatPtr(distance),
+			Hours:         strPtr(hours),
+		}
+
+		storePrice := withStore(base, location)
+		storePrice.BasePrice = roundCents(base.BasePrice + adjustment)
+		storePrice.FinalPrice = roundCents(base.FinalPrice + adjustment)
+		if base.PromoPrice != nil {
+			storePrice.PromoPrice = floatPtr(roundCents(*base.PromoPrice + adjustment))
+		}
+		prices = append(prices, storePrice)
+	}
+
+	ranked := rankStores(prices, radiusMiles)
+	if len(ranked) == 0 {
+		// Always report the nearest store so the best-store shortcut resolves
+		return prices[:1]
+	}
+	return ranked
+}
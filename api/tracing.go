@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/jkzilla/egg-price-compare/api"
+
+// TraceRetailer returns r with a span around each adapter call. Wrap outside
+// any chaos layer so injected faults show up in traces.
+func TraceRetailer(name string, r Retailer) Retailer {
+	return &tracedRetailer{name: name, next: r}
+}
+
+// TracePriceSource returns s with a span around each price lookup
+func TracePriceSource(s PriceSource) PriceSource {
+	return &tracedPriceSource{next: s}
+}
+
+type tracedRetailer struct {
+	name string
+	next Retailer
+}
+
+func (r *tracedRetailer) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	ctx, span := startSpan(ctx, r.name+".GetEggPrice", attribute.String("retailer", r.name), attribute.String("zipcode", zipcode))
+	defer span.End()
+
+	price, err := r.next.GetEggPrice(ctx, zipcode)
+	if err != nil {
+		endWithError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.String("price.source", price.Source))
+	return price, nil
+}
+
+func (r *tracedRetailer) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	ctx, span := startSpan(ctx, r.name+".GetStorePrices",
+		attribute.String("retailer", r.name),
+		attribute.String("zipcode", zipcode),
+		attribute.Float64("radius_miles", radiusMiles),
+	)
+	defer span.End()
+
+	prices, err := r.next.GetStorePrices(ctx, zipcode, radiusMiles)
+	if err != nil {
+		endWithError(span, err)
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("stores", len(prices)))
+	return prices, nil
+}
+
+type tracedPriceSource struct {
+	next PriceSource
+}
+
+func (s *tracedPriceSource) Name() string { return s.next.Name() }
+
+func (s *tracedPriceSource) FetchPrice(ctx context.Context, retailer, zipcode string) (*ThirdPartyPriceResponse, error) {
+	ctx, span := startSpan(ctx, s.next.Name()+".FetchPrice",
+		attribute.String("price_source", s.next.Name()),
+		attribute.String("retailer", retailer),
+		attribute.String("zipcode", zipcode),
+	)
+	defer span.End()
+
+	price, err := s.next.FetchPrice(ctx, retailer, zipcode)
+	if err != nil {
+		endWithError(span, err)
+	}
+	return price, err
+}
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(instrumentationName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endWithError(span trace.Span, err error) {
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -1,14 +1,14 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
+	"log/slog"
 	"net/http"
-	"net/url"
-	"os"
 	"time"
 
+	"github.com/jkzilla/egg-price-compare/config"
 	"github.com/jkzilla/egg-price-compare/graph/model"
 )
 
@@ -16,10 +16,12 @@ import (
 // Note: Walgreens does not expose a general product pricing API
 // Price data must come from a third-party provider (SearchAPI, SerpApi, Apify, etc.)
 type WalgreensAPI struct {
-	apiKey              string // Walgreens API Key
-	apiSecret           string // Walgreens API Secret (OAuth)
-	thirdPartyAPIKey    string // Third-party price provider API key (SearchAPI, SerpApi, etc.)
-	client              *http.Client
+	apiKey       *config.Secret   // Walgreens API Key
+	apiSecret    *config.Secret   // Walgreens API Secret (OAuth)
+	priceSources PriceSourceChain // Third-party price providers (SearchAPI, SerpApi, Apify) in fallback order
+	client       *http.Client
+	tokens       *WalgreensTokenSource // OAuth tokens for Inventory + Digital Offers
+	mock         *MockProvider         // Fixtures served without credentials
 }
 
 // WalgreensInventoryResponse represents Store Inventory API response
@@ -32,6 +34,29 @@ type WalgreensInventoryResponse struct {
 	PickupETA   string `json:"pickupEta"`
 }
 
+// WalgreensStore represents a store from the Store Locator API
+type WalgreensStore struct {
+	StoreNumber string  `json:"storeNumber"`
+	Name        string  `json:"name"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+	Distance    float64 `json:"distance"` // miles from the searched zipcode
+	Phone       string  `json:"phone"`
+	OpenTime    string  `json:"storeOpenTime"`
+	CloseTime   string  `json:"storeCloseTime"`
+	Address     struct {
+		Street string `json:"street"`
+		City   string `json:"city"`
+		State  string `json:"state"`
+		Zip    string `json:"zip"`
+	} `json:"address"`
+}
+
+// WalgreensStoreSearchResponse represents Store Locator API response
+type WalgreensStoreSearchResponse struct {
+	Stores []WalgreensStore `json:"stores"`
+}
+
 // WalgreensDigitalOffer represents a digital coupon/offer
 type WalgreensDigitalOffer struct {
 	OfferID         string  `json:"offerId"`
@@ -47,8 +72,8 @@ type WalgreensOffersResponse struct {
 	Offers []WalgreensDigitalOffer `json:"offers"`
 }
 
-// ThirdPartyPriceResponse represents price data from third-party provider
-// This could be SearchAPI, SerpApi, Apify, or custom scraper
+// ThirdPartyPriceResponse is price data normalized from a third-party provider
+// Each PriceSource parses its own response format into this shape
 type ThirdPartyPriceResponse struct {
 	ProductName  string  `json:"product_name"`
 	Price        float64 `json:"price"`
@@ -58,14 +83,21 @@ type ThirdPartyPriceResponse struct {
 	SKU          string  `json:"sku"`
 	ProductURL   string  `json:"product_url"`
 	Available    bool    `json:"available"`
+
+	// Provenance, filled in by the source and the price chain
+	Source    string    `json:"-"`
+	RequestID string    `json:"-"`
+	FetchedAt time.Time `json:"-"`
 }
 
-func NewWalgreensAPI() *WalgreensAPI {
+func NewWalgreensAPI(cfg config.Walgreens, thirdParty config.ThirdParty, client *http.Client, chaos *Chaos, mock *MockProvider) *WalgreensAPI {
 	return &WalgreensAPI{
-		apiKey:           os.Getenv("WALGREENS_API_KEY"),
-		apiSecret:        os.Getenv("WALGREENS_API_SECRET"),
-		thirdPartyAPIKey: os.Getenv("SEARCHAPI_KEY"), // or SERPAPI_KEY, APIFY_KEY, etc.
-		client:           &http.Client{Timeout: 15 * time.Second},
+		apiKey:       cfg.APIKey,
+		apiSecret:    cfg.APISecret,
+		priceSources: NewPriceSourceChain("walgreens", cfg.PriceSources, thirdParty, cfg.ApifyActor, client, chaos),
+		client:       client,
+		tokens:       NewWalgreensTokenSource(cfg.APIKey, cfg.APISecret, client),
+		mock:         mock,
 	}
 }
 
@@ -73,108 +105,36 @@ func NewWalgreensAPI() *WalgreensAPI {
 // 1. Walgreens Store Inventory API for in-stock status
 // 2. Walgreens Digital Offers API for clip-able coupons
 // 3. Third-party data provider for actual pricing (SearchAPI, SerpApi, Apify, etc.)
-func (w *WalgreensAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error) {
-	if w.apiKey == "" && w.thirdPartyAPIKey == "" {
-		// Return mock data for development
-		// Vary prices slightly based on zipcode for more realistic testing
-		zipcodeHash := 0
-		for _, c := range zipcode {
-			zipcodeHash += int(c)
-		}
-		
-		// Base price varies between $3.99 and $5.29
-		basePrice := 3.99 + float64(zipcodeHash%130)/100.0
-		
-		// Sometimes there's a sale price (70% of the time)
-		var promoPrice *float64
-		var offers []*model.DigitalOffer
-		hasPromo := zipcodeHash%10 < 7
-		
-		finalPrice := basePrice
-		if hasPromo {
-			discount := 0.20 + float64(zipcodeHash%60)/100.0
-			promo := basePrice - discount
-			promoPrice = &promo
-			finalPrice = promo
-		}
-		
-		// Digital coupons (50% of the time)
-		if zipcodeHash%10 < 5 {
-			couponDiscount := 0.50
-			offers = append(offers, &model.DigitalOffer{
-				OfferID:        "WAG-DIGITAL-001",
-				Description:    "Digital Coupon: Save $0.50",
-				DiscountAmount: floatPtr(couponDiscount),
-				ExpiresAt:      strPtr(time.Now().AddDate(0, 0, 7).Format(time.RFC3339)),
-			})
-			finalPrice -= couponDiscount
-		}
-		
-		// Rewards program offer (20% of the time)
-		if zipcodeHash%10 < 2 {
-			offers = append(offers, &model.DigitalOffer{
-				OfferID:         "WAG-REWARDS-002",
-				Description:     "myWalgreens: 10% off",
-				DiscountPercent: floatPtr(10.0),
-			})
-			finalPrice *= 0.90
-		}
-		
-		// Stock status varies (85% in stock)
-		inStock := zipcodeHash%20 < 17
-		pickupEta := "Ready in 1 hour"
-		if !inStock {
-			pickupEta = "Out of stock at nearby stores"
-		} else if zipcodeHash%5 == 0 {
-			pickupEta = "Ready in 2-3 hours"
-		}
-		
-		// Store ID varies
-		storeID := fmt.Sprintf("%d", 10000+(zipcodeHash%5000))
-		
-		productURL := "https://www.walgreens.com/store/c/walgreens-grade-a-large-white-eggs/ID=prod6378461"
-		
-		return &model.RetailerPrice{
-			Store:          "Walgreens",
-			Sku:            strPtr("prod6378461"),
-			Upc:            strPtr("041220993758"),
-			StoreID:        strPtr(storeID),
-			Zipcode:        zipcode,
-			BasePrice:      basePrice,
-			PromoPrice:     promoPrice,
-			FinalPrice:     finalPrice,
-			ProductName:    "Walgreens Grade A Large White Eggs, 12 ct",
-			ProductURL:     &productURL,
-			InStock:        inStock,
-			PickupEta:      strPtr(pickupEta),
-			DigitalOffers:  offers,
-			LastUpdated:    time.Now().Format(time.RFC3339),
-		}, nil
+func (w *WalgreensAPI) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	if w.Mock() {
+		// Return fixture data for development (see api/fixtures)
+		return w.mock.GetEggPrice(ctx, "Walgreens", zipcode)
 	}
 
 	// Step 1: Get price data from third-party provider
 	// This is necessary because Walgreens doesn't expose retail pricing API
-	priceData, err := w.fetchPriceFromThirdParty(zipcode)
+	priceData, err := w.fetchPriceFromThirdParty(ctx, zipcode)
 	if err != nil {
 		return nil, fmt.Errorf("walgreens: failed to fetch price data: %w", err)
 	}
 
 	// Step 2: Get inventory status from Walgreens Store Inventory API
-	inventory, err := w.fetchInventory(priceData.SKU, zipcode)
+	inventory, err := w.fetchInventory(ctx, priceData.SKU, zipcode)
 	if err != nil {
 		// Log error but continue with price data
-		fmt.Printf("walgreens: inventory check failed: %v\n", err)
+		slog.WarnContext(ctx, "walgreens: inventory check failed", "error", err)
 		inventory = &WalgreensInventoryResponse{
 			InStock:   priceData.Available,
 			PickupETA: "Check store availability",
 		}
 	}
+	availability := walgreensAvailability(inventory, err == nil)
 
 	// Step 3: Get digital offers from Walgreens Digital Offers API
-	offers, err := w.fetchDigitalOffers(priceData.SKU)
+	offers, err := w.fetchDigitalOffers(ctx, priceData.SKU)
 	if err != nil {
 		// Log error but continue without offers
-		fmt.Printf("walgreens: digital offers fetch failed: %v\n", err)
+		slog.WarnContext(ctx, "walgreens: digital offers fetch failed", "error", err)
 		offers = []*model.DigitalOffer{}
 	}
 
@@ -185,11 +145,11 @@ func (w *WalgreensAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error)
 	}
 	finalPrice := priceData.Price
 	var promoPrice *float64
-	
+
 	if priceData.OnSale {
 		promoPrice = &priceData.Price
 	}
-	
+
 	// Apply digital offer discounts
 	for _, offer := range offers {
 		if offer.DiscountAmount != nil {
@@ -197,7 +157,7 @@ func (w *WalgreensAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error)
 		}
 	}
 
-	return &model.RetailerPrice{
+	price := &model.RetailerPrice{
 		Store:         "Walgreens",
 		Sku:           &priceData.SKU,
 		Upc:           &priceData.UPC,
@@ -211,93 +171,182 @@ func (w *WalgreensAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error)
 		InStock:       inventory.InStock,
 		PickupEta:     &inventory.PickupETA,
 		DigitalOffers: offers,
+		Availability:  availability,
 		LastUpdated:   time.Now().Format(time.RFC3339),
-	}, nil
+	}
+	// Walgreens has no pricing API, so the price is attributed to the third-party source
+	setProvenance(price, priceData.Source, priceData.RequestID, priceData.FetchedAt)
+	return price, nil
 }
 
-// fetchPriceFromThirdParty gets pricing data from SearchAPI, SerpApi, Apify, or similar
-// These services are designed for price-comparison use cases and respect ToS
-func (w *WalgreensAPI) fetchPriceFromThirdParty(zipcode string) (*ThirdPartyPriceResponse, error) {
-	if w.thirdPartyAPIKey == "" {
-		return nil, fmt.Errorf("third-party API key not configured")
-	}
-
-	// Example: SearchAPI for Walgreens product search
-	// Documentation: https://www.searchapi.io/docs/walgreens
-	baseURL := "https://www.searchapi.io/api/v1/search"
-	
-	params := url.Values{}
-	params.Add("engine", "walgreens")
-	params.Add("q", "eggs dozen large white")
-	params.Add("api_key", w.thirdPartyAPIKey)
-	params.Add("location", zipcode)
-	
-	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-	
-	req, err := http.NewRequest("GET", requestURL, nil)
+// GetStorePrices returns egg prices for each Walgreens store within the radius,
+// cheapest first. Pricing comes from the zip-level third-party lookup; stock
+// and pickup readiness are checked per store.
+func (w *WalgreensAPI) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	price, err := w.GetEggPrice(ctx, zipcode)
 	if err != nil {
 		return nil, err
 	}
 
-	resp, err := w.client.Do(req)
-	if err != nil {
-		return nil, err
+	if w.Mock() {
+		return mockStorePrices(price, "Walgreens", "8:00 AM - 10:00 PM", radiusMiles), nil
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("third-party API returned status %d: %s", resp.StatusCode, string(body))
+	stores, err := w.fetchStores(ctx, zipcode, radiusMiles)
+	if err != nil || len(stores) == 0 {
+		// Log error but fall back to the zip-level price
+		slog.WarnContext(ctx, "walgreens: store lookup failed", "error", err)
+		return []*model.RetailerPrice{price}, nil
+	}
+
+	var prices []*model.RetailerPrice
+	for _, store := range stores {
+		location := &model.StoreLocation{
+			StoreID:       store.StoreNumber,
+			Name:          store.Name,
+			Address:       store.Address.Street,
+			City:          strPtr(store.Address.City),
+			State:         strPtr(store.Address.State),
+			Zipcode:       strPtr(store.Address.Zip),
+			Latitude:      floatPtr(store.Latitude),
+			Longitude:     floatPtr(store.Longitude),
+			DistanceMiles: floatPtr(store.Distance),
+			Phone:         strPtr(store.Phone),
+		}
+		if store.OpenTime != "" && store.CloseTime != "" {
+			location.Hours = strPtr(store.OpenTime + " - " + store.CloseTime)
+		}
+
+		storePrice := withStore(price, location)
+		if price.Sku != nil {
+			if inventory, err := w.fetchStoreInventory(ctx, *price.Sku, store.StoreNumber); err == nil {
+				storePrice.InStock = inventory.InStock
+				storePrice.PickupEta = strPtr(inventory.PickupETA)
+				storePrice.Availability = walgreensAvailability(inventory, true)
+			}
+		}
+		prices = append(prices, storePrice)
 	}
 
-	// Parse response (format depends on provider)
-	// This is a simplified example - actual implementation depends on provider
-	var result struct {
-		Products []ThirdPartyPriceResponse `json:"products"`
+	if ranked := rankStores(prices, radiusMiles); len(ranked) > 0 {
+		return ranked, nil
 	}
-	
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	return []*model.RetailerPrice{price}, nil
+}
+
+// Mock reports whether neither Walgreens nor a third-party price source is configured
+func (w *WalgreensAPI) Mock() bool {
+	return w.apiKey.Get() == "" && len(w.priceSources) == 0
+}
+
+// ConfigError reports Walgreens credentials that can't serve prices: OAuth
+// needs both halves, and Walgreens has no pricing API of its own, so a key
+// is useless without a third-party price source
+func (w *WalgreensAPI) ConfigError() error {
+	switch {
+	case (w.apiKey.Get() == "") != (w.apiSecret.Get() == ""):
+		return providerError("walgreens", ErrAuthMisconfigured, fmt.Errorf("WALGREENS_API_KEY and WALGREENS_API_SECRET must be set together"))
+	case w.apiKey.Get() != "" && len(w.priceSources) == 0:
+		return providerError("walgreens", ErrAuthMisconfigured, fmt.Errorf("WALGREENS_API_KEY is set but no third-party price source is configured"))
 	}
-	
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
+	return nil
+}
+
+// walgreensAvailability converts a Store Inventory response. When the
+// inventory call failed the quantity is unknown, so no band is guessed.
+func walgreensAvailability(inventory *WalgreensInventoryResponse, fromInventoryAPI bool) *model.Availability {
+	availability := &model.Availability{
+		InStore:     inventory.InStock,
+		Pickup:      inventory.InStock,
+		PickupReady: inventory.PickupReady,
+		StockLevel:  model.StockLevelUnknown,
 	}
 
-	if len(result.Products) == 0 {
-		return nil, fmt.Errorf("no products found")
+	if !inventory.InStock {
+		availability.StockLevel = model.StockLevelOutOfStock
+	} else if fromInventoryAPI {
+		availability.StockLevel = stockLevelFromQuantity(inventory.Quantity)
+		availability.Quantity = intPtr(inventory.Quantity)
 	}
 
-	return &result.Products[0], nil
+	return availability
+}
+
+// fetchPriceFromThirdParty gets pricing data from the configured provider chain
+// (SearchAPI, SerpApi, Apify). These services are designed for price-comparison
+// use cases and respect ToS.
+func (w *WalgreensAPI) fetchPriceFromThirdParty(ctx context.Context, zipcode string) (*ThirdPartyPriceResponse, error) {
+	return w.priceSources.FetchPrice(ctx, "walgreens", zipcode)
 }
 
 // fetchInventory calls Walgreens Store Inventory API
-func (w *WalgreensAPI) fetchInventory(sku, zipcode string) (*WalgreensInventoryResponse, error) {
-	if w.apiKey == "" {
-		return nil, fmt.Errorf("walgreens API key not configured")
+func (w *WalgreensAPI) fetchInventory(ctx context.Context, sku, zipcode string) (*WalgreensInventoryResponse, error) {
+	if w.apiKey.Get() == "" {
+		return nil, providerError("walgreens", ErrAuthMisconfigured, fmt.Errorf("walgreens API key not configured"))
 	}
 
 	// Walgreens Store Inventory API
 	// Documentation: https://developer.walgreens.com/store-inventory
 	url := fmt.Sprintf("https://services.walgreens.com/api/stores/inventory?sku=%s&zip=%s", sku, zipcode)
-	
-	req, err := http.NewRequest("GET", url, nil)
+
+	resp, err := w.doAuthorized(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("walgreens", resp.StatusCode, fmt.Errorf("inventory API returned status %d", resp.StatusCode))
+	}
+
+	var inventory WalgreensInventoryResponse
+	if err := json.NewDecoder(resp.Body).Decode(&inventory); err != nil {
+		return nil, err
+	}
+
+	return &inventory, nil
+}
+
+// fetchStores calls Walgreens Store Locator API
+func (w *WalgreensAPI) fetchStores(ctx context.Context, zipcode string, radiusMiles float64) ([]WalgreensStore, error) {
+	if w.apiKey.Get() == "" {
+		return nil, providerError("walgreens", ErrAuthMisconfigured, fmt.Errorf("walgreens API key not configured"))
+	}
+
+	// Walgreens Store Locator API
+	// Documentation: https://developer.walgreens.com/store-locator
+	url := fmt.Sprintf("https://services.walgreens.com/api/stores/search?zip=%s&radius=%g", zipcode, radiusMiles)
+
+	resp, err := w.doAuthorized(ctx, url)
 	if err != nil {
 		return nil, err
 	}
-	
-	req.Header.Set("apikey", w.apiKey)
-	// OAuth token would go here if required
-	
-	resp, err := w.client.Do(req)
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("walgreens", resp.StatusCode, fmt.Errorf("store locator API returned status %d", resp.StatusCode))
+	}
+
+	var search WalgreensStoreSearchResponse
+	if err := json.NewDecoder(resp.Body).Decode(&search); err != nil {
+		return nil, err
+	}
+
+	return search.Stores, nil
+}
+
+// fetchStoreInventory calls Walgreens Store Inventory API for a single store
+func (w *WalgreensAPI) fetchStoreInventory(ctx context.Context, sku, storeID string) (*WalgreensInventoryResponse, error) {
+	url := fmt.Sprintf("https://services.walgreens.com/api/stores/inventory?sku=%s&storeId=%s", sku, storeID)
+
+	resp, err := w.doAuthorized(ctx, url)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("inventory API returned status %d", resp.StatusCode)
+		return nil, statusError("walgreens", resp.StatusCode, fmt.Errorf("inventory API returned status %d", resp.StatusCode))
 	}
 
 	var inventory WalgreensInventoryResponse
@@ -309,23 +358,16 @@ func (w *WalgreensAPI) fetchInventory(sku, zipcode string) (*WalgreensInventoryR
 }
 
 // fetchDigitalOffers calls Walgreens Digital Offers API
-func (w *WalgreensAPI) fetchDigitalOffers(sku string) ([]*model.DigitalOffer, error) {
-	if w.apiKey == "" {
+func (w *WalgreensAPI) fetchDigitalOffers(ctx context.Context, sku string) ([]*model.DigitalOffer, error) {
+	if w.apiKey.Get() == "" {
 		return []*model.DigitalOffer{}, nil
 	}
 
 	// Walgreens Digital Offers API
 	// Documentation: https://developer.walgreens.com/digital-offers
 	url := fmt.Sprintf("https://services.walgreens.com/api/offers?sku=%s", sku)
-	
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-	
-	req.Header.Set("apikey", w.apiKey)
-	
-	resp, err := w.client.Do(req)
+
+	resp, err := w.doAuthorized(ctx, url)
 	if err != nil {
 		return nil, err
 	}
@@ -347,21 +389,53 @@ func (w *WalgreensAPI) fetchDigitalOffers(sku string) ([]*model.DigitalOffer, er
 			OfferID:     offer.OfferID,
 			Description: offer.Description,
 			ExpiresAt:   &offer.ExpiresAt,
+			Clippable:   offer.Clippable,
 		}
-		
+
 		if offer.DiscountAmount > 0 {
 			modelOffer.DiscountAmount = &offer.DiscountAmount
 		}
 		if offer.DiscountPercent > 0 {
 			modelOffer.DiscountPercent = &offer.DiscountPercent
 		}
-		
+
 		offers = append(offers, modelOffer)
 	}
 
 	return offers, nil
 }
 
+// doAuthorized sends a GET to a Walgreens developer API with the API key and
+// an OAuth bearer token. A 401 means the token was revoked or expired early,
+// so the token is dropped and the request retried once with a fresh one.
+func (w *WalgreensAPI) doAuthorized(ctx context.Context, requestURL string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := w.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("apikey", w.apiKey.Get())
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		resp, err := w.client.Do(req)
+		if err != nil {
+			return nil, providerError("walgreens", ErrUpstreamUnavailable, err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+
+		resp.Body.Close()
+		w.tokens.Invalidate(token)
+	}
+}
+
 func strPtr(s string) *string {
 	return &s
 }
@@ -369,3 +443,7 @@ func strPtr(s string) *string {
 func floatPtr(f float64) *float64 {
 	return &f
 }
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// walgreensTokenURL is the OAuth 2.0 client-credentials endpoint for the
+// Walgreens developer APIs (Store Inventory, Digital Offers)
+const walgreensTokenURL = "https://services.walgreens.com/api/oauth/token"
+
+// tokenRefreshWindow is how long before expiry a cached token is refreshed,
+// so requests in flight never carry a token that expires mid-call
+const tokenRefreshWindow = 60 * time.Second
+
+// defaultTokenLifetime is assumed when the token endpoint leaves expires_in
+// out; a token that turns out to be shorter-lived is refetched on its 401
+const defaultTokenLifetime = 5 * time.Minute
+
+// WalgreensTokenResponse represents the OAuth token endpoint response
+type WalgreensTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// WalgreensTokenSource fetches and caches OAuth client-credentials tokens.
+// A single token is shared by all requests; concurrent callers that find the
+// token expired wait for one refresh instead of each hitting the token endpoint.
+// A token is only reused while the credentials it was issued for are current,
+// so a rotated secret takes effect on the next request.
+type WalgreensTokenSource struct {
+	clientID     *config.Secret
+	clientSecret *config.Secret
+	tokenURL     string
+	client       *http.Client
+
+	mu        sync.Mutex
+	token     string
+	refreshAt time.Time
+	issuedFor [2]string // client ID and secret the token was issued for
+}
+
+func NewWalgreensTokenSource(clientID, clientSecret *config.Secret, client *http.Client) *WalgreensTokenSource {
+	return &WalgreensTokenSource{
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		tokenURL:     walgreensTokenURL,
+		client:       client,
+	}
+}
+
+// Token returns a valid access token, refreshing it when it is missing or
+// close to expiring: see refreshTime
+func (s *WalgreensTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	credentials := [2]string{s.clientID.Get(), s.clientSecret.Get()}
+	if s.token != "" && s.issuedFor == credentials && time.Now().Before(s.refreshAt) {
+		return s.token, nil
+	}
+
+	token, expiresIn, err := s.fetchToken(ctx, credentials[0], credentials[1])
+	if err != nil {
+		return "", err
+	}
+
+	s.token = token
+	s.refreshAt = refreshTime(time.Now(), expiresIn)
+	s.issuedFor = credentials
+	return s.token, nil
+}
+
+// Invalidate drops the cached token if it is still the one that was rejected.
+// Comparing against the rejected token stops a slow request from discarding
+// a fresh token that another request already obtained.
+func (s *WalgreensTokenSource) Invalidate(rejected string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == rejected {
+		s.token = ""
+		s.refreshAt = time.Time{}
+	}
+}
+
+// refreshTime is when a token issued at issuedAt and valid for lifetime is
+// replaced: tokenRefreshWindow before it expires, or halfway through its
+// life when that is shorter, so a short-lived token is still reused rather
+// than refetched on every call
+func refreshTime(issuedAt time.Time, lifetime time.Duration) time.Time {
+	if lifetime <= 0 {
+		lifetime = defaultTokenLifetime
+	}
+	if lifetime <= 2*tokenRefreshWindow {
+		return issuedAt.Add(lifetime / 2)
+	}
+	return issuedAt.Add(lifetime - tokenRefreshWindow)
+}
+
+// fetchToken performs the client-credentials grant against the token endpoint
+func (s *WalgreensTokenSource) fetchToken(ctx context.Context, clientID, clientSecret string) (string, time.Duration, error) {
+	if clientID == "" || clientSecret == "" {
+		return "", 0, providerError("walgreens", ErrAuthMisconfigured, fmt.Errorf("walgreens: OAuth credentials not configured"))
+	}
+
+	form := url.Values{}
+	form.Add("grant_type", "client_credentials")
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, fmt.Errorf("walgreens: failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, clientSecret)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", 0, providerError("walgreens", ErrUpstreamUnavailable, fmt.Errorf("walgreens: token request failed: %w", err))
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		// invalid_client and unauthorized_client are answered with 400 or 401
+		err := fmt.Errorf("walgreens: token endpoint returned status %d: %s", resp.StatusCode, string(body))
+		if resp.StatusCode == http.StatusBadRequest {
+			return "", 0, providerError("walgreens", ErrAuthMisconfigured, err)
+		}
+		return "", 0, statusError("walgreens", resp.StatusCode, err)
+	}
+
+	var tokenResp WalgreensTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return "", 0, providerError("walgreens", ErrUpstreamUnavailable, fmt.Errorf("walgreens: failed to parse token response: %w", err))
+	}
+
+	if tokenResp.AccessToken == "" {
+		return "", 0, providerError("walgreens", ErrUpstreamUnavailable, fmt.Errorf("walgreens: token response missing access_token"))
+	}
+
+	return tokenResp.AccessToken, time.Duration(tokenResp.ExpiresIn) * time.Second, nil
+}
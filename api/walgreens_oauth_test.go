@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+func TestRefreshTime(t *testing.T) {
+	issued := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		lifetime time.Duration
+		want     time.Duration
+	}{
+		{time.Hour, time.Hour - tokenRefreshWindow},
+		{2 * tokenRefreshWindow, tokenRefreshWindow},
+		{30 * time.Second, 15 * time.Second},
+		{0, defaultTokenLifetime - tokenRefreshWindow},
+		{-time.Second, defaultTokenLifetime - tokenRefreshWindow},
+	}
+	for _, tt := range tests {
+		if got := refreshTime(issued, tt.lifetime).Sub(issued); got != tt.want {
+			t.Errorf("refreshTime(%s) = issued + %s, want issued + %s", tt.lifetime, got, tt.want)
+		}
+	}
+}
+
+func TestTokenSourceReusesShortLivedTokens(t *testing.T) {
+	for _, expiresIn := range []int{0, 30} {
+		t.Run(fmt.Sprintf("expires_in=%d", expiresIn), func(t *testing.T) {
+			var fetches atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := fetches.Add(1)
+				fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":%d}`, n, expiresIn)
+			}))
+			defer srv.Close()
+
+			tokens := NewWalgreensTokenSource(config.NewSecret("id"), config.NewSecret("secret"), srv.Client())
+			tokens.tokenURL = srv.URL
+			for i := 0; i < 3; i++ {
+				token, err := tokens.Token(context.Background())
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token != "token-1" {
+					t.Fatalf("call %d got %q, want the first token reused", i, token)
+				}
+			}
+			if n := fetches.Load(); n != 1 {
+				t.Errorf("token endpoint called %d times, want 1", n)
+			}
+		})
+	}
+}
+
+// walgreensOAuthServer issues token-1, token-2, ... from /token and answers
+// /stores with apiStatus, counting both
+type walgreensOAuthServer struct {
+	*httptest.Server
+	tokenFetches atomic.Int32
+	apiCalls     atomic.Int32
+	apiStatus    func(authorization string) int
+}
+
+func newWalgreensOAuthServer(t *testing.T, apiStatus func(authorization string) int) *walgreensOAuthServer {
+	s := &walgreensOAuthServer{apiStatus: apiStatus}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/token":
+			n := s.tokenFetches.Add(1)
+			fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+		case "/stores":
+			s.apiCalls.Add(1)
+			w.WriteHeader(s.apiStatus(r.Header.Get("Authorization")))
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *walgreensOAuthServer) api() *WalgreensAPI {
+	w := &WalgreensAPI{apiKey: config.NewSecret("id"), apiSecret: config.NewSecret("secret"), client: s.Client()}
+	w.tokens = NewWalgreensTokenSource(w.apiKey, w.apiSecret, s.Client())
+	w.tokens.tokenURL = s.URL + "/token"
+	return w
+}
+
+// A 401 means the token was revoked or expired early: it is dropped and the
+// call retried once with a new one
+func TestDoAuthorizedRetriesOnceWithANewToken(t *testing.T) {
+	srv := newWalgreensOAuthServer(t, func(authorization string) int {
+		if authorization == "Bearer token-1" {
+			return http.StatusUnauthorized
+		}
+		return http.StatusOK
+	})
+
+	resp, err := srv.api().doAuthorized(context.Background(), srv.URL+"/stores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d, want the retry's 200", resp.StatusCode)
+	}
+	if calls, fetches := srv.apiCalls.Load(), srv.tokenFetches.Load(); calls != 2 || fetches != 2 {
+		t.Errorf("%d API calls and %d token fetches, want 2 of each", calls, fetches)
+	}
+}
+
+func TestDoAuthorizedDoesNotRetryTwice(t *testing.T) {
+	srv := newWalgreensOAuthServer(t, func(authorization string) int {
+		return http.StatusUnauthorized
+	})
+
+	resp, err := srv.api().doAuthorized(context.Background(), srv.URL+"/stores")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("status = %d, want the retry's 401 returned", resp.StatusCode)
+	}
+	if calls := srv.apiCalls.Load(); calls != 2 {
+		t.Errorf("%d API calls, want the original and one retry", calls)
+	}
+}
+
+func TestTokenSourceSharesOneFetch(t *testing.T) {
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := fetches.Add(1)
+		// Keep the fetch in flight while the other callers arrive
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer srv.Close()
+
+	tokens := NewWalgreensTokenSource(config.NewSecret("id"), config.NewSecret("secret"), srv.Client())
+	tokens.tokenURL = srv.URL
+
+	var wg sync.WaitGroup
+	got := make([]string, 10)
+	errs := make([]error, len(got))
+	for i := range got {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got[i], errs[i] = tokens.Token(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	for i := range got {
+		if errs[i] != nil || got[i] != "token-1" {
+			t.Errorf("caller %d got %q, %v; want token-1", i, got[i], errs[i])
+		}
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("token endpoint called %d times, want 1", n)
+	}
+}
+
+// A rejected client is reported as misconfigured, and the next call tries
+// again rather than reusing an empty token
+func TestTokenSourceFailedFetch(t *testing.T) {
+	var reject atomic.Bool
+	reject.Store(true)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reject.Load() {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"token-1","token_type":"bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+
+	tokens := NewWalgreensTokenSource(config.NewSecret("id"), config.NewSecret("secret"), srv.Client())
+	tokens.tokenURL = srv.URL
+
+	token, err := tokens.Token(context.Background())
+	if !errors.Is(err, ErrAuthMisconfigured) {
+		t.Fatalf("Token with a rejected client = %q, %v; want ErrAuthMisconfigured", token, err)
+	}
+
+	reject.Store(false)
+	if token, err := tokens.Token(context.Background()); err != nil || token != "token-1" {
+		t.Errorf("Token after the client is fixed = %q, %v; want token-1", token, err)
+	}
+}
+
+func TestTokenSourceWithoutCredentials(t *testing.T) {
+	tokens := NewWalgreensTokenSource(config.NewSecret(""), config.NewSecret("secret"), http.DefaultClient)
+	tokens.tokenURL = "http://127.0.0.1:0/unreachable"
+
+	if _, err := tokens.Token(context.Background()); !errors.Is(err, ErrAuthMisconfigured) {
+		t.Errorf("Token without a client ID = %v, want ErrAuthMisconfigured", err)
+	}
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// The cassette's first inventory call is rejected with an expired token, so
+// this also covers fetching a new token and retrying
+func TestWalgreensReplay(t *testing.T) {
+	cfg := config.Walgreens{APIKey: replayKey, APISecret: replayKey, PriceSources: []string{"searchapi"}}
+	walgreens := NewWalgreensAPI(cfg, config.ThirdParty{SearchAPIKey: replayKey}, replayClient(t, "walgreens-searchapi.yaml"), nil, nil)
+
+	prices, err := walgreens.GetStorePrices(context.Background(), "94102", DefaultRadiusMiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 2 {
+		t.Fatalf("got %d stores, want 2", len(prices))
+	}
+
+	// The clippable $0.50 coupon comes off the $4.29 shelf price; the
+	// myWalgreens discount can't be clipped
+	want := parsedPrice{
+		Sku:         "prod6378461",
+		Upc:         "041220993758",
+		StoreID:     "10425",
+		BasePrice:   4.79,
+		PromoPrice:  4.29,
+		FinalPrice:  3.79,
+		ProductName: "Walgreens Grade A Large White Eggs, 12 ct",
+		ProductURL:  "https://www.walgreens.com/store/c/walgreens-grade-a-large-white-eggs/ID=prod6378461",
+		InStock:     true,
+		PickupEta:   "Ready in 2-3 hours",
+		Offers:      []string{"WAG-DIGITAL-001", "WAG-REWARDS-002"},
+		Source:      "searchapi",
+		RequestID:   "search_sample",
+	}
+	checkPrice(t, prices[0], "Walgreens", want)
+
+	// Same price, so the nearer store ranks first; the other is out of stock
+	want.StoreID, want.InStock, want.PickupEta = "7144", false, "Out of stock"
+	checkPrice(t, prices[1], "Walgreens", want)
+
+	if a := prices[0].Availability; a == nil || a.StockLevel != "LOW" || a.Quantity == nil || *a.Quantity != 3 {
+		t.Errorf("availability = %+v, want LOW with 3 left", a)
+	}
+	if l := prices[0].Location; l == nil || l.DistanceMiles == nil || *l.DistanceMiles != 0.6 || l.Hours == nil || *l.Hours != "07:00 AM - 10:00 PM" {
+		t.Errorf("location = %+v, want 0.6 miles away, open 07:00 AM - 10:00 PM", l)
+	}
+}
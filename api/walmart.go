@@ -1,176 +1,248 @@
 package api
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
-	"os"
+	"strconv"
 	"time"
 
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/geo"
 	"github.com/jkzilla/egg-price-compare/graph/model"
 )
 
 // WalmartAPI handles Walmart Affiliates Product Lookup API (1P retail pricing)
 type WalmartAPI struct {
-	affiliateID string // Walmart Affiliates Publisher ID
-	apiKey      string // Walmart Affiliates API Key
-	client      *http.Client
+	affiliateID  *config.Secret // Walmart Affiliates Publisher ID
+	apiKey       *config.Secret // Walmart Affiliates API Key
+	client       *http.Client
+	priceSources PriceSourceChain // Optional third-party fallback (WALMART_PRICE_SOURCES)
+	mock         *MockProvider    // Fixtures served without credentials
+	zipcodes     *geo.ZipcodeDB   // Centroids store distances are measured from
 }
 
 // WalmartAffiliateProduct represents a product from Walmart Affiliates Product Lookup API
 type WalmartAffiliateProduct struct {
-	ItemID              string  `json:"itemId"`
-	Name                string  `json:"name"`
-	SalePrice           float64 `json:"salePrice"`
-	MSRP                float64 `json:"msrp"`
-	UPC                 string  `json:"upc"`
-	Stock               string  `json:"stock"`
-	AvailableOnline     bool    `json:"availableOnline"`
-	ProductURL          string  `json:"productUrl"`
-	ShortDescription    string  `json:"shortDescription"`
-	SpecialBuy          bool    `json:"specialBuy"`
-	Clearance           bool    `json:"clearance"`
-	PreOrder            bool    `json:"preOrder"`
-	ShippingPassEligible bool   `json:"shippingPassEligible"`
+	ItemID               string  `json:"itemId"`
+	Name                 string  `json:"name"`
+	SalePrice            float64 `json:"salePrice"`
+	MSRP                 float64 `json:"msrp"`
+	UPC                  string  `json:"upc"`
+	Stock                string  `json:"stock"`
+	AvailableOnline      bool    `json:"availableOnline"`
+	ProductURL           string  `json:"productUrl"`
+	ShortDescription     string  `json:"shortDescription"`
+	SpecialBuy           bool    `json:"specialBuy"`
+	Clearance            bool    `json:"clearance"`
+	PreOrder             bool    `json:"preOrder"`
+	ShippingPassEligible bool    `json:"shippingPassEligible"`
+}
+
+// WalmartStore represents a store from the Walmart Affiliates Store Locator API.
+// The locator doesn't report opening hours, only whether a store opens on
+// Sundays, so Walmart store locations have no Hours.
+type WalmartStore struct {
+	No            int       `json:"no"`
+	Name          string    `json:"name"`
+	Coordinates   []float64 `json:"coordinates"` // [longitude, latitude]
+	StreetAddress string    `json:"streetAddress"`
+	City          string    `json:"city"`
+	StateProvCode string    `json:"stateProvCode"`
+	Zip           string    `json:"zip"`
+	PhoneNumber   string    `json:"phoneNumber"`
+	SundayOpen    bool      `json:"sundayOpen"`
+	Timezone      string    `json:"timezone"`
 }
 
 // WalmartAffiliateResponse represents the response from Walmart Affiliates API
 type WalmartAffiliateResponse struct {
-	Items      []WalmartAffiliateProduct `json:"items"`
-	TotalResults int                      `json:"totalResults"`
-	Start        int                      `json:"start"`
-	NumItems     int                      `json:"numItems"`
+	Items        []WalmartAffiliateProduct `json:"items"`
+	TotalResults int                       `json:"totalResults"`
+	Start        int                       `json:"start"`
+	NumItems     int                       `json:"numItems"`
 }
 
-func NewWalmartAPI() *WalmartAPI {
+func NewWalmartAPI(cfg config.Walmart, thirdParty config.ThirdParty, client *http.Client, chaos *Chaos, mock *MockProvider, zipcodes *geo.ZipcodeDB) *WalmartAPI {
 	return &WalmartAPI{
-		affiliateID: os.Getenv("WALMART_AFFILIATE_ID"),
-		apiKey:      os.Getenv("WALMART_API_KEY"),
-		client:      &http.Client{Timeout: 15 * time.Second},
+		affiliateID:  cfg.AffiliateID,
+		apiKey:       cfg.APIKey,
+		client:       client,
+		priceSources: NewPriceSourceChain("walmart", cfg.PriceSources, thirdParty, cfg.ApifyActor, client, chaos),
+		mock:         mock,
+		zipcodes:     zipcodes,
 	}
 }
 
 // GetEggPrice fetches egg prices using Walmart Affiliates Product Lookup API
 // This is the official 1P retail pricing API for price-comparison use cases
-func (w *WalmartAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error) {
-	if w.apiKey == "" {
-		// Return mock data for development
-		// Vary prices slightly based on zipcode for more realistic testing
-		zipcodeHash := 0
-		for _, c := range zipcode {
-			zipcodeHash += int(c)
-		}
-		
-		// Base price varies between $3.48 and $4.98
-		basePrice := 3.48 + float64(zipcodeHash%150)/100.0
-		
-		// Sometimes there's a promo (60% of the time)
-		var promoPrice *float64
-		var offers []*model.DigitalOffer
-		hasPromo := zipcodeHash%10 < 6
-		
-		finalPrice := basePrice
-		if hasPromo {
-			discount := 0.30 + float64(zipcodeHash%70)/100.0
-			promo := basePrice - discount
-			promoPrice = &promo
-			finalPrice = promo
-			
-			offers = append(offers, &model.DigitalOffer{
-				OfferID:        "WMT-PROMO-001",
-				Description:    "Rollback: Save on eggs",
-				DiscountAmount: floatPtr(discount),
-			})
+// When the Affiliates API is unavailable, the third-party price chain is used instead
+func (w *WalmartAPI) GetEggPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	if w.Mock() {
+		// Return fixture data for development (see api/fixtures)
+		return w.mock.GetEggPrice(ctx, "Walmart", zipcode)
+	}
+
+	if w.apiKey.Get() == "" {
+		return w.fetchThirdPartyPrice(ctx, zipcode)
+	}
+
+	price, err := w.fetchAffiliatePrice(ctx, zipcode)
+	if err != nil && len(w.priceSources) > 0 {
+		if fallback, fallbackErr := w.fetchThirdPartyPrice(ctx, zipcode); fallbackErr == nil {
+			return fallback, nil
 		}
-		
-		// Occasionally add a digital coupon (30% of the time)
-		if zipcodeHash%10 < 3 {
-			offers = append(offers, &model.DigitalOffer{
-				OfferID:        "WMT-DIGITAL-002",
-				Description:    "Digital Coupon: Extra $0.25 off",
-				DiscountAmount: floatPtr(0.25),
-			})
-			finalPrice -= 0.25
+	}
+	return price, err
+}
+
+// GetStorePrices returns egg prices for each Walmart store near the zipcode,
+// cheapest first. The Affiliates API only reports a national price, so every
+// store carries the same price and the store list adds location details.
+func (w *WalmartAPI) GetStorePrices(ctx context.Context, zipcode string, radiusMiles float64) ([]*model.RetailerPrice, error) {
+	price, err := w.GetEggPrice(ctx, zipcode)
+	if err != nil {
+		return nil, err
+	}
+
+	if w.Mock() {
+		return mockStorePrices(price, "Walmart Supercenter", "6:00 AM - 11:00 PM", radiusMiles), nil
+	}
+
+	stores, err := w.fetchStores(ctx, zipcode, radiusMiles)
+	if err != nil || len(stores) == 0 {
+		// Log error but fall back to the zip-level price
+		slog.WarnContext(ctx, "walmart: store lookup failed", "error", err)
+		return []*model.RetailerPrice{price}, nil
+	}
+
+	// The Store Locator API doesn't report distance, so measure it from the
+	// zipcode centroid when the zipcode is in the geodatabase
+	var origin *geo.Zipcode
+	if w.zipcodes != nil {
+		origin, _ = w.zipcodes.Lookup(zipcode)
+	}
+
+	var prices []*model.RetailerPrice
+	for _, store := range stores {
+		location := &model.StoreLocation{
+			StoreID: fmt.Sprintf("%d", store.No),
+			Name:    store.Name,
+			Address: store.StreetAddress,
+			City:    strPtr(store.City),
+			State:   strPtr(store.StateProvCode),
+			Zipcode: strPtr(store.Zip),
+			Phone:   strPtr(store.PhoneNumber),
 		}
-		
-		// Stock status varies (90% in stock)
-		inStock := zipcodeHash%10 != 0
-		pickupEta := "Available today"
-		if !inStock {
-			pickupEta = "Out of stock"
+		if len(store.Coordinates) == 2 {
+			location.Longitude = floatPtr(store.Coordinates[0])
+			location.Latitude = floatPtr(store.Coordinates[1])
+			if origin != nil {
+				location.DistanceMiles = floatPtr(geo.DistanceMiles(origin.Latitude, origin.Longitude, store.Coordinates[1], store.Coordinates[0]))
+			}
 		}
-		
-		productURL := "https://www.walmart.com/ip/Great-Value-Large-White-Eggs-12-Count/10450114"
-		
-		return &model.RetailerPrice{
-			Store:          "Walmart",
-			Sku:            strPtr("10450114"),
-			Upc:            strPtr("078742370842"),
-			StoreID:        nil,
-			Zipcode:        zipcode,
-			BasePrice:      basePrice,
-			PromoPrice:     promoPrice,
-			FinalPrice:     finalPrice,
-			ProductName:    "Great Value Large White Eggs, 12 Count",
-			ProductURL:     &productURL,
-			InStock:        inStock,
-			PickupEta:      strPtr(pickupEta),
-			DigitalOffers:  offers,
-			LastUpdated:    time.Now().Format(time.RFC3339),
-		}, nil
+		prices = append(prices, withStore(price, location))
 	}
 
+	if ranked := rankStores(prices, radiusMiles); len(ranked) > 0 {
+		return ranked, nil
+	}
+	// No store within the radius: serve the zip-level price, as Walgreens does
+	return []*model.RetailerPrice{price}, nil
+}
+
+// fetchStores calls the Walmart Affiliates Store Locator API
+// Documentation: https://walmart.io/docs/affiliates/v1/stores
+// The radius narrows the search upstream; rankStores still drops any store
+// measured outside it.
+func (w *WalmartAPI) fetchStores(ctx context.Context, zipcode string, radiusMiles float64) ([]WalmartStore, error) {
+	if w.apiKey.Get() == "" {
+		return nil, providerError("walmart", ErrAuthMisconfigured, fmt.Errorf("walmart API key not configured"))
+	}
+
+	params := url.Values{}
+	params.Add("zip", zipcode)
+	params.Add("radius", strconv.FormatFloat(radiusMiles, 'f', -1, 64))
+	params.Add("apiKey", w.apiKey.Get())
+	params.Add("format", "json")
+
+	var stores []WalmartStore
+	requestURL := fmt.Sprintf("https://developer.api.walmart.com/api-proxy/service/affil/v2/stores?%s", params.Encode())
+	if _, err := getJSON(ctx, w.client, "walmart", requestURL, &stores); err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+// Mock reports whether no Walmart data source is configured
+func (w *WalmartAPI) Mock() bool {
+	return w.apiKey.Get() == "" && len(w.priceSources) == 0
+}
+
+// ConfigError reports Walmart credentials that are only partly set
+func (w *WalmartAPI) ConfigError() error {
+	if w.affiliateID.Get() != "" && w.apiKey.Get() == "" {
+		return providerError("walmart", ErrAuthMisconfigured, fmt.Errorf("WALMART_AFFILIATE_ID is set without WALMART_API_KEY"))
+	}
+	return nil
+}
+
+// fetchAffiliatePrice looks up eggs with the Walmart Affiliates Product Lookup API
+func (w *WalmartAPI) fetchAffiliatePrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
 	// Walmart Affiliates Product Lookup API
 	// Documentation: https://developer.walmart.com/api/us/affil/product/v2
 	baseURL := "https://developer.api.walmart.com/api-proxy/service/affil/product/v2/search"
-	
+
 	params := url.Values{}
 	params.Add("query", "eggs dozen large white")
-	params.Add("apiKey", w.apiKey)
+	params.Add("apiKey", w.apiKey.Get())
 	params.Add("format", "json")
 	params.Add("numItems", "5") // Get top 5 results to find best match
-	
+
 	// Note: Walmart Affiliates API doesn't directly support zipcode filtering
 	// For store-specific pricing, you may need to use SearchAPI or similar service
 	// that provides zipcode-based Walmart pricing
-	
+
 	requestURL := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-	
-	req, err := http.NewRequest("GET", requestURL, nil)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", requestURL, nil)
 	if err != nil {
 		return nil, fmt.Errorf("walmart: failed to create request: %w", err)
 	}
 
 	resp, err := w.client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("walmart: request failed: %w", err)
+		return nil, providerError("walmart", ErrUpstreamUnavailable, fmt.Errorf("walmart: request failed: %w", err))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("walmart: API returned status %d: %s", resp.StatusCode, string(body))
+		return nil, statusError("walmart", resp.StatusCode, fmt.Errorf("walmart: API returned status %d: %s", resp.StatusCode, string(body)))
 	}
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("walmart: failed to read response: %w", err)
+		return nil, providerError("walmart", ErrUpstreamUnavailable, fmt.Errorf("walmart: failed to read response: %w", err))
 	}
+	fetchedAt := time.Now()
 
 	var walmartResp WalmartAffiliateResponse
 	if err := json.Unmarshal(body, &walmartResp); err != nil {
-		return nil, fmt.Errorf("walmart: failed to parse response: %w", err)
+		return nil, providerError("walmart", ErrUpstreamUnavailable, fmt.Errorf("walmart: failed to parse response: %w", err))
 	}
 
 	if len(walmartResp.Items) == 0 {
-		return nil, fmt.Errorf("walmart: no products found")
+		return nil, fmt.Errorf("walmart: %w", ErrNotFound)
 	}
 
 	// Find the best match (first available product)
 	product := walmartResp.Items[0]
-	
+
 	// Calculate final price (handle promos, clearance, special buy)
 	basePrice := product.MSRP
 	if basePrice == 0 {
@@ -178,11 +250,11 @@ func (w *WalmartAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error) {
 	}
 	finalPrice := product.SalePrice
 	var promoPrice *float64
-	
+
 	if product.SalePrice < basePrice {
 		promoPrice = &product.SalePrice
 	}
-	
+
 	// Build digital offers list
 	var offers []*model.DigitalOffer
 	if product.Clearance {
@@ -197,22 +269,71 @@ func (w *WalmartAPI) GetEggPrice(zipcode string) (*model.RetailerPrice, error) {
 			Description: "Special Buy",
 		})
 	}
-	
+
 	inStock := product.Stock == "Available" && product.AvailableOnline
-	
-	return &model.RetailerPrice{
+	stockLevel := stockLevelFromWalmart(product.Stock)
+	inStore := stockLevel != model.StockLevelOutOfStock
+
+	price := &model.RetailerPrice{
+		Store:         "Walmart",
+		Sku:           &product.ItemID,
+		Upc:           &product.UPC,
+		Zipcode:       zipcode,
+		BasePrice:     basePrice,
+		PromoPrice:    promoPrice,
+		FinalPrice:    finalPrice,
+		ProductName:   product.Name,
+		ProductURL:    &product.ProductURL,
+		InStock:       inStock,
+		PickupEta:     strPtr("Check store availability"),
+		DigitalOffers: offers,
+		Availability: &model.Availability{
+			InStore:    inStore,
+			Pickup:     inStore,
+			Shipping:   product.AvailableOnline,
+			StockLevel: stockLevel,
+		},
+		LastUpdated: time.Now().Format(time.RFC3339),
+	}
+	setProvenance(price, SourceWalmartAffiliates, providerRequestID(resp.Header), fetchedAt)
+	return price, nil
+}
+
+// fetchThirdPartyPrice gets Walmart pricing from the configured provider chain
+func (w *WalmartAPI) fetchThirdPartyPrice(ctx context.Context, zipcode string) (*model.RetailerPrice, error) {
+	priceData, err := w.priceSources.FetchPrice(ctx, "walmart", zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("walmart: failed to fetch price data: %w", err)
+	}
+
+	basePrice := priceData.RegularPrice
+	if basePrice == 0 {
+		basePrice = priceData.Price
+	}
+	var promoPrice *float64
+	if priceData.OnSale {
+		promoPrice = &priceData.Price
+	}
+
+	price := &model.RetailerPrice{
 		Store:       "Walmart",
-		Sku:         &product.ItemID,
-		Upc:         &product.UPC,
+		Sku:         &priceData.SKU,
+		Upc:         &priceData.UPC,
 		Zipcode:     zipcode,
 		BasePrice:   basePrice,
 		PromoPrice:  promoPrice,
-		FinalPrice:  finalPrice,
-		ProductName: product.Name,
-		ProductURL:  &product.ProductURL,
-		InStock:     inStock,
+		FinalPrice:  priceData.Price,
+		ProductName: priceData.ProductName,
+		ProductURL:  &priceData.ProductURL,
+		InStock:     priceData.Available,
 		PickupEta:   strPtr("Check store availability"),
-		DigitalOffers: offers,
+		Availability: &model.Availability{
+			InStore:    priceData.Available,
+			Pickup:     priceData.Available,
+			StockLevel: thirdPartyStockLevel(priceData.Available),
+		},
 		LastUpdated: time.Now().Format(time.RFC3339),
-	}, nil
+	}
+	setProvenance(price, priceData.Source, priceData.RequestID, priceData.FetchedAt)
+	return price, nil
 }
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+func TestWalmartReplay(t *testing.T) {
+	cfg := config.Walmart{AffiliateID: replayKey, APIKey: replayKey}
+	walmart := NewWalmartAPI(cfg, config.ThirdParty{}, replayClient(t, "walmart-affiliates.yaml"), nil, nil, zipcodeDB(t))
+
+	prices, err := walmart.GetStorePrices(context.Background(), "94102", DefaultRadiusMiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// San Leandro and Pittsburg are more than 10 miles from the 94102 centroid
+	if len(prices) != 1 {
+		t.Fatalf("got %d stores, want 1 within %g miles", len(prices), DefaultRadiusMiles)
+	}
+	checkPrice(t, prices[0], "Walmart", parsedPrice{
+		Sku:         "10450114",
+		Upc:         "078742370842",
+		StoreID:     "2486",
+		BasePrice:   4.27,
+		PromoPrice:  3.88,
+		FinalPrice:  3.88,
+		ProductName: "Great Value Large White Eggs, 12 Count",
+		ProductURL:  "https://www.walmart.com/ip/Great-Value-Large-White-Eggs-12-Count/10450114",
+		InStock:     true,
+		PickupEta:   "Check store availability",
+		Source:      "walmart-affiliates",
+	})
+
+	location := prices[0].Location
+	if location == nil || location.Name != "San Francisco Store" || location.DistanceMiles == nil {
+		t.Fatalf("location = %+v, want San Francisco Store with a distance", location)
+	}
+	if d := *location.DistanceMiles; d < 0.9 || d > 1.1 {
+		t.Errorf("distance = %.2f miles, want about 1 mile", d)
+	}
+	// The Store Locator API doesn't report opening hours
+	if location.Hours != nil {
+		t.Errorf("hours = %q, want none from the Store Locator API", *location.Hours)
+	}
+}
+
+// Stores the locator returns outside the radius are dropped
+func TestWalmartStorePricesOutsideRadius(t *testing.T) {
+	cfg := config.Walmart{AffiliateID: replayKey, APIKey: replayKey}
+	walmart := NewWalmartAPI(cfg, config.ThirdParty{}, replayClient(t, "walmart-affiliates-small-radius.yaml"), nil, nil, zipcodeDB(t))
+
+	prices, err := walmart.GetStorePrices(context.Background(), "94102", 0.1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prices) != 1 {
+		t.Fatalf("got %d prices, want the zip-level price alone", len(prices))
+	}
+	if p := prices[0]; p.StoreID != nil || p.FinalPrice != 3.88 {
+		t.Errorf("got store %v at $%.2f, want the zip-level price of $3.88", p.StoreID, p.FinalPrice)
+	}
+}
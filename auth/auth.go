@@ -0,0 +1,145 @@
+// Package auth authenticates API clients by key and enforces each key's
+// scopes, per-minute rate limit and daily quota. Keys are random tokens
+// shown once at creation; only their SHA-256 hash is stored.
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+// Scope grants access to a group of operations
+type Scope string
+
+const (
+	// ScopeRead allows the price, history and location queries
+	ScopeRead Scope = "read"
+	// ScopeAdmin allows managing keys and reading the config dump, and
+	// implies ScopeRead
+	ScopeAdmin Scope = "admin"
+)
+
+// keyPrefix marks egg-price-compare keys so leaked ones are easy to grep for
+const keyPrefix = "epc_"
+
+var (
+	// ErrUnauthenticated means no valid key was presented
+	ErrUnauthenticated = errors.New("a valid API key is required")
+	// ErrForbidden means the key lacks the scope an operation needs
+	ErrForbidden = errors.New("API key lacks the required scope")
+	// ErrRateLimited means the key exceeded its per-minute limit
+	ErrRateLimited = errors.New("API key rate limit exceeded")
+	// ErrQuotaExceeded means the key used up its daily quota
+	ErrQuotaExceeded = errors.New("API key daily quota exceeded")
+	// ErrKeyNotFound means no key has the given ID
+	ErrKeyNotFound = errors.New("API key not found")
+)
+
+// Key is an issued API key. The plaintext is never stored.
+type Key struct {
+	ID     string
+	Name   string
+	Hash   string // hex SHA-256 of the plaintext key
+	Prefix string // first characters of the plaintext, to tell keys apart
+	Scopes []Scope
+	// Zero means unlimited
+	RateLimitPerMinute int
+	DailyQuota         int
+	CreatedAt          time.Time
+	RevokedAt          *time.Time
+	ExpiresAt          *time.Time // nil never expires
+}
+
+// HasScope reports whether the key grants scope
+func (k *Key) HasScope(scope Scope) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
+
+// Revoked reports whether the key has been revoked
+func (k *Key) Revoked() bool {
+	return k.RevokedAt != nil
+}
+
+// Expired reports whether the key's expiry has passed at now
+func (k *Key) Expired(now time.Time) bool {
+	return k.ExpiresAt != nil && !now.Before(*k.ExpiresAt)
+}
+
+// NewKey generates a key and returns it with its plaintext, which the caller
+// must hand to the client now: it can't be recovered later
+func NewKey(name string, scopes []Scope, rateLimitPerMinute, dailyQuota int) (*Key, string, error) {
+	secret := make([]byte, 24)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", err
+	}
+
+	plaintext := keyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+	return &Key{
+		ID:                 hex.EncodeToString(id),
+		Name:               name,
+		Hash:               HashKey(plaintext),
+		Prefix:             plaintext[:len(keyPrefix)+6],
+		Scopes:             scopes,
+		RateLimitPerMinute: rateLimitPerMinute,
+		DailyQuota:         dailyQuota,
+		CreatedAt:          time.Now().UTC(),
+	}, plaintext, nil
+}
+
+// HashKey returns the stored form of a plaintext key. Keys are long random
+// tokens, so a fast hash is enough; there is nothing to brute-force.
+func HashKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+type keyContextKey struct{}
+
+// WithKey returns ctx carrying the authenticated key
+func WithKey(ctx context.Context, key *Key) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// FromContext returns the authenticated key, or nil for anonymous requests
+func FromContext(ctx context.Context) *Key {
+	key, _ := ctx.Value(keyContextKey{}).(*Key)
+	return key
+}
+
+// RequireScope returns ErrUnauthenticated for anonymous requests and
+// ErrForbidden when the request's key lacks scope. The read operations,
+// which anonymous requests may reach when keys aren't required, use
+// RequireRead instead.
+func RequireScope(ctx context.Context, scope Scope) error {
+	key := FromContext(ctx)
+	if key == nil {
+		return ErrUnauthenticated
+	}
+	if !key.HasScope(scope) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+// RequireRead checks ScopeRead but, like Authenticator.Middleware, lets
+// requests without a key through unless required is set
+func RequireRead(ctx context.Context, required bool) error {
+	if FromContext(ctx) == nil && !required {
+		return nil
+	}
+	return RequireScope(ctx, ScopeRead)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewKey(t *testing.T) {
+	key, plaintext, err := NewKey("grafana", []Scope{ScopeRead}, 30, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(plaintext, keyPrefix) || len(plaintext) != len(keyPrefix)+32 {
+		t.Errorf("plaintext = %q, want %s and 32 base64 characters", plaintext, keyPrefix)
+	}
+	if key.Hash != HashKey(plaintext) || strings.Contains(key.Hash, plaintext) {
+		t.Errorf("hash = %q, want the SHA-256 of the plaintext", key.Hash)
+	}
+	if !strings.HasPrefix(plaintext, key.Prefix) || len(key.Prefix) != len(keyPrefix)+6 {
+		t.Errorf("prefix = %q, want the first %d characters of the plaintext", key.Prefix, len(keyPrefix)+6)
+	}
+	if len(key.ID) != 16 || key.RateLimitPerMinute != 30 || key.DailyQuota != 5000 || key.Revoked() || key.ExpiresAt != nil {
+		t.Errorf("key = %+v", key)
+	}
+
+	other, otherPlaintext, err := NewKey("grafana", []Scope{ScopeRead}, 30, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.ID == key.ID || otherPlaintext == plaintext {
+		t.Error("two keys share an ID or plaintext")
+	}
+}
+
+// Stored hashes must stay stable, or every issued key stops working
+func TestHashKey(t *testing.T) {
+	if got, want := HashKey("epc_test"), "0fd10a3ca6497c1056b5a16b3b4686e85d37ac7b4ef46b121aa11e357727e913"; got != want {
+		t.Errorf("HashKey = %q, want hex SHA-256 %q", got, want)
+	}
+}
+
+func TestPresentedKey(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"none", nil, ""},
+		{"api key header", map[string]string{APIKeyHeader: "epc_a"}, "epc_a"},
+		{"bearer", map[string]string{"Authorization": "Bearer  epc_b "}, "epc_b"},
+		{"header wins", map[string]string{APIKeyHeader: "epc_a", "Authorization": "Bearer epc_b"}, "epc_a"},
+		{"basic auth", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, ""},
+		{"lowercase scheme", map[string]string{"Authorization": "bearer epc_b"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("POST", "/graphql", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := presentedKey(r); got != tt.want {
+				t.Errorf("presentedKey = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyExpired(t *testing.T) {
+	now := time.Now()
+	past, future := now.Add(-time.Second), now.Add(time.Hour)
+	for _, tt := range []struct {
+		expiresAt *time.Time
+		want      bool
+	}{
+		{nil, false},
+		{&past, true},
+		{&now, true},
+		{&future, false},
+	} {
+		if got := (&Key{ExpiresAt: tt.expiresAt}).Expired(now); got != tt.want {
+			t.Errorf("Expired with expiry %v = %t, want %t", tt.expiresAt, got, tt.want)
+		}
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	admin := WithKey(context.Background(), &Key{ID: "admin", Scopes: []Scope{ScopeAdmin}})
+	reader := WithKey(context.Background(), &Key{ID: "reader", Scopes: []Scope{ScopeRead}})
+	anonymous := context.Background()
+
+	tests := []struct {
+		name  string
+		ctx   context.Context
+		scope Scope
+		want  error
+	}{
+		{"admin key, admin scope", admin, ScopeAdmin, nil},
+		{"admin key implies read", admin, ScopeRead, nil},
+		{"read key, read scope", reader, ScopeRead, nil},
+		{"read key, admin scope", reader, ScopeAdmin, ErrForbidden},
+		{"anonymous", anonymous, ScopeRead, ErrUnauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RequireScope(tt.ctx, tt.scope); !errors.Is(err, tt.want) {
+				t.Errorf("RequireScope = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// Anonymous reads are refused only when keys are required; a presented key
+// needs the scope either way
+func TestRequireRead(t *testing.T) {
+	anonymous := context.Background()
+	unscoped := WithKey(context.Background(), &Key{ID: "unscoped"})
+	if err := RequireRead(anonymous, false); err != nil {
+		t.Errorf("anonymous, keys optional: %v", err)
+	}
+	if err := RequireRead(anonymous, true); !errors.Is(err, ErrUnauthenticated) {
+		t.Errorf("anonymous, keys required: %v, want ErrUnauthenticated", err)
+	}
+	if err := RequireRead(unscoped, false); !errors.Is(err, ErrForbidden) {
+		t.Errorf("key without READ: %v, want ErrForbidden", err)
+	}
+}
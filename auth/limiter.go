@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Decision is the outcome of counting one request against a key's limits
+type Decision struct {
+	Allowed bool
+	// Err is ErrRateLimited or ErrQuotaExceeded when the request is refused
+	Err error
+	// RetryAfter is how long until the exhausted window resets
+	RetryAfter time.Duration
+}
+
+// Limiter counts requests per key in fixed windows: the current minute and
+// the current UTC day. Refused requests are not counted.
+type Limiter interface {
+	// Allow counts a request for keyID against perMinute and perDay; zero
+	// disables a limit
+	Allow(ctx context.Context, keyID string, perMinute, perDay int) (Decision, error)
+}
+
+type windowCounts struct {
+	minute      time.Time
+	minuteCount int
+	day         time.Time
+	dayCount    int
+}
+
+// MemoryLimiter counts in process memory, so each replica enforces the
+// limits separately
+type MemoryLimiter struct {
+	mu     sync.Mutex
+	counts map[string]*windowCounts
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{counts: map[string]*windowCounts{}}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, keyID string, perMinute, perDay int) (Decision, error) {
+	now := time.Now().UTC()
+	minute := now.Truncate(time.Minute)
+	day := now.Truncate(24 * time.Hour)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	c, ok := l.counts[keyID]
+	if !ok {
+		c = &windowCounts{}
+		l.counts[keyID] = c
+	}
+	if !c.minute.Equal(minute) {
+		c.minute, c.minuteCount = minute, 0
+	}
+	if !c.day.Equal(day) {
+		c.day, c.dayCount = day, 0
+	}
+
+	if perDay > 0 && c.dayCount >= perDay {
+		return Decision{Err: ErrQuotaExceeded, RetryAfter: day.Add(24 * time.Hour).Sub(now)}, nil
+	}
+	if perMinute > 0 && c.minuteCount >= perMinute {
+		return Decision{Err: ErrRateLimited, RetryAfter: minute.Add(time.Minute).Sub(now)}, nil
+	}
+	c.minuteCount++
+	c.dayCount++
+	return Decision{Allowed: true}, nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryLimiterPerMinute(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	ctx := context.Background()
+	awayFromMinuteEnd(t)
+
+	for i := 0; i < 3; i++ {
+		if d, err := limiter.Allow(ctx, "key", 3, 0); err != nil || !d.Allowed {
+			t.Fatalf("request %d: %+v, %v", i+1, d, err)
+		}
+	}
+	d, err := limiter.Allow(ctx, "key", 3, 0)
+	if err != nil || d.Allowed || !errors.Is(d.Err, ErrRateLimited) {
+		t.Fatalf("4th request: %+v, %v; want ErrRateLimited", d, err)
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want the rest of the minute", d.RetryAfter)
+	}
+
+	if d, err := limiter.Allow(ctx, "other", 3, 0); err != nil || !d.Allowed {
+		t.Errorf("other key: %+v, %v", d, err)
+	}
+}
+
+// The daily quota is checked before the per-minute limit, and refused
+// requests don't count against either
+func TestMemoryLimiterDailyQuota(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	ctx := context.Background()
+	awayFromMinuteEnd(t)
+
+	for i := 0; i < 2; i++ {
+		if d, _ := limiter.Allow(ctx, "key", 5, 2); !d.Allowed {
+			t.Fatalf("request %d refused: %+v", i+1, d)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		d, _ := limiter.Allow(ctx, "key", 5, 2)
+		if d.Allowed || !errors.Is(d.Err, ErrQuotaExceeded) {
+			t.Fatalf("request %d over quota: %+v, want ErrQuotaExceeded", i+3, d)
+		}
+		if d.RetryAfter <= 0 || d.RetryAfter > 24*time.Hour {
+			t.Errorf("RetryAfter = %s, want the rest of the UTC day", d.RetryAfter)
+		}
+	}
+	if c := limiter.counts["key"]; c.minuteCount != 2 || c.dayCount != 2 {
+		t.Errorf("counted %d this minute and %d today, want refused requests left out", c.minuteCount, c.dayCount)
+	}
+}
+
+func TestMemoryLimiterUnlimited(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	for i := 0; i < 1000; i++ {
+		if d, err := limiter.Allow(context.Background(), "key", 0, 0); err != nil || !d.Allowed {
+			t.Fatalf("request %d: %+v, %v", i+1, d, err)
+		}
+	}
+}
+
+// A new minute resets the per-minute count but not the day's
+func TestMemoryLimiterWindowReset(t *testing.T) {
+	limiter := NewMemoryLimiter()
+	ctx := context.Background()
+	awayFromMinuteEnd(t)
+	limiter.Allow(ctx, "key", 1, 10)
+	c := limiter.counts["key"]
+	c.minute = c.minute.Add(-time.Minute)
+
+	if d, _ := limiter.Allow(ctx, "key", 1, 10); !d.Allowed {
+		t.Fatalf("first request of a new minute refused: %+v", d)
+	}
+	if c.minuteCount != 1 || c.dayCount != 2 {
+		t.Errorf("counts = %d/min, %d/day; want 1 and 2", c.minuteCount, c.dayCount)
+	}
+}
@@ -0,0 +1,136 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// APIKeyHeader carries the key; "Authorization: Bearer <key>" works too
+const APIKeyHeader = "X-API-Key"
+
+// adminTokenKey stands for the bootstrap ADMIN_TOKEN
+var adminTokenKey = &Key{ID: "admin-token", Name: "ADMIN_TOKEN", Scopes: []Scope{ScopeAdmin}}
+
+// Authenticator checks API keys on incoming requests
+type Authenticator struct {
+	keys       Store
+	limiter    Limiter
+	required   bool
+	adminToken *config.Secret
+}
+
+func NewAuthenticator(cfg config.Auth, keys Store, limiter Limiter) *Authenticator {
+	return &Authenticator{
+		keys:       keys,
+		limiter:    limiter,
+		required:   cfg.Required,
+		adminToken: cfg.AdminToken,
+	}
+}
+
+// Middleware authenticates the request's key, counts it against the key's
+// limits and stores it in the request context. Requests without a key pass
+// through anonymously unless auth is required; an invalid, revoked or
+// expired key is always refused.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plaintext := presentedKey(r)
+		if plaintext == "" {
+			if a.required {
+				writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", ErrUnauthenticated.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		key, err := a.authenticate(r, plaintext)
+		if err != nil {
+			if errors.Is(err, ErrUnauthenticated) {
+				writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", err.Error())
+				return
+			}
+			slog.ErrorContext(r.Context(), "auth: key lookup failed", "error", err)
+			writeError(w, http.StatusServiceUnavailable, "INTERNAL_SERVER_ERROR", "Authentication is temporarily unavailable")
+			return
+		}
+
+		decision, err := a.limiter.Allow(r.Context(), key.ID, key.RateLimitPerMinute, key.DailyQuota)
+		if err != nil {
+			// Better to serve a few requests over quota than none at all
+			slog.WarnContext(r.Context(), "auth: rate limiter unavailable, allowing request", "key_id", key.ID, "error", err)
+		} else if !decision.Allowed {
+			code := "RATE_LIMITED"
+			if errors.Is(decision.Err, ErrQuotaExceeded) {
+				code = "QUOTA_EXCEEDED"
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(decision.RetryAfter.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, code, decision.Err.Error())
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithKey(r.Context(), key)))
+	})
+}
+
+// RequireScope serves next only to requests whose key, set by Middleware,
+// grants scope
+func (a *Authenticator) RequireScope(scope Scope, next http.Handler) http.Handler {
+	return a.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch err := RequireScope(r.Context(), scope); {
+		case errors.Is(err, ErrUnauthenticated):
+			writeError(w, http.StatusUnauthorized, "UNAUTHENTICATED", err.Error())
+		case err != nil:
+			writeError(w, http.StatusForbidden, "FORBIDDEN", err.Error())
+		default:
+			next.ServeHTTP(w, r)
+		}
+	}))
+}
+
+// authenticate resolves a plaintext key to a live Key
+func (a *Authenticator) authenticate(r *http.Request, plaintext string) (*Key, error) {
+	hash := HashKey(plaintext)
+	if token := a.adminToken.Get(); token != "" && subtle.ConstantTimeCompare([]byte(hash), []byte(HashKey(token))) == 1 {
+		return adminTokenKey, nil
+	}
+
+	key, err := a.keys.LookupHash(r.Context(), hash)
+	if errors.Is(err, ErrKeyNotFound) || (err == nil && (key.Revoked() || key.Expired(time.Now()))) {
+		return nil, ErrUnauthenticated
+	}
+	return key, err
+}
+
+// presentedKey reads the key from X-API-Key or a bearer token
+func presentedKey(r *http.Request) string {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return key
+	}
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// writeError answers in the GraphQL error shape so clients handle auth
+// failures like any other coded error
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    message,
+			"extensions": map[string]string{"code": code},
+		}},
+	})
+}
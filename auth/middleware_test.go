@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// issue stores a key with the given scopes and limits and returns its plaintext
+func issue(t *testing.T, store Store, scopes []Scope, perMinute, perDay int) (*Key, string) {
+	t.Helper()
+	key, plaintext, err := NewKey("test", scopes, perMinute, perDay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Create(context.Background(), key); err != nil {
+		t.Fatal(err)
+	}
+	return key, plaintext
+}
+
+// echoKey answers with the ID of the key in the request context, or
+// "anonymous"
+var echoKey = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	if key := FromContext(r.Context()); key != nil {
+		w.Write([]byte(key.ID))
+		return
+	}
+	w.Write([]byte("anonymous"))
+})
+
+// serve sends a request with plaintext, if any, in X-API-Key
+func serve(h http.Handler, plaintext string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", nil)
+	if plaintext != "" {
+		req.Header.Set(APIKeyHeader, plaintext)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+// errorCode returns extensions.code from a GraphQL-shaped error body
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Errors []struct {
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || len(body.Errors) != 1 {
+		t.Fatalf("body %q is not one GraphQL error: %v", rec.Body, err)
+	}
+	return body.Errors[0].Extensions["code"]
+}
+
+func TestAuthenticatorRejectsKeys(t *testing.T) {
+	store := NewMemoryStore()
+	revoked, revokedPlaintext := issue(t, store, []Scope{ScopeRead}, 0, 0)
+	if _, err := store.Revoke(context.Background(), revoked.ID); err != nil {
+		t.Fatal(err)
+	}
+	expired, expiredPlaintext, err := NewKey("expired", []Scope{ScopeRead}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yesterday := time.Now().Add(-24 * time.Hour)
+	expired.ExpiresAt = &yesterday
+	store.Create(context.Background(), expired)
+
+	authn := NewAuthenticator(config.Auth{Required: true}, store, NewMemoryLimiter())
+	h := authn.Middleware(echoKey)
+	tests := map[string]string{
+		"missing": "",
+		"bad":     "epc_not-a-key",
+		"revoked": revokedPlaintext,
+		"expired": expiredPlaintext,
+	}
+	for name, plaintext := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := serve(h, plaintext)
+			if rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "UNAUTHENTICATED" {
+				t.Errorf("got %d %s, want 401 UNAUTHENTICATED", rec.Code, rec.Body)
+			}
+		})
+	}
+}
+
+func TestAuthenticatorAcceptsKeys(t *testing.T) {
+	store := NewMemoryStore()
+	key, plaintext := issue(t, store, []Scope{ScopeRead}, 0, 0)
+	adminToken := config.NewSecret("bootstrap-admin-token")
+
+	// Keys are optional here: anonymous requests pass, keys are still checked
+	h := NewAuthenticator(config.Auth{AdminToken: adminToken}, store, NewMemoryLimiter()).Middleware(echoKey)
+	for plaintext, want := range map[string]string{
+		plaintext:               key.ID,
+		"bootstrap-admin-token": adminTokenKey.ID,
+		"":                      "anonymous",
+	} {
+		if rec := serve(h, plaintext); rec.Code != http.StatusOK || rec.Body.String() != want {
+			t.Errorf("got %d %q, want 200 %q", rec.Code, rec.Body, want)
+		}
+	}
+	if rec := serve(h, "epc_not-a-key"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("a bad key with keys optional got %d, want 401", rec.Code)
+	}
+}
+
+func TestAuthenticatorRateLimits(t *testing.T) {
+	store := NewMemoryStore()
+	_, perMinute := issue(t, store, []Scope{ScopeRead}, 2, 0)
+	_, perDay := issue(t, store, []Scope{ScopeRead}, 0, 1)
+	h := NewAuthenticator(config.Auth{Required: true}, store, NewMemoryLimiter()).Middleware(echoKey)
+	awayFromMinuteEnd(t)
+
+	tests := []struct {
+		plaintext string
+		allowed   int
+		code      string
+		maxRetry  time.Duration
+	}{
+		{perMinute, 2, "RATE_LIMITED", time.Minute},
+		{perDay, 1, "QUOTA_EXCEEDED", 24 * time.Hour},
+	}
+	for _, tt := range tests {
+		for i := 0; i < tt.allowed; i++ {
+			if rec := serve(h, tt.plaintext); rec.Code != http.StatusOK {
+				t.Fatalf("request %d got %d, want 200", i+1, rec.Code)
+			}
+		}
+		rec := serve(h, tt.plaintext)
+		if rec.Code != http.StatusTooManyRequests || errorCode(t, rec) != tt.code {
+			t.Errorf("got %d %s, want 429 %s", rec.Code, rec.Body, tt.code)
+		}
+		retryAfter, err := strconv.Atoi(rec.Header().Get("Retry-After"))
+		if err != nil || retryAfter < 1 || time.Duration(retryAfter)*time.Second > tt.maxRetry {
+			t.Errorf("%s: Retry-After = %q, want whole seconds up to %s", tt.code, rec.Header().Get("Retry-After"), tt.maxRetry)
+		}
+	}
+}
+
+// The admin endpoints need the admin scope; a read key is authenticated
+// but forbidden
+func TestAuthenticatorRequireScope(t *testing.T) {
+	store := NewMemoryStore()
+	admin, adminPlaintext := issue(t, store, []Scope{ScopeAdmin}, 0, 0)
+	_, readPlaintext := issue(t, store, []Scope{ScopeRead}, 0, 0)
+	h := NewAuthenticator(config.Auth{}, store, NewMemoryLimiter()).RequireScope(ScopeAdmin, echoKey)
+
+	if rec := serve(h, adminPlaintext); rec.Code != http.StatusOK || rec.Body.String() != admin.ID {
+		t.Errorf("admin key got %d %q, want 200 %q", rec.Code, rec.Body, admin.ID)
+	}
+	if rec := serve(h, readPlaintext); rec.Code != http.StatusForbidden || errorCode(t, rec) != "FORBIDDEN" {
+		t.Errorf("read key got %d %s, want 403 FORBIDDEN", rec.Code, rec.Body)
+	}
+	// Even with keys optional, the admin scope needs a key
+	if rec := serve(h, ""); rec.Code != http.StatusUnauthorized || errorCode(t, rec) != "UNAUTHENTICATED" {
+		t.Errorf("no key got %d %s, want 401 UNAUTHENTICATED", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps keys in the api_keys table, so they survive restarts
+// and every replica accepts the same keys. The pool is shared; close it
+// separately.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+const keyColumns = `id, name, hash, prefix, scopes, rate_limit_per_minute, daily_quota, created_at, revoked_at, expires_at`
+
+func (s *PostgresStore) Create(ctx context.Context, key *Key) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO api_keys (`+keyColumns+`) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		key.ID, key.Name, key.Hash, key.Prefix, scopeStrings(key.Scopes),
+		key.RateLimitPerMinute, key.DailyQuota, key.CreatedAt, key.RevokedAt, key.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("auth: failed to store key: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) LookupHash(ctx context.Context, hash string) (*Key, error) {
+	key, err := scanKey(s.pool.QueryRow(ctx, `SELECT `+keyColumns+` FROM api_keys WHERE hash = $1`, hash))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: key lookup failed: %w", err)
+	}
+	return key, nil
+}
+
+func (s *PostgresStore) Revoke(ctx context.Context, id string) (*Key, error) {
+	// Revoking twice keeps the first revocation time, as the memory store does
+	key, err := scanKey(s.pool.QueryRow(ctx,
+		`UPDATE api_keys SET revoked_at = coalesce(revoked_at, now()) WHERE id = $1 RETURNING `+keyColumns, id))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to revoke key: %w", err)
+	}
+	return key, nil
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]*Key, error) {
+	rows, err := s.pool.Query(ctx, `SELECT `+keyColumns+` FROM api_keys ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, fmt.Errorf("auth: failed to list keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*Key{}
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("auth: failed to list keys: %w", err)
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("auth: failed to list keys: %w", err)
+	}
+	return keys, nil
+}
+
+func scanKey(row pgx.Row) (*Key, error) {
+	var (
+		key    Key
+		scopes []string
+	)
+	err := row.Scan(&key.ID, &key.Name, &key.Hash, &key.Prefix, &scopes,
+		&key.RateLimitPerMinute, &key.DailyQuota, &key.CreatedAt, &key.RevokedAt, &key.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scopes {
+		key.Scopes = append(key.Scopes, Scope(s))
+	}
+	key.CreatedAt = key.CreatedAt.UTC()
+	if key.RevokedAt != nil {
+		revokedAt := key.RevokedAt.UTC()
+		key.RevokedAt = &revokedAt
+	}
+	if key.ExpiresAt != nil {
+		expiresAt := key.ExpiresAt.UTC()
+		key.ExpiresAt = &expiresAt
+	}
+	return &key, nil
+}
+
+func scopeStrings(scopes []Scope) []string {
+	s := make([]string, len(scopes))
+	for i, scope := range scopes {
+		s[i] = string(scope)
+	}
+	return s
+}
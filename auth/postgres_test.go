@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+)
+
+func TestPostgresStore(t *testing.T) {
+	store := NewPostgresStore(dbtest.Open(t))
+	ctx := context.Background()
+
+	older, _, err := NewKey("grafana", []Scope{ScopeRead}, 30, 5000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	older.CreatedAt = older.CreatedAt.Add(-time.Hour)
+	expiry := time.Now().UTC().Add(24 * time.Hour).Truncate(time.Second)
+	older.ExpiresAt = &expiry
+	newer, plaintext, err := NewKey("admin", []Scope{ScopeAdmin}, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []*Key{older, newer} {
+		if err := store.Create(ctx, key); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	found, err := store.LookupHash(ctx, HashKey(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != newer.ID || found.Name != "admin" || !found.HasScope(ScopeAdmin) || found.RevokedAt != nil {
+		t.Errorf("LookupHash = %+v, want the admin key", found)
+	}
+	if _, err := store.LookupHash(ctx, HashKey("unknown")); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("LookupHash of an unknown key = %v, want ErrKeyNotFound", err)
+	}
+
+	keys, err := store.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != newer.ID || keys[1].ID != older.ID {
+		t.Errorf("List returned %d keys, want the admin key then the grafana key", len(keys))
+	}
+	if keys[1].RateLimitPerMinute != 30 || keys[1].DailyQuota != 5000 {
+		t.Errorf("limits = %d/min, %d/day; want 30 and 5000", keys[1].RateLimitPerMinute, keys[1].DailyQuota)
+	}
+	if keys[1].ExpiresAt == nil || !keys[1].ExpiresAt.Equal(expiry) || keys[0].ExpiresAt != nil {
+		t.Errorf("expiries = %v, %v; want %v and none", keys[1].ExpiresAt, keys[0].ExpiresAt, expiry)
+	}
+
+	revoked, err := store.Revoke(ctx, older.ID)
+	if err != nil || revoked.RevokedAt == nil {
+		t.Fatalf("Revoke = %+v, %v", revoked, err)
+	}
+	again, err := store.Revoke(ctx, older.ID)
+	if err != nil || !again.RevokedAt.Equal(*revoked.RevokedAt) {
+		t.Errorf("revoking twice moved the revocation time from %v to %v", revoked.RevokedAt, again.RevokedAt)
+	}
+	if _, err := store.Revoke(ctx, "unknown"); !errors.Is(err, ErrKeyNotFound) {
+		t.Errorf("Revoke of an unknown key = %v, want ErrKeyNotFound", err)
+	}
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLimitPrefix namespaces rate-limit counters in a shared Redis
+const redisLimitPrefix = "ratelimit:"
+
+// allowScript checks and counts a request atomically, so concurrent requests
+// on different replicas can't both take the last slot. KEYS are the minute
+// and day counters; ARGV are the limits (0 = none) and each counter's TTL in
+// seconds. Returns 0 if allowed, 1 if over the daily quota, 2 if over the
+// per-minute limit.
+var allowScript = redis.NewScript(`
+local minute = tonumber(redis.call("GET", KEYS[1]) or "0")
+local day = tonumber(redis.call("GET", KEYS[2]) or "0")
+local perMinute = tonumber(ARGV[1])
+local perDay = tonumber(ARGV[2])
+if perDay > 0 and day >= perDay then
+	return 1
+end
+if perMinute > 0 and minute >= perMinute then
+	return 2
+end
+redis.call("INCR", KEYS[1])
+redis.call("EXPIRE", KEYS[1], ARGV[3])
+redis.call("INCR", KEYS[2])
+redis.call("EXPIRE", KEYS[2], ARGV[4])
+return 0`)
+
+// RedisLimiter counts in Redis, so every replica enforces one shared limit
+// per key. Counters expire with their window.
+type RedisLimiter struct {
+	client *redis.Client
+}
+
+// NewRedisLimiter counts in client. The client is shared; close it
+// separately.
+func NewRedisLimiter(client *redis.Client) *RedisLimiter {
+	return &RedisLimiter{client: client}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, keyID string, perMinute, perDay int) (Decision, error) {
+	now := time.Now().UTC()
+	minute := now.Truncate(time.Minute)
+	day := now.Truncate(24 * time.Hour)
+	keys := []string{
+		redisLimitPrefix + keyID + ":minute:" + minute.Format("200601021504"),
+		redisLimitPrefix + keyID + ":day:" + day.Format("20060102"),
+	}
+	// Keep each counter a little past its window for clock skew between replicas
+	minuteTTL := int((2 * time.Minute).Seconds())
+	dayTTL := int((25 * time.Hour).Seconds())
+
+	result, err := allowScript.Run(ctx, l.client, keys, perMinute, perDay, minuteTTL, dayTTL).Int()
+	if err != nil {
+		return Decision{}, err
+	}
+	switch result {
+	case 1:
+		return Decision{Err: ErrQuotaExceeded, RetryAfter: day.Add(24 * time.Hour).Sub(now)}, nil
+	case 2:
+		return Decision{Err: ErrRateLimited, RetryAfter: minute.Add(time.Minute).Sub(now)}, nil
+	}
+	return Decision{Allowed: true}, nil
+}
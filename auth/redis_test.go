@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedisLimiter(t *testing.T) (*RedisLimiter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisLimiter(client), mr
+}
+
+// awayFromMinuteEnd waits out the end of the current minute, so a test's
+// requests all fall in one per-minute window
+func awayFromMinuteEnd(t *testing.T) {
+	t.Helper()
+	if left := time.Until(time.Now().Truncate(time.Minute).Add(time.Minute)); left < 2*time.Second {
+		time.Sleep(left)
+	}
+}
+
+func TestRedisLimiterPerMinute(t *testing.T) {
+	limiter, _ := newRedisLimiter(t)
+	ctx := context.Background()
+	awayFromMinuteEnd(t)
+
+	for i := 0; i < 3; i++ {
+		d, err := limiter.Allow(ctx, "key", 3, 0)
+		if err != nil || !d.Allowed {
+			t.Fatalf("request %d: %+v, %v", i+1, d, err)
+		}
+	}
+	d, err := limiter.Allow(ctx, "key", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || !errors.Is(d.Err, ErrRateLimited) {
+		t.Fatalf("4th request: %+v, want ErrRateLimited", d)
+	}
+	if d.RetryAfter <= 0 || d.RetryAfter > time.Minute {
+		t.Errorf("RetryAfter = %s, want the rest of the minute", d.RetryAfter)
+	}
+
+	// Other keys have their own counters
+	if d, err := limiter.Allow(ctx, "other", 3, 0); err != nil || !d.Allowed {
+		t.Errorf("other key: %+v, %v", d, err)
+	}
+}
+
+func TestRedisLimiterDailyQuota(t *testing.T) {
+	limiter, _ := newRedisLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if d, err := limiter.Allow(ctx, "key", 0, 2); err != nil || !d.Allowed {
+			t.Fatalf("request %d: %+v, %v", i+1, d, err)
+		}
+	}
+	d, err := limiter.Allow(ctx, "key", 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Allowed || !errors.Is(d.Err, ErrQuotaExceeded) {
+		t.Errorf("3rd request: %+v, want ErrQuotaExceeded", d)
+	}
+}
+
+// Replicas share the counters, so concurrent requests can't both take the
+// last slot
+func TestRedisLimiterLastSlot(t *testing.T) {
+	limiter, _ := newRedisLimiter(t)
+	awayFromMinuteEnd(t)
+
+	const limit, requests = 5, 50
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		allowed int
+	)
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := limiter.Allow(context.Background(), "key", limit, 0)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if d.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if allowed != limit {
+		t.Errorf("%d of %d concurrent requests allowed, want %d", allowed, requests, limit)
+	}
+}
+
+// Refused requests aren't counted, and counters expire after their window
+func TestRedisLimiterCounters(t *testing.T) {
+	limiter, mr := newRedisLimiter(t)
+	ctx := context.Background()
+	awayFromMinuteEnd(t)
+
+	limiter.Allow(ctx, "key", 1, 0)
+	limiter.Allow(ctx, "key", 1, 0)
+
+	minute := redisLimitPrefix + "key:minute:" + time.Now().UTC().Truncate(time.Minute).Format("200601021504")
+	if got, err := mr.Get(minute); err != nil || got != "1" {
+		t.Fatalf("minute counter = %q, %v; want 1", got, err)
+	}
+	if ttl := mr.TTL(minute); ttl <= time.Minute || ttl > 2*time.Minute {
+		t.Errorf("minute counter TTL = %s, want just over a minute", ttl)
+	}
+
+	mr.FastForward(2 * time.Minute)
+	if mr.Exists(minute) {
+		t.Error("minute counter outlived its TTL")
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store persists issued keys
+type Store interface {
+	Create(ctx context.Context, key *Key) error
+	// LookupHash returns the key with the given hash, revoked or not, or
+	// ErrKeyNotFound
+	LookupHash(ctx context.Context, hash string) (*Key, error)
+	// Revoke marks the key revoked and returns it, or ErrKeyNotFound
+	Revoke(ctx context.Context, id string) (*Key, error)
+	// List returns every key, newest first
+	List(ctx context.Context) ([]*Key, error)
+}
+
+// MemoryStore keeps keys in memory. Keys issued to it are lost on restart.
+type MemoryStore struct {
+	mu     sync.RWMutex
+	byID   map[string]*Key
+	byHash map[string]*Key
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{byID: map[string]*Key{}, byHash: map[string]*Key{}}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, key *Key) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored := *key
+	s.byID[key.ID] = &stored
+	s.byHash[key.Hash] = &stored
+	return nil
+}
+
+func (s *MemoryStore) LookupHash(ctx context.Context, hash string) (*Key, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.byHash[hash]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	found := *key
+	return &found, nil
+}
+
+func (s *MemoryStore) Revoke(ctx context.Context, id string) (*Key, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.byID[id]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now().UTC()
+		key.RevokedAt = &now
+	}
+	revoked := *key
+	return &revoked, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]*Key, error) {
+	s.mu.RLock()
+	keys := make([]*Key, 0, len(s.byID))
+	for _, key := range s.byID {
+		k := *key
+		keys = append(keys, &k)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys, nil
+}
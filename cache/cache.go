@@ -0,0 +1,109 @@
+// Package cache shares fetched data between requests and, with Redis,
+// between replicas: a Cache of values, Locks that let one caller fetch a
+// missing value while the others wait for it, and the Redis connection both
+// use.
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache stores values until they expire
+type Cache interface {
+	// Get returns the value for key and whether it was found
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+}
+
+// Locker grants short-lived exclusive locks on keys
+type Locker interface {
+	// TryLock takes the lock on key for at most ttl without waiting. The
+	// returned unlock releases it if it is still held; it is nil when the
+	// lock was not acquired.
+	TryLock(ctx context.Context, key string, ttl time.Duration) (unlock func(), err error)
+}
+
+// Open returns the cache and locker cfg names. rdb is the shared Redis
+// client, which is only used, and must only be non-nil, for the redis backend.
+func Open(cfg config.Cache, rdb *redis.Client) (Cache, Locker, error) {
+	switch strings.ToLower(cfg.Backend) {
+	case "memory":
+		// A Group already coalesces callers within the process
+		return NewMemoryCache(), nil, nil
+	case "redis":
+		if rdb == nil {
+			return nil, nil, fmt.Errorf("cache: the redis backend needs a redis client")
+		}
+		return NewRedisCache(rdb), NewRedisLocker(rdb), nil
+	default:
+		return nil, nil, fmt.Errorf("cache: unknown backend %q", cfg.Backend)
+	}
+}
+
+// maxMemoryEntries bounds a MemoryCache; past it, expired entries are
+// dropped and then arbitrary ones
+const maxMemoryEntries = 10000
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryCache keeps values in process memory, so each replica has its own
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]memoryEntry{}}
+}
+
+func (c *MemoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if time.Now().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false, nil
+	}
+	return e.value, true, nil
+}
+
+func (c *MemoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= maxMemoryEntries {
+		c.evict()
+	}
+	c.entries[key] = memoryEntry{value: value, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// evict makes room for one entry
+func (c *MemoryCache) evict() {
+	now := time.Now()
+	for key, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.entries {
+		if len(c.entries) < maxMemoryEntries {
+			return
+		}
+		delete(c.entries, key)
+	}
+}
@@ -0,0 +1,123 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/metrics"
+	"golang.org/x/sync/singleflight"
+)
+
+// pollInterval is how often a caller waiting on another replica's fetch
+// checks the cache
+const pollInterval = 100 * time.Millisecond
+
+// Group loads missing values at most once at a time per key. Callers in the
+// same process share one load; with a Locker, callers on other replicas
+// wait for the lock holder to fill the cache instead of loading too.
+//
+// The cache and locks fail open: when they are unreachable every caller
+// loads for itself, as if there were no cache.
+type Group struct {
+	name        string
+	cache       Cache
+	locks       Locker
+	lockTimeout time.Duration
+	flight      singleflight.Group
+}
+
+// NewGroup coalesces loads through c. name labels the group's cache metrics.
+// locks may be nil for a single replica. lockTimeout bounds how long a load
+// may hold its lock, and so how long other replicas wait on it.
+func NewGroup(name string, c Cache, locks Locker, lockTimeout time.Duration) *Group {
+	return &Group{name: name, cache: c, locks: locks, lockTimeout: lockTimeout}
+}
+
+// Do returns the cached value for key, or calls load, caches its result for
+// ttl and returns it. Errors are not cached. The shared load is not
+// cancelled when ctx is, since other callers may be waiting on it; ctx only
+// stops this caller waiting.
+func (g *Group) Do(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	key = g.name + ":" + key
+	if value, ok := g.get(ctx, key); ok {
+		metrics.ObserveCache(g.name, true)
+		return value, nil
+	}
+	metrics.ObserveCache(g.name, false)
+
+	ch := g.flight.DoChan(key, func() (interface{}, error) {
+		return g.load(context.WithoutCancel(ctx), key, ttl, load)
+	})
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		return res.Val.([]byte), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// load fills key under its lock, or waits for the replica holding the lock
+func (g *Group) load(ctx context.Context, key string, ttl time.Duration, load func(context.Context) ([]byte, error)) ([]byte, error) {
+	if g.locks != nil {
+		unlock, err := g.locks.TryLock(ctx, key, g.lockTimeout)
+		switch {
+		case err != nil:
+			slog.WarnContext(ctx, "cache: lock failed, loading without it", "cache", g.name, "key", key, "error", err)
+		case unlock == nil:
+			if value, ok := g.wait(ctx, key); ok {
+				return value, nil
+			}
+			// The holder failed or is too slow; load rather than keep waiting
+		default:
+			defer unlock()
+			// Another replica may have filled the cache since the lookup
+			if value, ok := g.get(ctx, key); ok {
+				return value, nil
+			}
+		}
+	}
+
+	// Finish within the lock's lifetime, so no other replica starts loading
+	// while this one still is
+	ctx, cancel := context.WithTimeout(ctx, g.lockTimeout)
+	defer cancel()
+	value, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := g.cache.Set(ctx, key, value, ttl); err != nil {
+		slog.WarnContext(ctx, "cache: store failed", "cache", g.name, "key", key, "error", err)
+	}
+	return value, nil
+}
+
+// wait polls the cache until another replica fills key or its lock expires
+func (g *Group) wait(ctx context.Context, key string) ([]byte, bool) {
+	ctx, cancel := context.WithTimeout(ctx, g.lockTimeout)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-ticker.C:
+			if value, ok := g.get(ctx, key); ok {
+				return value, true
+			}
+		}
+	}
+}
+
+func (g *Group) get(ctx context.Context, key string) ([]byte, bool) {
+	value, ok, err := g.cache.Get(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "cache: lookup failed", "cache", g.name, "key", key, "error", err)
+		return nil, false
+	}
+	return value, ok
+}
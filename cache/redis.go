@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/redis/go-redis/v9"
+	"github.com/redis/go-redis/v9/maintnotifications"
+)
+
+// OpenRedis connects to cfg.URL and checks the server answers. Close the
+// client on shutdown.
+func OpenRedis(ctx context.Context, cfg config.Redis) (*redis.Client, error) {
+	opts, err := redis.ParseURL(cfg.URL.Get())
+	if err != nil {
+		// The URL can hold a password, so it is left out of the error
+		return nil, fmt.Errorf("cache: invalid redis url")
+	}
+	// Redis sits in front of work the request can do without it, so give up
+	// quickly rather than stall every request while it is down. Settings in
+	// the URL's query, e.g. ?dial_timeout=5s, take precedence.
+	if opts.DialTimeout == 0 {
+		opts.DialTimeout = time.Second
+	}
+	if opts.ReadTimeout == 0 {
+		opts.ReadTimeout = time.Second
+	}
+	if opts.MaxRetries == 0 {
+		opts.MaxRetries = 1
+	}
+	if opts.DialerRetries == 0 {
+		opts.DialerRetries = 1
+	}
+	// Maintenance notifications are a Redis Cloud feature; probing for them
+	// logs a handshake error on every connection to other servers
+	opts.MaintNotificationsConfig = &maintnotifications.Config{Mode: maintnotifications.ModeDisabled}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("cache: failed to reach redis at %s: %w", opts.Addr, err)
+	}
+	return client, nil
+}
+
+// Keys are prefixed so the cache and locks can share a Redis with other
+// components
+const (
+	redisCachePrefix = "cache:"
+	redisLockPrefix  = "lock:"
+)
+
+// RedisCache stores values in Redis, shared by every replica
+type RedisCache struct {
+	client *redis.Client
+}
+
+// NewRedisCache stores values in client. The client is shared; close it
+// separately.
+func NewRedisCache(client *redis.Client) *RedisCache {
+	return &RedisCache{client: client}
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.client.Get(ctx, redisCachePrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.client.Set(ctx, redisCachePrefix+key, value, ttl).Err()
+}
+
+// RedisLocker takes locks with SET NX, so they expire on their own if the
+// holder dies
+type RedisLocker struct {
+	client *redis.Client
+}
+
+func NewRedisLocker(client *redis.Client) *RedisLocker {
+	return &RedisLocker{client: client}
+}
+
+// unlockScript deletes a lock only if it still holds this holder's token; a
+// lock that expired may since have been taken by someone else
+var unlockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+func (l *RedisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (func(), error) {
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return nil, err
+	}
+	value := hex.EncodeToString(token)
+
+	acquired, err := l.client.SetNX(ctx, redisLockPrefix+key, value, ttl).Result()
+	if err != nil || !acquired {
+		return nil, err
+	}
+	return func() {
+		// Unlock even if the load's context is done, or the lock is held
+		// until it expires
+		ctx := context.WithoutCancel(ctx)
+		if err := unlockScript.Run(ctx, l.client, []string{redisLockPrefix + key}, value).Err(); err != nil {
+			slog.WarnContext(ctx, "cache: unlock failed", "key", key, "error", err)
+		}
+	}, nil
+}
@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, mr
+}
+
+func TestRedisCache(t *testing.T) {
+	client, mr := newRedis(t)
+	c := NewRedisCache(client)
+	ctx := context.Background()
+
+	if _, ok, err := c.Get(ctx, "prices:walmart:94102"); ok || err != nil {
+		t.Fatalf("Get before Set = %t, %v; want a miss", ok, err)
+	}
+	if err := c.Set(ctx, "prices:walmart:94102", []byte("3.88"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	value, ok, err := c.Get(ctx, "prices:walmart:94102")
+	if err != nil || !ok || string(value) != "3.88" {
+		t.Fatalf("Get = %q, %t, %v", value, ok, err)
+	}
+
+	mr.FastForward(time.Minute)
+	if _, ok, err := c.Get(ctx, "prices:walmart:94102"); ok || err != nil {
+		t.Errorf("Get after the TTL = %t, %v; want a miss", ok, err)
+	}
+}
+
+// With Redis down every caller loads for itself, as if there were no cache
+func TestGroupFailsOpen(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	mr.Close()
+	group := NewGroup("prices", NewRedisCache(client), NewRedisLocker(client), time.Second)
+
+	value, err := group.Do(context.Background(), "key", time.Minute, func(context.Context) ([]byte, error) {
+		return []byte("loaded"), nil
+	})
+	if err != nil || string(value) != "loaded" {
+		t.Errorf("Do = %q, %v; want its own load", value, err)
+	}
+}
+
+func TestRedisLocker(t *testing.T) {
+	client, _ := newRedis(t)
+	locks := NewRedisLocker(client)
+	ctx := context.Background()
+
+	unlock, err := locks.TryLock(ctx, "key", time.Minute)
+	if err != nil || unlock == nil {
+		t.Fatalf("TryLock = %v, want the lock", err)
+	}
+	if again, err := locks.TryLock(ctx, "key", time.Minute); err != nil || again != nil {
+		t.Fatalf("second TryLock = %v, want the lock refused", err)
+	}
+	unlock()
+	if again, err := locks.TryLock(ctx, "key", time.Minute); err != nil || again == nil {
+		t.Fatalf("TryLock after unlock = %v, want the lock", err)
+	}
+}
+
+// A holder whose lock expired mustn't release the next holder's lock
+func TestRedisLockerExpiry(t *testing.T) {
+	client, mr := newRedis(t)
+	locks := NewRedisLocker(client)
+	ctx := context.Background()
+
+	stale, err := locks.TryLock(ctx, "key", time.Second)
+	if err != nil || stale == nil {
+		t.Fatalf("TryLock = %v, want the lock", err)
+	}
+	mr.FastForward(time.Second)
+
+	current, err := locks.TryLock(ctx, "key", time.Minute)
+	if err != nil || current == nil {
+		t.Fatalf("TryLock after expiry = %v, want the lock", err)
+	}
+	stale()
+	if !mr.Exists(redisLockPrefix + "key") {
+		t.Error("the expired holder's unlock released the current lock")
+	}
+}
+
+// A replica without the lock waits for the holder to fill the cache
+func TestGroupWaitsForLockHolder(t *testing.T) {
+	client, _ := newRedis(t)
+	holder := NewRedisLocker(client)
+	group := NewGroup("prices", NewRedisCache(client), NewRedisLocker(client), 5*time.Second)
+	ctx := context.Background()
+
+	unlock, err := holder.TryLock(ctx, "prices:key", 5*time.Second)
+	if err != nil || unlock == nil {
+		t.Fatalf("TryLock = %v", err)
+	}
+	go func() {
+		time.Sleep(2 * pollInterval)
+		NewRedisCache(client).Set(ctx, "prices:key", []byte("from holder"), time.Minute)
+		unlock()
+	}()
+
+	var loads atomic.Int32
+	value, err := group.Do(ctx, "key", time.Minute, func(context.Context) ([]byte, error) {
+		loads.Add(1)
+		return []byte("loaded"), nil
+	})
+	if err != nil || string(value) != "from holder" || loads.Load() != 0 {
+		t.Errorf("Do = %q, %v after %d loads; want the holder's value without loading", value, err, loads.Load())
+	}
+}
+
+// A holder that never fills the cache only delays the others by lockTimeout
+func TestGroupLockTimeout(t *testing.T) {
+	client, _ := newRedis(t)
+	holder := NewRedisLocker(client)
+	lockTimeout := 3 * pollInterval
+	group := NewGroup("prices", NewRedisCache(client), NewRedisLocker(client), lockTimeout)
+	ctx := context.Background()
+
+	if unlock, err := holder.TryLock(ctx, "prices:key", time.Minute); err != nil || unlock == nil {
+		t.Fatalf("TryLock = %v", err)
+	}
+
+	start := time.Now()
+	value, err := group.Do(ctx, "key", time.Minute, func(context.Context) ([]byte, error) {
+		return []byte("loaded"), nil
+	})
+	if err != nil || string(value) != "loaded" {
+		t.Fatalf("Do = %q, %v; want its own load", value, err)
+	}
+	if waited := time.Since(start); waited < lockTimeout {
+		t.Errorf("loaded after %s, before the %s lock timeout", waited, lockTimeout)
+	}
+}
+
+// Errors aren't cached, so the next caller loads again
+func TestGroupDoesNotCacheErrors(t *testing.T) {
+	client, _ := newRedis(t)
+	group := NewGroup("prices", NewRedisCache(client), NewRedisLocker(client), time.Second)
+	ctx := context.Background()
+
+	failed := errors.New("upstream down")
+	if _, err := group.Do(ctx, "key", time.Minute, func(context.Context) ([]byte, error) {
+		return nil, failed
+	}); !errors.Is(err, failed) {
+		t.Fatalf("Do = %v, want the load error", err)
+	}
+	value, err := group.Do(ctx, "key", time.Minute, func(context.Context) ([]byte, error) {
+		return []byte("loaded"), nil
+	})
+	if err != nil || string(value) != "loaded" {
+		t.Errorf("Do after an error = %q, %v; want a fresh load", value, err)
+	}
+}
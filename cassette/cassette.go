@@ -0,0 +1,322 @@
+// Package cassette records HTTP exchanges with upstream providers to YAML
+// files and replays them offline. Credentials are scrubbed before anything
+// is written, so cassettes are safe to commit.
+package cassette
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"gopkg.in/yaml.v3"
+)
+
+// Mode controls whether a Recorder talks to the network
+type Mode string
+
+const (
+	// ModeReplay serves responses from the cassette and fails on unknown requests
+	ModeReplay Mode = "replay"
+	// ModeRecord forwards requests upstream and saves every exchange
+	ModeRecord Mode = "record"
+)
+
+// Redacted replaces scrubbed credentials
+const Redacted = "REDACTED"
+
+// minSecretLength skips literal scrubbing of values too short to be real
+// credentials, which would otherwise mangle every URL they occur in
+const minSecretLength = 8
+
+// sensitiveParams are query/form parameters holding credentials
+var sensitiveParams = []string{"api_key", "apikey", "apiKey", "token", "key", "access_token", "client_secret"}
+
+// sensitiveHeaders are request headers holding credentials
+var sensitiveHeaders = []string{"Apikey", "Authorization", "X-Api-Key"}
+
+// sensitiveJSONFields and sensitiveFormFields catch credentials in bodies,
+// such as the access_token returned by an OAuth token endpoint
+var (
+	sensitiveJSONFields = regexp.MustCompile(`("(?:` + strings.Join(sensitiveParams, "|") + `)"\s*:\s*)"[^"]*"`)
+	sensitiveFormFields = regexp.MustCompile(`((?:^|&)(?:` + strings.Join(sensitiveParams, "|") + `)=)[^&]*`)
+)
+
+// Cassette is a recorded sequence of HTTP exchanges
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	RecordedAt time.Time `yaml:"recordedAt"`
+	Request    Request   `yaml:"request"`
+	Response   Response  `yaml:"response"`
+}
+
+type Request struct {
+	Method  string              `yaml:"method"`
+	URL     string              `yaml:"url"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+	Body    string              `yaml:"body,omitempty"`
+}
+
+type Response struct {
+	Status  int                 `yaml:"status"`
+	Headers map[string][]string `yaml:"headers,omitempty"`
+	Body    string              `yaml:"body"`
+}
+
+// Recorder is an http.RoundTripper that records to or replays from a cassette file
+type Recorder struct {
+	mode     Mode
+	path     string
+	upstream http.RoundTripper
+	secrets  []*config.Secret
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     map[int]bool
+}
+
+// New opens the cassette at path. In replay mode the file must exist; in
+// record mode any existing interactions are discarded. secrets are
+// credentials scrubbed wherever their current value appears, in addition to
+// the well-known credential parameters and headers; they are read on every
+// exchange, so a rotated credential is scrubbed too.
+func New(path string, mode Mode, upstream http.RoundTripper, secrets ...*config.Secret) (*Recorder, error) {
+	if upstream == nil {
+		upstream = http.DefaultTransport
+	}
+
+	r := &Recorder{
+		mode:     mode,
+		path:     path,
+		upstream: upstream,
+		cassette: &Cassette{},
+		used:     map[int]bool{},
+		secrets:  secrets,
+	}
+
+	switch mode {
+	case ModeReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("cassette: %w", err)
+		}
+		if err := yaml.Unmarshal(data, r.cassette); err != nil {
+			return nil, fmt.Errorf("cassette: failed to parse %s: %w", path, err)
+		}
+	case ModeRecord:
+	default:
+		return nil, fmt.Errorf("cassette: unknown mode %q", mode)
+	}
+
+	return r, nil
+}
+
+// Open returns a recorder when cfg.Path names a cassette file, in cfg.Mode
+// (replay by default). Without a path it returns the default transport
+// unchanged.
+func Open(cfg config.Cassette, secrets ...*config.Secret) (http.RoundTripper, error) {
+	if cfg.Path == "" {
+		return http.DefaultTransport, nil
+	}
+
+	mode := Mode(strings.ToLower(cfg.Mode))
+	if mode == "" {
+		mode = ModeReplay
+	}
+	return New(cfg.Path, mode, http.DefaultTransport, secrets...)
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	recorded := r.scrubRequest(req, reqBody)
+
+	if r.mode == ModeReplay {
+		return r.replay(req, recorded)
+	}
+
+	resp, err := r.upstream.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		RecordedAt: time.Now().UTC(),
+		Request:    recorded,
+		Response: Response{
+			Status:  resp.StatusCode,
+			Headers: r.scrubHeaders(resp.Header),
+			Body:    r.scrub(respBody),
+		},
+	})
+	if err := r.save(); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// replay returns the first unused interaction matching method, URL and body.
+// Interactions are consumed in order so repeated identical requests (e.g. a
+// 401 followed by a retry) replay their recorded sequence.
+func (r *Recorder) replay(req *http.Request, recorded Request) (*http.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] || !matches(interaction.Request, recorded) {
+			continue
+		}
+		r.used[i] = true
+
+		header := http.Header{}
+		for k, v := range interaction.Response.Headers {
+			header[k] = v
+		}
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.Status, http.StatusText(interaction.Response.Status)),
+			StatusCode:    interaction.Response.Status,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        header,
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("cassette: no recorded interaction for %s %s in %s", recorded.Method, recorded.URL, r.path)
+}
+
+// Unplayed returns the recorded requests that haven't been replayed yet, so
+// tests can check an adapter made every call it was recorded making
+func (r *Recorder) Unplayed() []Request {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var unplayed []Request
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unplayed = append(unplayed, interaction.Request)
+		}
+	}
+	return unplayed
+}
+
+func matches(recorded, req Request) bool {
+	return recorded.Method == req.Method && recorded.URL == req.URL && recorded.Body == req.Body
+}
+
+func (r *Recorder) save() error {
+	data, err := yaml.Marshal(r.cassette)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, data, 0o644)
+}
+
+func (r *Recorder) scrubRequest(req *http.Request, body []byte) Request {
+	u := *req.URL
+	u.RawQuery = scrubQuery(u.Query()).Encode()
+
+	return Request{
+		Method:  req.Method,
+		URL:     r.scrub([]byte(u.String())),
+		Headers: r.scrubHeaders(req.Header),
+		Body:    r.scrub(body),
+	}
+}
+
+func (r *Recorder) scrubHeaders(h http.Header) map[string][]string {
+	if len(h) == 0 {
+		return nil
+	}
+
+	scrubbed := make(map[string][]string, len(h))
+	for k, values := range h {
+		if isSensitiveHeader(k) {
+			scrubbed[k] = []string{Redacted}
+			continue
+		}
+		for _, v := range values {
+			scrubbed[k] = append(scrubbed[k], r.scrub([]byte(v)))
+		}
+	}
+	return scrubbed
+}
+
+// scrub blanks credential fields and replaces every known secret value,
+// longest first so a secret that contains another is fully removed
+func (r *Recorder) scrub(b []byte) string {
+	s := sensitiveJSONFields.ReplaceAllString(string(b), `${1}"`+Redacted+`"`)
+	s = sensitiveFormFields.ReplaceAllString(s, "${1}"+Redacted)
+	var secrets []string
+	for _, secret := range r.secrets {
+		if v := secret.Get(); len(v) >= minSecretLength {
+			secrets = append(secrets, v)
+		}
+	}
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	for _, secret := range secrets {
+		s = strings.ReplaceAll(s, secret, Redacted)
+		s = strings.ReplaceAll(s, url.QueryEscape(secret), Redacted)
+	}
+	return s
+}
+
+func scrubQuery(q url.Values) url.Values {
+	for _, name := range sensitiveParams {
+		if q.Has(name) {
+			q.Set(name, Redacted)
+		}
+	}
+	return q
+}
+
+func isSensitiveHeader(name string) bool {
+	for _, h := range sensitiveHeaders {
+		if strings.EqualFold(name, h) {
+			return true
+		}
+	}
+	return false
+}
+
+// readBody drains a body and replaces it with a rereadable copy
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
@@ -0,0 +1,146 @@
+package cassette
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+const (
+	apiKey      = "sk-live-4f9a8b7c6d5e"
+	literal     = "publisher-8675309"
+	accessToken = "eyJhbGciOiJIUzI1NiJ9.payload"
+)
+
+// publisherID holds literal, a credential only known by its value
+var publisherID = config.NewSecret(literal)
+
+// upstream answers like a provider that echoes a credential back: an OAuth
+// token in JSON and the publisher ID in a link
+func upstream(t *testing.T) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Request-Id", "req-1")
+		fmt.Fprintf(w, `{"access_token":"%s","url":"https://example.com/?publisherId=%s","price":3.88}`, accessToken, literal)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get sends url with credentials in its headers and returns the body
+func get(client *http.Client, url string) (*http.Response, string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-Api-Key", apiKey)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp, string(body), err
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	srv := upstream(t)
+	path := filepath.Join(t.TempDir(), "provider.yaml")
+	url := srv.URL + "/search?q=eggs&api_key=" + apiKey + "&publisherId=" + literal
+
+	recorder, err := New(path, ModeRecord, srv.Client().Transport, publisherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, body, err := get(&http.Client{Transport: recorder}, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || !strings.Contains(body, accessToken) {
+		t.Fatalf("recording returned %d: %s; want the upstream response unchanged", resp.StatusCode, body)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{apiKey, literal, accessToken} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("cassette contains %q:\n%s", secret, data)
+		}
+	}
+	if !strings.Contains(string(data), "price\":3.88") {
+		t.Errorf("cassette lost the response body:\n%s", data)
+	}
+
+	// Replay matches the scrubbed request, so the same call with real
+	// credentials replays without the network
+	srv.Close()
+	replayer, err := New(path, ModeReplay, nil, publisherID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: replayer}
+	resp, body, err = get(client, url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Request-Id") != "req-1" {
+		t.Fatalf("replay returned %d with headers %v", resp.StatusCode, resp.Header)
+	}
+	if !strings.Contains(body, `"access_token":"REDACTED"`) || !strings.Contains(body, "price\":3.88") {
+		t.Errorf("replayed body = %s, want the scrubbed recording", body)
+	}
+	if unplayed := replayer.Unplayed(); len(unplayed) != 0 {
+		t.Errorf("unplayed = %v, want none", unplayed)
+	}
+
+	// Each interaction is replayed once, and anything unrecorded fails
+	// rather than reaching the network
+	for _, missing := range []string{url, srv.URL + "/search?q=milk"} {
+		if _, _, err := get(client, missing); err == nil || !strings.Contains(err.Error(), "no recorded interaction") {
+			t.Errorf("replay of %s = %v, want no recorded interaction", missing, err)
+		}
+	}
+}
+
+func TestReplayRequiresCassette(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.yaml"), ModeReplay, nil); err == nil {
+		t.Error("replaying a missing cassette succeeded")
+	}
+	if _, err := New("x.yaml", Mode("live"), nil); err == nil {
+		t.Error("unknown mode accepted")
+	}
+}
+
+func TestShortSecretsNotScrubbed(t *testing.T) {
+	r, err := New("unused.yaml", ModeRecord, nil, config.NewSecret("eggs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.scrub([]byte("eggs dozen")); got != "eggs dozen" {
+		t.Errorf("scrub = %q, want values shorter than %d characters left alone", got, minSecretLength)
+	}
+}
+
+// Secrets are read when scrubbing, so a credential rotated after the
+// recorder was opened is still kept out of the cassette
+func TestRotatedSecretScrubbed(t *testing.T) {
+	secret := config.NewSecret("old-credential-1")
+	r, err := New("unused.yaml", ModeRecord, nil, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret.Set("new-credential-2")
+	if got := r.scrub([]byte("current new-credential-2, previous old-credential-1")); got != "current REDACTED, previous old-credential-1" {
+		t.Errorf("scrub = %q, want only the current value replaced", got)
+	}
+}
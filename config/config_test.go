@@ -0,0 +1,272 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// env serves lookups from vars, as os.LookupEnv would
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		v, ok := vars[key]
+		return v, ok
+	}
+}
+
+func TestChaosFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chaos.yaml")
+	data := "seed: 7\nproviders:\n  walmart:\n    errorRate: 0.5\n    statusCodes: [503]\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := load("", env(map[string]string{"CHAOS_CONFIG": path}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fault := cfg.Chaos.Providers["walmart"]
+	if cfg.Chaos.Seed != 7 || fault.ErrorRate != 0.5 || len(fault.StatusCodes) != 1 {
+		t.Errorf("chaos = %+v", cfg.Chaos)
+	}
+}
+
+// production returns a valid production config
+func production() *Config {
+	cfg := Default()
+	cfg.Server.Environment = "production"
+	cfg.Server.CORSAllowedOrigins = []string{"https://eggs.example.com"}
+	cfg.Auth.Required = true
+	cfg.Auth.AdminToken = NewSecret("admin-token")
+	return cfg
+}
+
+func TestProductionRequiresAuth(t *testing.T) {
+	cfg := production()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.Auth.Required = false
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "auth.required") {
+		t.Errorf("got %v, want auth.required rejected in production", err)
+	}
+}
+
+func TestChaosRefusedInProduction(t *testing.T) {
+	cfg := production()
+	cfg.Chaos.Providers = map[string]ChaosFault{"walmart": {ErrorRate: 0.1}}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "config: chaos:") {
+		t.Errorf("got %v, want chaos refused in production", err)
+	}
+}
+
+// writeFile writes data to name in a temp dir and returns its path
+func writeFile(t *testing.T, name, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// The same settings load from YAML and TOML, and anything left unset keeps
+// its default
+func TestLoadFile(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": "server:\n  port: \"9090\"\nprices:\n  trackedZipcodes: [\"94102\"]\n  refreshInterval: 5m\nwalmart:\n  apiKey: walmart-key\nmock:\n  scenario: walmart-outage\n",
+		"config.toml": "[server]\nport = \"9090\"\n[prices]\ntrackedZipcodes = [\"94102\"]\nrefreshInterval = \"5m\"\n[walmart]\napiKey = \"walmart-key\"\n[mock]\nscenario = \"walmart-outage\"\n",
+	}
+	for name, data := range files {
+		t.Run(name, func(t *testing.T) {
+			cfg, err := load(writeFile(t, name, data), env(nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Server.Port != "9090" || cfg.Prices.RefreshInterval != 5*time.Minute || len(cfg.Prices.TrackedZipcodes) != 1 {
+				t.Errorf("server = %+v, prices = %+v", cfg.Server, cfg.Prices)
+			}
+			if cfg.Walmart.APIKey.Get() != "walmart-key" || cfg.Mock.Scenario != "walmart-outage" {
+				t.Errorf("walmart key = %q, mock = %+v", cfg.Walmart.APIKey.Get(), cfg.Mock)
+			}
+			if cfg.Server.WriteTimeout != Default().Server.WriteTimeout {
+				t.Errorf("server.writeTimeout = %s, want the default", cfg.Server.WriteTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadFileRejectsUnknownKeys(t *testing.T) {
+	files := map[string]string{
+		"typo.yaml": "server:\n  prot: \"9090\"\n",
+		"typo.toml": "[server]\nprot = \"9090\"\n",
+		"typo.json": "{}",
+	}
+	for name, data := range files {
+		if _, err := load(writeFile(t, name, data), env(nil)); err == nil {
+			t.Errorf("%s loaded, want it rejected", name)
+		}
+	}
+}
+
+// An env var wins over the file, even when set to an empty value
+func TestEnvOverridesFile(t *testing.T) {
+	path := writeFile(t, "config.yaml", "server:\n  port: \"9090\"\nlog:\n  level: debug\nwalgreens:\n  priceSources: [apify]\ncassette:\n  mode: record\n")
+	cfg, err := load(path, env(map[string]string{
+		"PORT":                    "7070",
+		"LOG_LEVEL":               "",
+		"WALGREENS_PRICE_SOURCES": "searchapi, serpapi",
+		"HTTP_CASSETTE_MODE":      "replay",
+		"MOCK_SCENARIO":           "slow-providers",
+		"OTEL_SERVICE_NAME":       "eggs-staging",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != "7070" || cfg.Log.Level != "" {
+		t.Errorf("port = %q, log level = %q", cfg.Server.Port, cfg.Log.Level)
+	}
+	if got := strings.Join(cfg.Walgreens.PriceSources, ","); got != "searchapi,serpapi" {
+		t.Errorf("walgreens.priceSources = %q", got)
+	}
+	if cfg.Cassette.Mode != "replay" || cfg.Mock.Scenario != "slow-providers" || cfg.Tracing.ServiceName != "eggs-staging" {
+		t.Errorf("cassette = %+v, mock = %+v, tracing = %+v", cfg.Cassette, cfg.Mock, cfg.Tracing)
+	}
+}
+
+func TestEnvRejectsBadValues(t *testing.T) {
+	for name, value := range map[string]string{
+		"HTTP_READ_TIMEOUT":  "soon",
+		"AUTH_REQUIRED":      "maybe",
+		"DATABASE_MAX_CONNS": "ten",
+	} {
+		_, err := load("", env(map[string]string{name: value}))
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s=%s: got %v, want it named in the error", name, value, err)
+		}
+	}
+}
+
+// NAME_FILE reads a secret from a file, and a file set in the config file
+// is read the same way; setting both NAME and NAME_FILE is ambiguous
+func TestSecretFiles(t *testing.T) {
+	keyFile := writeFile(t, "walgreens-key", "file-key\n")
+	secretFile := writeFile(t, "walgreens-secret", "file-secret")
+	path := writeFile(t, "config.yaml", "walgreens:\n  apiSecret: {file: "+secretFile+"}\n")
+
+	cfg, err := load(path, env(map[string]string{"WALGREENS_API_KEY_FILE": keyFile}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cfg.Walgreens.APIKey.Get(); got != "file-key" {
+		t.Errorf("walgreens.apiKey = %q, want file-key without the newline", got)
+	}
+	if got := cfg.Walgreens.APISecret.Get(); got != "file-secret" {
+		t.Errorf("walgreens.apiSecret = %q, want file-secret", got)
+	}
+	if cfg.Walgreens.APIKey.File() != keyFile {
+		t.Errorf("walgreens.apiKey file = %q, want %q", cfg.Walgreens.APIKey.File(), keyFile)
+	}
+
+	_, err = load("", env(map[string]string{"WALGREENS_API_KEY_FILE": filepath.Join(t.TempDir(), "missing")}))
+	if err == nil || !strings.Contains(err.Error(), "walgreens.apiKey") {
+		t.Errorf("missing file: got %v, want walgreens.apiKey named", err)
+	}
+	_, err = load("", env(map[string]string{"WALGREENS_API_KEY": "key", "WALGREENS_API_KEY_FILE": keyFile}))
+	if err == nil || !strings.Contains(err.Error(), "set only one") {
+		t.Errorf("both set: got %v, want them refused", err)
+	}
+}
+
+// /admin/config shows every section but no secret, including the OTLP
+// headers and secrets read from files
+func TestHandlerRedactsSecrets(t *testing.T) {
+	cfg, err := load("", env(map[string]string{
+		"WALMART_API_KEY":            "walmart-live-key",
+		"APIFY_KEY_FILE":             writeFile(t, "apify", "apify-live-key"),
+		"OTEL_EXPORTER_OTLP_HEADERS": "authorization=Bearer otlp-live-token",
+		"ZIPCODE_DATA_FILE":          writeFile(t, "zipcodes.csv", ""),
+		"MOCK_SCENARIO":              "walmart-outage",
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler(cfg).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-store" {
+		t.Fatalf("got %d, Cache-Control %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	for _, secret := range []string{"walmart-live-key", "apify-live-key", "otlp-live-token"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("dump leaks %q:\n%s", secret, body)
+		}
+	}
+
+	var dump map[string]map[string]interface{}
+	if err := yaml.Unmarshal(rec.Body.Bytes(), &dump); err != nil {
+		t.Fatal(err)
+	}
+	if dump["walmart"]["apiKey"] != redacted || dump["thirdParty"]["apifyKey"] != redacted || dump["tracing"]["headers"] != redacted {
+		t.Errorf("secrets aren't shown as %s: walmart %v, thirdParty %v, tracing %v", redacted, dump["walmart"], dump["thirdParty"], dump["tracing"])
+	}
+	if dump["walgreens"]["apiKey"] != "" {
+		t.Errorf("unset walgreens.apiKey = %v, want empty", dump["walgreens"]["apiKey"])
+	}
+	if dump["mock"]["scenario"] != "walmart-outage" || dump["geo"]["zipcodeDataFile"] == "" || dump["cassette"]["mode"] != "replay" {
+		t.Errorf("mock %v, geo %v, cassette %v", dump["mock"], dump["geo"], dump["cassette"])
+	}
+}
+
+func TestValidateDevelopmentSettings(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		key    string
+	}{
+		{"missing scenarios dir", func(c *Config) { c.Mock.ScenariosDir = filepath.Join(t.TempDir(), "missing") }, "mock.scenariosDir"},
+		{"unknown cassette mode", func(c *Config) { c.Cassette.Mode = "rewind" }, "cassette.mode"},
+		{"missing cassette to replay", func(c *Config) { c.Cassette.Path = filepath.Join(t.TempDir(), "missing.yaml") }, "cassette.path"},
+		{"missing zipcode data", func(c *Config) { c.Geo.ZipcodeDataFile = filepath.Join(t.TempDir(), "missing.csv") }, "geo.zipcodeDataFile"},
+		{"endpoint without scheme", func(c *Config) { c.Tracing.Endpoint = "collector:4318" }, "tracing.endpoint"},
+		{"bad resource attribute", func(c *Config) { c.Tracing.ResourceAttributes = []string{"staging"} }, "tracing.resourceAttributes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.key) {
+				t.Errorf("got %v, want %s rejected", err, tt.key)
+			}
+		})
+	}
+
+	// A cassette being recorded doesn't exist yet
+	cfg := Default()
+	cfg.Cassette = Cassette{Path: filepath.Join(t.TempDir(), "new.yaml"), Mode: "record"}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("record to a new cassette: %v", err)
+	}
+}
+
+func TestCassetteRefusedInProduction(t *testing.T) {
+	cfg := production()
+	cfg.Cassette = Cassette{Path: writeFile(t, "cassette.yaml", ""), Mode: "replay"}
+
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "cassette.path") {
+		t.Errorf("got %v, want the cassette refused in production", err)
+	}
+}
@@ -0,0 +1,25 @@
+package config
+
+import (
+	"net/http"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted stands in for every secret that is set when a Config is marshaled
+const redacted = "REDACTED"
+
+// Handler serves c as YAML with secrets redacted. It does no authorization
+// of its own; mount it behind the admin scope check.
+func Handler(c *Config) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, err := yaml.Marshal(c)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(data)
+	})
+}
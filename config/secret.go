@@ -0,0 +1,268 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+)
+
+// Secret is a credential that can be rotated while in use. Config sections
+// are copied into adapters by value but share each *Secret, so a rotation
+// reaches every holder at once and in-flight requests keep the value they read.
+//
+// In a config file a secret is either the value itself or a file to read it
+// from, e.g. apiKey: {file: /var/run/secrets/walgreens/api-key}. From the
+// environment it is NAME or NAME_FILE.
+type Secret struct {
+	value atomic.Pointer[string]
+	file  string
+}
+
+// NewSecret returns a secret holding value
+func NewSecret(value string) *Secret {
+	s := &Secret{}
+	s.Set(value)
+	return s
+}
+
+// Get returns the current value. A nil Secret is empty.
+func (s *Secret) Get() string {
+	if s == nil {
+		return ""
+	}
+	if v := s.value.Load(); v != nil {
+		return *v
+	}
+	return ""
+}
+
+// Set replaces the value atomically
+func (s *Secret) Set(value string) {
+	s.value.Store(&value)
+}
+
+// File returns the path the value is read from, or "" if it was set directly
+func (s *Secret) File() string {
+	if s == nil {
+		return ""
+	}
+	return s.file
+}
+
+// Configured reports whether the secret has a value or a file that can
+// supply one after a rotation
+func (s *Secret) Configured() bool {
+	return s.Get() != "" || s.File() != ""
+}
+
+// MarshalText never reveals the value, so a marshaled Config is safe to show:
+// a set secret reads "REDACTED" and an empty one "".
+func (s *Secret) MarshalText() ([]byte, error) {
+	if s.Get() == "" {
+		return []byte{}, nil
+	}
+	return []byte(redacted), nil
+}
+
+// UnmarshalYAML accepts a plain value or {file: path}
+func (s *Secret) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		s.file = ""
+		s.Set(node.Value)
+		return nil
+	}
+
+	var ref struct {
+		File string `yaml:"file"`
+	}
+	if err := node.Decode(&ref); err != nil || ref.File == "" {
+		return fmt.Errorf("line %d: a secret must be a string or {file: path}", node.Line)
+	}
+	s.file = ref.File
+	return nil
+}
+
+// UnmarshalTOML accepts a plain value or { file = "path" }
+func (s *Secret) UnmarshalTOML(v interface{}) error {
+	switch v := v.(type) {
+	case string:
+		s.file = ""
+		s.Set(v)
+		return nil
+	case map[string]interface{}:
+		if file, ok := v["file"].(string); ok && file != "" && len(v) == 1 {
+			s.file = file
+			return nil
+		}
+	}
+	return fmt.Errorf("a secret must be a string or { file = \"path\" }")
+}
+
+// readFile loads the value from the secret's file. Surrounding whitespace,
+// such as the trailing newline most editors add, is dropped.
+func (s *Secret) readFile() (string, error) {
+	data, err := os.ReadFile(s.file)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+type namedSecret struct {
+	key    string // e.g. walgreens.apiKey
+	secret *Secret
+}
+
+var secretType = reflect.TypeOf((*Secret)(nil))
+
+// secrets lists every Secret field, allocating any that are nil
+func (c *Config) secrets() []namedSecret {
+	var secrets []namedSecret
+	v := reflect.ValueOf(c).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		section := v.Field(i)
+		sectionKey := v.Type().Field(i).Tag.Get("yaml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Type().Field(j)
+			if field.Type != secretType {
+				continue
+			}
+			if section.Field(j).IsNil() {
+				section.Field(j).Set(reflect.ValueOf(&Secret{}))
+			}
+			secrets = append(secrets, namedSecret{
+				key:    sectionKey + "." + field.Tag.Get("yaml"),
+				secret: section.Field(j).Interface().(*Secret),
+			})
+		}
+	}
+	return secrets
+}
+
+// Secrets returns every secret, set or not, for scrubbing from recorded
+// provider traffic. Read them when scrubbing, so rotated values are covered.
+func (c *Config) Secrets() []*Secret {
+	named := c.secrets()
+	secrets := make([]*Secret, len(named))
+	for i, s := range named {
+		secrets[i] = s.secret
+	}
+	return secrets
+}
+
+// loadSecretFiles reads every file-backed secret. At startup a missing or
+// unreadable file is an error, unlike during rotation.
+func (c *Config) loadSecretFiles() error {
+	var errs []error
+	for _, s := range c.secrets() {
+		if s.secret.File() == "" {
+			continue
+		}
+		value, err := s.secret.readFile()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config: %s: %w", s.key, err))
+			continue
+		}
+		s.secret.Set(value)
+	}
+	return errors.Join(errs...)
+}
+
+// SecretWatcher reloads file-backed secrets when their files change
+type SecretWatcher struct {
+	watcher *fsnotify.Watcher
+	wg      sync.WaitGroup
+}
+
+// WatchSecrets watches the directory of every file-backed secret and swaps
+// in new values as they are written. Kubernetes updates a mounted Secret by
+// replacing a symlink in its directory, which a watch on the file itself
+// would miss. A file that is unreadable or empty, as it can be mid-write,
+// keeps the previous value. Close the watcher on shutdown.
+func (c *Config) WatchSecrets() (*SecretWatcher, error) {
+	var watched []namedSecret
+	dirs := map[string]bool{}
+	for _, s := range c.secrets() {
+		if s.secret.File() != "" {
+			watched = append(watched, s)
+			dirs[filepath.Dir(s.secret.File())] = true
+		}
+	}
+	if len(watched) == 0 {
+		return &SecretWatcher{}, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("config: failed to watch secret files: %w", err)
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return nil, fmt.Errorf("config: failed to watch %s: %w", dir, err)
+		}
+	}
+
+	w := &SecretWatcher{watcher: watcher}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				reloadSecrets(watched)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				slog.Warn("config: secret watch error", "error", err)
+			}
+		}
+	}()
+	return w, nil
+}
+
+// Close stops watching and waits for any reload in progress
+func (w *SecretWatcher) Close() error {
+	if w.watcher == nil {
+		return nil
+	}
+	err := w.watcher.Close()
+	w.wg.Wait()
+	return err
+}
+
+// reloadSecrets rereads every watched file; one event can stand for several
+// files when a whole mounted directory is swapped
+func reloadSecrets(secrets []namedSecret) {
+	for _, s := range secrets {
+		value, err := s.secret.readFile()
+		if err != nil {
+			// Kubernetes' symlink swap briefly leaves no file behind
+			if !errors.Is(err, fs.ErrNotExist) {
+				slog.Warn("config: failed to reload secret", "key", s.key, "error", err)
+			}
+			continue
+		}
+		if value == "" {
+			continue
+		}
+		if value != s.secret.Get() {
+			s.secret.Set(value)
+			slog.Info("secret rotated", "key", s.key, "file", s.secret.File())
+		}
+	}
+}
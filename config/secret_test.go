@@ -0,0 +1,131 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// k8sMount lays out a directory the way the kubelet mounts a Secret: each
+// key is a symlink through ..data to a timestamped directory
+type k8sMount struct {
+	t   *testing.T
+	dir string
+	gen int
+}
+
+func newK8sMount(t *testing.T, key, value string) *k8sMount {
+	m := &k8sMount{t: t, dir: t.TempDir()}
+	m.update(key, value)
+	if err := os.Symlink(filepath.Join("..data", key), filepath.Join(m.dir, key)); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// update writes value into a new timestamped directory and swaps ..data to
+// it with a rename, as the kubelet does on rotation
+func (m *k8sMount) update(key, value string) {
+	m.t.Helper()
+	m.gen++
+	version := filepath.Join(m.dir, fmt.Sprintf("..2026_10_18_00_00_%02d", m.gen))
+	if err := os.Mkdir(version, 0o755); err != nil {
+		m.t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(version, key), []byte(value), 0o644); err != nil {
+		m.t.Fatal(err)
+	}
+	tmp := filepath.Join(m.dir, "..data_tmp")
+	if err := os.Symlink(filepath.Base(version), tmp); err != nil {
+		m.t.Fatal(err)
+	}
+	if err := os.Rename(tmp, filepath.Join(m.dir, "..data")); err != nil {
+		m.t.Fatal(err)
+	}
+}
+
+// waitFor polls until s holds want
+func waitFor(t *testing.T, s *Secret, want string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for s.Get() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("secret = %q, want %q", s.Get(), want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchSecretsFollowsRotation(t *testing.T) {
+	mount := newK8sMount(t, "api-key", "first-key\n")
+	cfg, err := load("", env(map[string]string{"WALGREENS_API_KEY_FILE": filepath.Join(mount.dir, "api-key")}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cfg.Walgreens.APIKey
+	if key.Get() != "first-key" {
+		t.Fatalf("loaded %q, want first-key", key.Get())
+	}
+
+	watcher, err := cfg.WatchSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	mount.update("api-key", "second-key\n")
+	waitFor(t, key, "second-key")
+
+	// An empty file, as a half-written rotation can leave, keeps the last
+	// good key in use until the next one arrives
+	mount.update("api-key", "")
+	mount.update("api-key", "third-key")
+	deadline := time.Now().Add(5 * time.Second)
+	for key.Get() != "third-key" {
+		if key.Get() == "" {
+			t.Fatal("the empty file replaced the key")
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("secret = %q, want third-key", key.Get())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReloadSecretsKeepsValueOnBadFile(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]func(path string) error{
+		"empty":   func(path string) error { return os.WriteFile(path, []byte(" \n"), 0o644) },
+		"missing": func(path string) error { return nil },
+		// Reading a directory fails with something other than not-exist
+		"unreadable": func(path string) error { return os.Mkdir(path, 0o755) },
+	}
+	for name, write := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name)
+			if err := write(path); err != nil {
+				t.Fatal(err)
+			}
+			s := &Secret{file: path}
+			s.Set("old-key")
+
+			reloadSecrets([]namedSecret{{key: "walgreens.apiKey", secret: s}})
+			if s.Get() != "old-key" {
+				t.Errorf("secret = %q, want old-key kept", s.Get())
+			}
+		})
+	}
+}
+
+// Only file-backed secrets need a watch
+func TestWatchSecretsWithoutFiles(t *testing.T) {
+	watcher, err := Default().WatchSecrets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := watcher.Close(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,35 @@
+// Package db holds the Postgres connection pool shared by components that
+// keep state in Postgres.
+package db
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// Open connects a pool of at most cfg.MaxConns connections to cfg.URL and
+// checks the server answers. Close the pool on shutdown.
+func Open(ctx context.Context, cfg config.Postgres) (*pgxpool.Pool, error) {
+	poolConfig, err := pgxpool.ParseConfig(cfg.URL.Get())
+	if err != nil {
+		// The URL can hold a password, so it is left out of the error
+		return nil, fmt.Errorf("db: invalid postgres url")
+	}
+	poolConfig.MaxConns = int32(cfg.MaxConns)
+	pool, err := pgxpool.NewWithConfig(ctx, poolConfig)
+	if err != nil {
+		return nil, fmt.Errorf("db: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, fmt.Errorf("db: failed to reach postgres at %s: %w", poolConfig.ConnConfig.Host, err)
+	}
+	return pool, nil
+}
@@ -0,0 +1,51 @@
+package db_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/db"
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+)
+
+func TestMigrate(t *testing.T) {
+	pool := dbtest.Open(t)
+	ctx := context.Background()
+
+	// Replicas starting together migrate concurrently; an up-to-date schema
+	// is left alone
+	var wg sync.WaitGroup
+	errs := make([]error, 3)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = db.Migrate(ctx, pool)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count, latest int
+	if err := pool.QueryRow(ctx, "SELECT count(*), max(version) FROM schema_migrations").Scan(&count, &latest); err != nil {
+		t.Fatal(err)
+	}
+	if count != latest {
+		t.Errorf("%d migrations recorded up to version %d, want each version once", count, latest)
+	}
+
+	for _, table := range []string{"comparisons", "comparison_rollups", "price_observations", "latest_prices", "api_keys", "tracked_zipcodes", "price_alerts"} {
+		var exists bool
+		if err := pool.QueryRow(ctx, "SELECT to_regclass($1) IS NOT NULL", table).Scan(&exists); err != nil {
+			t.Fatal(err)
+		}
+		if !exists {
+			t.Errorf("table %s is missing", table)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+-- One comparison per day for the priceHistory query
+CREATE TABLE price_comparisons (
+    date             date PRIMARY KEY,
+    walmart_price    double precision NOT NULL,
+    walgreens_price  double precision NOT NULL,
+    walmart_source   text NOT NULL,
+    walgreens_source text NOT NULL,
+    fetched_at       timestamptz,
+    is_mock          boolean NOT NULL
+);
+
+-- Every price observed at a retailer store. store_id is empty for zip-level
+-- prices that aren't tied to a store.
+CREATE TABLE price_observations (
+    id          bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    retailer    text NOT NULL,
+    zipcode     text NOT NULL,
+    store_id    text NOT NULL,
+    store_name  text NOT NULL,
+    final_price double precision NOT NULL,
+    base_price  double precision NOT NULL,
+    in_stock    boolean NOT NULL,
+    offer_count integer NOT NULL,
+    source      text NOT NULL,
+    is_mock     boolean NOT NULL,
+    fetched_at  timestamptz NOT NULL
+);
+
+CREATE INDEX price_observations_store_fetched_at
+    ON price_observations (retailer, zipcode, store_id, fetched_at);
+
+-- The latest observation per store, so the price exporter doesn't scan
+-- price_observations on every scrape
+CREATE TABLE latest_prices (
+    retailer    text NOT NULL,
+    zipcode     text NOT NULL,
+    store_id    text NOT NULL,
+    store_name  text NOT NULL,
+    final_price double precision NOT NULL,
+    base_price  double precision NOT NULL,
+    in_stock    boolean NOT NULL,
+    offer_count integer NOT NULL,
+    source      text NOT NULL,
+    is_mock     boolean NOT NULL,
+    fetched_at  timestamptz NOT NULL,
+    PRIMARY KEY (retailer, zipcode, store_id)
+);
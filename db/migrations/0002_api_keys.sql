@@ -0,0 +1,12 @@
+-- Issued API keys. Only the SHA-256 hash of each key is stored.
+CREATE TABLE api_keys (
+    id                    text PRIMARY KEY,
+    name                  text NOT NULL,
+    hash                  text NOT NULL UNIQUE,
+    prefix                text NOT NULL,
+    scopes                text[] NOT NULL,
+    rate_limit_per_minute integer NOT NULL,
+    daily_quota           integer NOT NULL,
+    created_at            timestamptz NOT NULL,
+    revoked_at            timestamptz
+);
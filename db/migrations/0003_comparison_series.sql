@@ -0,0 +1,51 @@
+-- Every comparison served, per zipcode. Raw comparisons are kept for the
+-- raw retention and rolled up before they expire.
+CREATE TABLE comparisons (
+    id               bigint GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    zipcode          text NOT NULL,
+    walmart_price    double precision NOT NULL,
+    walgreens_price  double precision NOT NULL,
+    walmart_source   text NOT NULL,
+    walgreens_source text NOT NULL,
+    is_mock          boolean NOT NULL,
+    fetched_at       timestamptz NOT NULL
+);
+
+CREATE INDEX comparisons_fetched_at ON comparisons (fetched_at);
+CREATE INDEX comparisons_zipcode_fetched_at ON comparisons (zipcode, fetched_at);
+
+-- Hourly and daily open/high/low/close of comparisons. Sources and
+-- fetched_at are the close's. zipcode '' is the series across every zipcode.
+CREATE TABLE comparison_rollups (
+    resolution       text NOT NULL CHECK (resolution IN ('HOURLY', 'DAILY')),
+    zipcode          text NOT NULL,
+    period_start     timestamptz NOT NULL,
+    walmart_open     double precision NOT NULL,
+    walmart_high     double precision NOT NULL,
+    walmart_low      double precision NOT NULL,
+    walmart_close    double precision NOT NULL,
+    walgreens_open   double precision NOT NULL,
+    walgreens_high   double precision NOT NULL,
+    walgreens_low    double precision NOT NULL,
+    walgreens_close  double precision NOT NULL,
+    walmart_source   text NOT NULL,
+    walgreens_source text NOT NULL,
+    is_mock          boolean NOT NULL,
+    fetched_at       timestamptz NOT NULL,
+    samples          integer NOT NULL,
+    PRIMARY KEY (resolution, zipcode, period_start)
+);
+
+-- price_comparisons kept the first comparison of each day from any zipcode,
+-- so its rows become daily points of the all-zipcode series
+INSERT INTO comparison_rollups
+SELECT 'DAILY', '', date::timestamp AT TIME ZONE 'UTC',
+       walmart_price, walmart_price, walmart_price, walmart_price,
+       walgreens_price, walgreens_price, walgreens_price, walgreens_price,
+       walmart_source, walgreens_source, is_mock,
+       coalesce(fetched_at, date::timestamp AT TIME ZONE 'UTC'), 1
+FROM price_comparisons;
+
+DROP TABLE price_comparisons;
+
+CREATE INDEX price_observations_fetched_at ON price_observations (fetched_at);
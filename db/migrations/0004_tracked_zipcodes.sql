@@ -0,0 +1,6 @@
+-- Zipcodes whose prices are refreshed in the background and published by
+-- the price exporter
+CREATE TABLE tracked_zipcodes (
+    zipcode  text PRIMARY KEY,
+    added_at timestamptz NOT NULL DEFAULT now()
+);
@@ -0,0 +1,15 @@
+-- A comparison served from cache repeats the one recorded when it was
+-- fetched. Keep the first of each and skip repeats from now on.
+DELETE FROM comparisons c
+USING comparisons earlier
+WHERE earlier.zipcode = c.zipcode AND earlier.fetched_at = c.fetched_at AND earlier.id < c.id;
+
+DROP INDEX comparisons_zipcode_fetched_at;
+CREATE UNIQUE INDEX comparisons_zipcode_fetched_at ON comparisons (zipcode, fetched_at);
+
+-- Compaction stopped rolling every zipcode up into one series under ''.
+-- Only the daily points carried over from price_comparisons, before
+-- migration 3, are kept.
+DELETE FROM comparison_rollups
+WHERE zipcode = '' AND (resolution = 'HOURLY' OR period_start >=
+    (SELECT date_trunc('day', applied_at, 'UTC') FROM schema_migrations WHERE version = 3));
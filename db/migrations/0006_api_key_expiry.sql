@@ -0,0 +1,2 @@
+-- Keys issued with an expiry stop authenticating once it passes
+ALTER TABLE api_keys ADD COLUMN expires_at timestamptz;
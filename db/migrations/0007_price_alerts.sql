@@ -0,0 +1,14 @@
+-- Price alerts: an API key asks to be told when the cheapest price at a
+-- zipcode drops below a target. key_id has no foreign key because the
+-- bootstrap admin token isn't a row in api_keys.
+CREATE TABLE price_alerts (
+    id           text PRIMARY KEY,
+    key_id       text NOT NULL,
+    zipcode      text NOT NULL,
+    target_price double precision NOT NULL,
+    created_at   timestamptz NOT NULL,
+    fired_at     timestamptz
+);
+
+CREATE INDEX price_alerts_key_id ON price_alerts (key_id, created_at DESC);
+CREATE INDEX price_alerts_zipcode ON price_alerts (zipcode);
@@ -0,0 +1,217 @@
+// Command zipgen builds geo/zipcodes.csv from the GeoNames US postal code
+// dataset, which covers every USPS zipcode. Postal codes carry no timezone,
+// so each zipcode takes the timezone of the nearest GeoNames place in its
+// state. Run it through go generate ./geo; it needs network access.
+//
+// GeoNames data is licensed under CC BY 4.0: https://www.geonames.org
+package main
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	postalCodesURL = "https://download.geonames.org/export/zip/US.zip"
+	placesURL      = "https://download.geonames.org/export/dump/cities500.zip"
+)
+
+// header matches the columns geo.Load expects
+var header = []string{"zipcode", "city", "state", "county", "latitude", "longitude", "timezone"}
+
+type zipcode struct {
+	zipcode, city, state, county string
+	lat, lon                     float64
+	timezone                     string
+}
+
+type place struct {
+	state    string
+	lat, lon float64
+	timezone string
+}
+
+func main() {
+	out := flag.String("o", "zipcodes.csv", "file to write")
+	flag.Parse()
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	postal, err := download(client, postalCodesURL, "US.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	zipcodes, err := parsePostalCodes(bytes.NewReader(postal))
+	if err != nil {
+		log.Fatal(err)
+	}
+	cities, err := download(client, placesURL, "cities500.txt")
+	if err != nil {
+		log.Fatal(err)
+	}
+	places, err := parsePlaces(bytes.NewReader(cities))
+	if err != nil {
+		log.Fatal(err)
+	}
+	assignTimezones(zipcodes, places)
+
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := write(f, zipcodes); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d zipcodes to %s", len(zipcodes), *out)
+}
+
+// download fetches a GeoNames zip archive and returns the named file in it
+func download(client *http.Client, url, name string) ([]byte, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("zipgen: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("zipgen: %s returned status %d", url, resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("zipgen: failed to download %s: %w", url, err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("zipgen: %s: %w", url, err)
+	}
+	f, err := archive.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("zipgen: %s: %w", url, err)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// parsePostalCodes reads the tab-separated GeoNames postal code file:
+// country, postal code, place, state name, state code, county name, county
+// code, community name, community code, latitude, longitude, accuracy.
+// Codes without coordinates, such as military post offices, are skipped.
+func parsePostalCodes(r io.Reader) ([]*zipcode, error) {
+	seen := map[string]bool{}
+	var zipcodes []*zipcode
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 11 {
+			return nil, fmt.Errorf("zipgen: postal codes line %d: %d fields, want 12", line, len(fields))
+		}
+		code := fields[1]
+		if len(code) != 5 || seen[code] || fields[9] == "" || fields[10] == "" {
+			continue
+		}
+		lat, err := strconv.ParseFloat(fields[9], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zipgen: postal codes line %d: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(fields[10], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zipgen: postal codes line %d: %w", line, err)
+		}
+		seen[code] = true
+		zipcodes = append(zipcodes, &zipcode{
+			zipcode: code, city: fields[2], state: fields[4], county: fields[5], lat: lat, lon: lon,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("zipgen: failed to read postal codes: %w", err)
+	}
+	sort.Slice(zipcodes, func(i, j int) bool { return zipcodes[i].zipcode < zipcodes[j].zipcode })
+	return zipcodes, nil
+}
+
+// parsePlaces reads the US places from the tab-separated GeoNames cities
+// file, keeping the state (admin1 code), coordinates and timezone
+func parsePlaces(r io.Reader) ([]place, error) {
+	var places []place
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) < 18 {
+			return nil, fmt.Errorf("zipgen: places line %d: %d fields, want 19", line, len(fields))
+		}
+		if fields[8] != "US" || fields[17] == "" {
+			continue
+		}
+		lat, err := strconv.ParseFloat(fields[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zipgen: places line %d: %w", line, err)
+		}
+		lon, err := strconv.ParseFloat(fields[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("zipgen: places line %d: %w", line, err)
+		}
+		places = append(places, place{state: fields[10], lat: lat, lon: lon, timezone: fields[17]})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("zipgen: failed to read places: %w", err)
+	}
+	return places, nil
+}
+
+// assignTimezones gives each zipcode the timezone of the nearest place in
+// its state, or the nearest place anywhere when its state has none
+func assignTimezones(zipcodes []*zipcode, places []place) {
+	byState := map[string][]place{}
+	for _, p := range places {
+		byState[p.state] = append(byState[p.state], p)
+	}
+	for _, z := range zipcodes {
+		candidates := byState[z.state]
+		if len(candidates) == 0 {
+			candidates = places
+		}
+		best := math.Inf(1)
+		for _, p := range candidates {
+			if d := distance(z.lat, z.lon, p.lat, p.lon); d < best {
+				best, z.timezone = d, p.timezone
+			}
+		}
+	}
+}
+
+// distance is proportional to the squared equirectangular distance, which
+// orders nearby points the same as the great-circle distance
+func distance(lat1, lon1, lat2, lon2 float64) float64 {
+	x := (lon2 - lon1) * math.Cos((lat1+lat2)/2*math.Pi/180)
+	y := lat2 - lat1
+	return x*x + y*y
+}
+
+func write(w io.Writer, zipcodes []*zipcode) error {
+	out := csv.NewWriter(w)
+	out.Write(header)
+	for _, z := range zipcodes {
+		out.Write([]string{
+			z.zipcode, z.city, z.state, z.county,
+			strconv.FormatFloat(z.lat, 'f', 4, 64), strconv.FormatFloat(z.lon, 'f', 4, 64), z.timezone,
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/geo"
+)
+
+// Excerpts of the GeoNames files, including a military post office
+// without coordinates
+const (
+	postalCodes = "US\t94102\tSan Francisco\tCalifornia\tCA\tSan Francisco\t075\t\t\t37.7813\t-122.4167\t4\n" +
+		"US\t02108\tBoston\tMassachusetts\tMA\tSuffolk\t025\t\t\t42.3576\t-71.0637\t4\n" +
+		"US\t09002\tAPO\tArmed Forces Europe\tAE\t\t\t\t\t\t\t\n" +
+		"US\t89049\tTonopah\tNevada\tNV\tNye\t023\t\t\t38.0670\t-117.2301\t4\n"
+	places = "5391959\tSan Francisco\tSan Francisco\t\t37.77493\t-122.41942\tP\tPPLA2\tUS\t\tCA\t075\t\t\t864816\t16\t28\tAmerica/Los_Angeles\t2022-09-05\n" +
+		"4930956\tBoston\tBoston\t\t42.35843\t-71.05977\tP\tPPLA\tUS\t\tMA\t025\t\t\t617594\t14\t38\tAmerica/New_York\t2022-09-05\n" +
+		"5506956\tLas Vegas\tLas Vegas\t\t36.17497\t-115.13722\tP\tPPLA2\tUS\t\tNV\t003\t\t\t641903\t613\t620\tAmerica/Los_Angeles\t2022-09-05\n" +
+		"6167865\tToronto\tToronto\t\t43.70643\t-79.39864\tP\tPPLA\tCA\t\t08\t\t\t\t2600000\t175\t180\tAmerica/Toronto\t2022-09-05\n"
+)
+
+// The generated file loads with geo.Load and every zipcode with a location
+// gets a timezone
+func TestGenerate(t *testing.T) {
+	zipcodes, err := parsePostalCodes(strings.NewReader(postalCodes))
+	if err != nil {
+		t.Fatal(err)
+	}
+	places, err := parsePlaces(strings.NewReader(places))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(places) != 3 {
+		t.Errorf("got %d places, want the 3 in the US", len(places))
+	}
+	assignTimezones(zipcodes, places)
+
+	var out strings.Builder
+	if err := write(&out, zipcodes); err != nil {
+		t.Fatal(err)
+	}
+	db, err := geo.Load(strings.NewReader(out.String()))
+	if err != nil {
+		t.Fatalf("generated file doesn't load: %v\n%s", err, out.String())
+	}
+	if db.Len() != 3 {
+		t.Errorf("got %d zipcodes, want 3 without the APO", db.Len())
+	}
+	for zipcode, timezone := range map[string]string{
+		"02108": "America/New_York",
+		"94102": "America/Los_Angeles",
+		"89049": "America/Los_Angeles",
+	} {
+		z, ok := db.Lookup(zipcode)
+		if !ok || z.Timezone != timezone {
+			t.Errorf("Lookup(%s) = %+v, want timezone %s", zipcode, z, timezone)
+		}
+	}
+}
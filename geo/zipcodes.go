@@ -0,0 +1,184 @@
+package geo
+
+import (
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// zipcodesCSV is the bundled dataset. The checked-in file covers major US
+// metros for offline development; builds regenerate it with every USPS
+// zipcode from GeoNames (see internal/zipgen). Open can replace it with a CSV
+// with the same columns.
+//
+//go:generate go run ./internal/zipgen -o zipcodes.csv
+//go:embed zipcodes.csv
+var zipcodesCSV string
+
+// earthRadiusMiles is the mean Earth radius used for great-circle distances
+const earthRadiusMiles = 3958.8
+
+var zipcodePattern = regexp.MustCompile(`^\d{5}$`)
+
+// columns is the header Load expects, in order
+var columns = []string{"zipcode", "city", "state", "county", "latitude", "longitude", "timezone"}
+
+// Zipcode is a US ZIP code with its centroid and locale details
+type Zipcode struct {
+	Zipcode   string
+	City      string
+	State     string
+	County    string
+	Latitude  float64
+	Longitude float64
+	Timezone  string
+}
+
+// ZipcodeDB is an in-memory zipcode index
+type ZipcodeDB struct {
+	byZip   map[string]*Zipcode
+	ordered []*Zipcode
+}
+
+var (
+	defaultDB     *ZipcodeDB
+	defaultDBErr  error
+	defaultDBOnce sync.Once
+)
+
+// Default returns the shared database loaded from the embedded dataset
+func Default() (*ZipcodeDB, error) {
+	defaultDBOnce.Do(func() {
+		defaultDB, defaultDBErr = Load(strings.NewReader(zipcodesCSV))
+	})
+	return defaultDB, defaultDBErr
+}
+
+// Open loads the database from the CSV file at path (geo.zipcodeDataFile),
+// or returns the embedded one when path is empty
+func Open(path string) (*ZipcodeDB, error) {
+	if path == "" {
+		return Default()
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("geo: failed to open zipcode data: %w", err)
+	}
+	defer f.Close()
+	return Load(f)
+}
+
+// Load parses a zipcode CSV with the header
+// zipcode,city,state,county,latitude,longitude,timezone
+func Load(r io.Reader) (*ZipcodeDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(columns)
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("geo: failed to parse zipcode data: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("geo: zipcode data is empty")
+	}
+	// Columns are read by position, so a reordered file would silently
+	// swap fields. Spreadsheet exports may prefix a byte order mark.
+	for i, name := range records[0] {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		if !strings.EqualFold(strings.TrimSpace(name), columns[i]) {
+			return nil, fmt.Errorf("geo: unexpected header %q, want %q", strings.Join(records[0], ","), strings.Join(columns, ","))
+		}
+	}
+
+	db := &ZipcodeDB{byZip: make(map[string]*Zipcode, len(records)-1)}
+	for i, record := range records[1:] {
+		if !ValidFormat(record[0]) {
+			// Usually a spreadsheet dropping leading zeros
+			return nil, fmt.Errorf("geo: line %d: invalid zipcode %q", i+2, record[0])
+		}
+		lat, err := strconv.ParseFloat(record[4], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geo: line %d: invalid latitude: %w", i+2, err)
+		}
+		lon, err := strconv.ParseFloat(record[5], 64)
+		if err != nil {
+			return nil, fmt.Errorf("geo: line %d: invalid longitude: %w", i+2, err)
+		}
+
+		z := &Zipcode{
+			Zipcode:   record[0],
+			City:      record[1],
+			State:     record[2],
+			County:    record[3],
+			Latitude:  lat,
+			Longitude: lon,
+			Timezone:  record[6],
+		}
+		db.byZip[z.Zipcode] = z
+		db.ordered = append(db.ordered, z)
+	}
+
+	return db, nil
+}
+
+// ValidFormat reports whether s looks like a 5-digit ZIP code
+func ValidFormat(s string) bool {
+	return zipcodePattern.MatchString(s)
+}
+
+// Lookup returns the zipcode record, or false if it is not in the dataset
+func (db *ZipcodeDB) Lookup(zipcode string) (*Zipcode, bool) {
+	z, ok := db.byZip[strings.TrimSpace(zipcode)]
+	return z, ok
+}
+
+// Len returns the number of zipcodes loaded
+func (db *ZipcodeDB) Len() int {
+	return len(db.ordered)
+}
+
+// Nearest returns up to n zipcodes closest to the point, nearest first
+func (db *ZipcodeDB) Nearest(lat, lon float64, n int) []*Zipcode {
+	type candidate struct {
+		zip      *Zipcode
+		distance float64
+	}
+
+	candidates := make([]candidate, 0, len(db.ordered))
+	for _, z := range db.ordered {
+		candidates = append(candidates, candidate{z, DistanceMiles(lat, lon, z.Latitude, z.Longitude)})
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].distance < candidates[j].distance
+	})
+
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	nearest := make([]*Zipcode, 0, n)
+	for _, c := range candidates[:n] {
+		nearest = append(nearest, c.zip)
+	}
+	return nearest
+}
+
+// DistanceMiles is the great-circle (haversine) distance between two points
+func DistanceMiles(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMiles * math.Asin(math.Sqrt(a))
+}
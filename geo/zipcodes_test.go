@@ -0,0 +1,68 @@
+package geo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	db, err := Load(strings.NewReader(zipcodesCSV))
+	if err != nil {
+		t.Fatal(err)
+	}
+	z, ok := db.Lookup("94102")
+	if !ok || z.City != "San Francisco" || z.State != "CA" {
+		t.Errorf("Lookup(94102) = %+v, %t", z, ok)
+	}
+}
+
+func TestLoadHeader(t *testing.T) {
+	row := "\n94102,San Francisco,CA,San Francisco,37.7793,-122.4193,America/Los_Angeles\n"
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"zipcode,city,state,county,latitude,longitude,timezone", true},
+		{"\ufeffZipcode, City,State,County,Latitude,Longitude,Timezone", true},
+		{"zipcode,city,state,county,longitude,latitude,timezone", false},
+		{"zip,city,state,county,latitude,longitude,timezone", false},
+	}
+	for _, tt := range tests {
+		_, err := Load(strings.NewReader(tt.header + row))
+		if ok := err == nil; ok != tt.ok {
+			t.Errorf("Load with header %q: error %v, want ok = %t", tt.header, err, tt.ok)
+		}
+	}
+}
+
+func TestLoadRejectsTruncatedZipcodes(t *testing.T) {
+	data := "zipcode,city,state,county,latitude,longitude,timezone\n2108,Boston,MA,Suffolk,42.3576,-71.0637,America/New_York\n"
+	if _, err := Load(strings.NewReader(data)); err == nil {
+		t.Error("Load accepted the 4-digit zipcode 2108")
+	}
+}
+
+// A configured file replaces the embedded dataset rather than adding to it
+func TestOpenFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zipcodes.csv")
+	data := "zipcode,city,state,county,latitude,longitude,timezone\n00501,Holtsville,NY,Suffolk,40.8154,-73.0451,America/New_York\n"
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := db.Lookup("00501"); !ok {
+		t.Error("00501 from the file is missing")
+	}
+	if _, ok := db.Lookup("94102"); ok {
+		t.Error("94102 from the embedded dataset is still present")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.csv")); err == nil {
+		t.Error("Open of a missing file succeeded")
+	}
+}
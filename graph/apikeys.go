@@ -0,0 +1,88 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// newAPIKey validates input and generates the key it describes
+func (r *Resolver) newAPIKey(input model.CreateAPIKeyInput) (*auth.Key, string, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, "", badInput("name must not be empty")
+	}
+	if len(input.Scopes) == 0 {
+		return nil, "", badInput("at least one scope is required")
+	}
+
+	rateLimit, dailyQuota := r.authConfig.DefaultRateLimit, r.authConfig.DefaultDailyQuota
+	if input.RateLimitPerMinute != nil {
+		rateLimit = *input.RateLimitPerMinute
+	}
+	if input.DailyQuota != nil {
+		dailyQuota = *input.DailyQuota
+	}
+	if rateLimit < 0 || dailyQuota < 0 {
+		return nil, "", badInput("limits must not be negative")
+	}
+	if input.ExpiresInDays != nil && *input.ExpiresInDays < 1 {
+		return nil, "", badInput("expiresInDays must be at least 1")
+	}
+
+	scopes := make([]auth.Scope, 0, len(input.Scopes))
+	for _, s := range input.Scopes {
+		scopes = append(scopes, auth.Scope(strings.ToLower(s.String())))
+	}
+	key, plaintext, err := auth.NewKey(name, scopes, rateLimit, dailyQuota)
+	if err != nil {
+		return nil, "", err
+	}
+	if input.ExpiresInDays != nil {
+		expiresAt := key.CreatedAt.AddDate(0, 0, *input.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	return key, plaintext, nil
+}
+
+// requireRead checks the READ scope that the price, history and location
+// queries need, letting anonymous requests through when keys aren't required
+func (r *Resolver) requireRead(ctx context.Context) error {
+	return auth.RequireRead(ctx, r.authConfig.Required)
+}
+
+func apiKeyModel(k *auth.Key) *model.APIKey {
+	key := &model.APIKey{
+		ID:                 k.ID,
+		Name:               k.Name,
+		Prefix:             k.Prefix,
+		RateLimitPerMinute: k.RateLimitPerMinute,
+		DailyQuota:         k.DailyQuota,
+		CreatedAt:          k.CreatedAt.Format(time.RFC3339),
+		RevokedAt:          formatTime(derefTime(k.RevokedAt)),
+		ExpiresAt:          formatTime(derefTime(k.ExpiresAt)),
+	}
+	for _, s := range k.Scopes {
+		key.Scopes = append(key.Scopes, model.APIKeyScope(strings.ToUpper(string(s))))
+	}
+	return key
+}
+
+func derefTime(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
+
+// badInput is a client error that ErrorPresenter passes through as is
+func badInput(message string) error {
+	return &gqlerror.Error{
+		Message:    message,
+		Extensions: map[string]interface{}{"code": "BAD_USER_INPUT"},
+	}
+}
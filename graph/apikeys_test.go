@@ -0,0 +1,87 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/history"
+)
+
+func TestCreateAPIKeyExpiry(t *testing.T) {
+	r := &Resolver{keys: auth.NewMemoryStore(), authConfig: config.Default().Auth}
+	m := &mutationResolver{r}
+	admin := auth.WithKey(context.Background(), &auth.Key{ID: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	days := 30
+
+	created, err := m.CreateAPIKey(admin, model.CreateAPIKeyInput{Name: "grafana", Scopes: []model.APIKeyScope{model.APIKeyScopeRead}, ExpiresInDays: &days})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiresAt, err := time.Parse(time.RFC3339, *created.APIKey.ExpiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expiresAt); d < 29*24*time.Hour || d > 30*24*time.Hour {
+		t.Errorf("expiresAt = %s, want 30 days from now", expiresAt)
+	}
+
+	unlimited, err := m.CreateAPIKey(admin, model.CreateAPIKeyInput{Name: "ci", Scopes: []model.APIKeyScope{model.APIKeyScopeRead}})
+	if err != nil || unlimited.APIKey.ExpiresAt != nil {
+		t.Errorf("without expiresInDays: %+v, %v; want no expiry", unlimited, err)
+	}
+
+	zero := 0
+	if _, err := m.CreateAPIKey(admin, model.CreateAPIKeyInput{Name: "ci", Scopes: []model.APIKeyScope{model.APIKeyScopeRead}, ExpiresInDays: &zero}); err == nil {
+		t.Error("expiresInDays: 0 accepted")
+	}
+}
+
+// The price queries need the READ scope, which anonymous requests only get
+// while keys aren't required
+func TestPriceQueriesRequireRead(t *testing.T) {
+	cfg := config.Default()
+	r := &Resolver{history: history.NewMemoryStore(cfg.History), historyCfg: cfg.History, zipcodeDB: embeddedZipcodes(t)}
+	q := &queryResolver{r}
+	unscoped := auth.WithKey(context.Background(), &auth.Key{ID: "unscoped"})
+	reader := auth.WithKey(context.Background(), &auth.Key{ID: "reader", Scopes: []auth.Scope{auth.ScopeRead}})
+	anonymous := context.Background()
+
+	queries := map[string]func(context.Context) error{
+		"priceHistory": func(ctx context.Context) error {
+			_, err := q.PriceHistory(ctx, nil, nil, nil)
+			return err
+		},
+		"location": func(ctx context.Context) error {
+			_, err := q.Location(ctx, "94102")
+			return err
+		},
+		// Refused before any retailer is called, so none is configured
+		"eggPrices": func(ctx context.Context) error {
+			_, err := q.EggPrices(ctx, "94102", nil)
+			return err
+		},
+	}
+	for field, query := range queries {
+		if err := query(unscoped); !errors.Is(err, auth.ErrForbidden) {
+			t.Errorf("%s with a key lacking READ = %v, want ErrForbidden", field, err)
+		}
+		r.authConfig.Required = true
+		if err := query(anonymous); !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Errorf("%s anonymously with keys required = %v, want ErrUnauthenticated", field, err)
+		}
+		r.authConfig.Required = false
+		if field == "eggPrices" {
+			continue
+		}
+		for name, ctx := range map[string]context.Context{"READ key": reader, "anonymous": anonymous} {
+			if err := query(ctx); err != nil {
+				t.Errorf("%s with %s: %v", field, name, err)
+			}
+		}
+	}
+}
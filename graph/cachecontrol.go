@@ -0,0 +1,169 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// locationMaxAge is how long a location may be cached; the zipcode dataset
+// only changes with a deploy
+const locationMaxAge = 24 * time.Hour
+
+// cachePolicy collects how long one response may be cached. Each resolver
+// that can be cached lowers maxAge to what its data allows; a response that
+// no resolver vouched for, or that any resolver marked private, isn't cached.
+type cachePolicy struct {
+	mu      sync.Mutex
+	set     bool
+	noStore bool
+	maxAge  time.Duration
+}
+
+type cachePolicyKey struct{}
+
+func withCachePolicy(ctx context.Context) (context.Context, *cachePolicy) {
+	p := &cachePolicy{}
+	return context.WithValue(ctx, cachePolicyKey{}, p), p
+}
+
+// cacheFor allows the response to be cached for at most maxAge. Outside a
+// CacheHeaders request, such as a scheduled refresh, it does nothing.
+func cacheFor(ctx context.Context, maxAge time.Duration) {
+	p, ok := ctx.Value(cachePolicyKey{}).(*cachePolicy)
+	if !ok {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.set || maxAge < p.maxAge {
+		p.maxAge = maxAge
+	}
+	p.set = true
+}
+
+// noStore keeps the response out of every cache
+func noStore(ctx context.Context) {
+	if p, ok := ctx.Value(cachePolicyKey{}).(*cachePolicy); ok {
+		p.mu.Lock()
+		p.noStore = true
+		p.mu.Unlock()
+	}
+}
+
+// header returns the Cache-Control value for the collected policy. A
+// response to a request with credentials is private, so shared caches such
+// as CDNs don't keep it: one would serve it without checking the key's
+// rate limit, quota or revocation.
+func (p *cachePolicy) header(credentials bool) (string, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	seconds := int(p.maxAge / time.Second)
+	if p.noStore || !p.set || seconds <= 0 {
+		return "no-store", false
+	}
+	scope := "public"
+	if credentials {
+		scope = "private"
+	}
+	return fmt.Sprintf("%s, max-age=%d", scope, seconds), true
+}
+
+// cacheFreshPrices allows a price comparison to be cached until its oldest
+// price is maxAge old
+func cacheFreshPrices(ctx context.Context, maxAge time.Duration, stores ...[]*model.RetailerPrice) {
+	oldest := 0
+	for _, prices := range stores {
+		for _, p := range prices {
+			oldest = max(oldest, p.AgeSeconds())
+		}
+	}
+	cacheFor(ctx, maxAge-time.Duration(oldest)*time.Second)
+}
+
+// CacheHeaders sets Cache-Control and ETag on GET responses from next, the
+// only ones a CDN will cache, and answers a matching If-None-Match with 304.
+// How long a response may be cached comes from the resolvers that built it;
+// see cacheFor. Responses to requests with an API key are private; all vary
+// by API key and mock scenario, which change what a client may see.
+func CacheHeaders(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx, policy := withCachePolicy(r.Context())
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(ctx))
+
+		w.Header().Add("Vary", "Authorization, "+auth.APIKeyHeader+", "+api.MockScenarioHeader)
+		credentials := r.Header.Get("Authorization") != "" || r.Header.Get(auth.APIKeyHeader) != ""
+		cacheControl, cacheable := policy.header(credentials)
+		if rec.status != http.StatusOK {
+			cacheControl, cacheable = "no-store", false
+		}
+		w.Header().Set("Cache-Control", cacheControl)
+		if cacheable {
+			sum := sha256.Sum256(rec.body.Bytes())
+			etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+			w.Header().Set("ETag", etag)
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.WriteHeader(rec.status)
+		w.Write(rec.body.Bytes())
+	})
+}
+
+// responseRecorder holds a response back until its cache headers are known
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// cacheControl keeps responses with errors out of caches, so a transient
+// provider failure isn't served until it expires
+type cacheControl struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = cacheControl{}
+
+func (cacheControl) ExtensionName() string {
+	return "CacheControl"
+}
+
+func (cacheControl) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (cacheControl) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		noStore(ctx)
+	}
+	return resp
+}
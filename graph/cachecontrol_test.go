@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/jkzilla/egg-price-compare/tracked"
+)
+
+const locationQuery = `query Location { location(zipcode: "94102") { city } }`
+
+// newCachedHandler serves /graphql as server.go does, minus auth, with
+// locationQuery already persisted
+func newCachedHandler(t *testing.T) http.Handler {
+	t.Helper()
+	cfg := config.Default()
+	prices := cache.NewGroup("prices", cache.NewMemoryCache(), nil, cfg.Cache.LockTimeout)
+	resolver, err := NewResolver(cfg, history.NewMemoryStore(cfg.History), auth.NewMemoryStore(), tracked.NewMemoryStore(), prices, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := CacheHeaders(NewHandler(resolver, cfg, persisted.NewMemoryStore(10)))
+
+	body := `{"query":` + strconv.Quote(locationQuery) + `,"extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + queryHash() + `"}}}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("registering the query: %d %s", rec.Code, rec.Body)
+	}
+	return handler
+}
+
+func queryHash() string {
+	sum := sha256.Sum256([]byte(locationQuery))
+	return hex.EncodeToString(sum[:])
+}
+
+func getPersisted(header map[string]string) *http.Request {
+	params := url.Values{"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"` + queryHash() + `"}}`}}
+	req := httptest.NewRequest(http.MethodGet, "/graphql?"+params.Encode(), nil)
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestCacheControlByCredentials(t *testing.T) {
+	handler := newCachedHandler(t)
+	tests := []struct {
+		name   string
+		header map[string]string
+		want   string
+	}{
+		{"anonymous", nil, "public, max-age=86400"},
+		{"api key", map[string]string{auth.APIKeyHeader: "key"}, "private, max-age=86400"},
+		{"bearer", map[string]string{"Authorization": "Bearer key"}, "private, max-age=86400"},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, getPersisted(tt.header))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: got %d: %s", tt.name, rec.Code, rec.Body)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.want {
+			t.Errorf("%s: Cache-Control = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestGETRequiresPersistedQuery(t *testing.T) {
+	handler := newCachedHandler(t)
+	req := httptest.NewRequest(http.MethodGet, "/graphql?"+url.Values{"query": {locationQuery}}.Encode(), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "PERSISTED_QUERY_REQUIRED") {
+		t.Errorf("got %d: %s, want PERSISTED_QUERY_REQUIRED", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+}
+
+// An unknown hash asks the client to register the query with POST, and the
+// answer must not be cached or the query could never be registered
+func TestGETUnknownPersistedQuery(t *testing.T) {
+	handler := newCachedHandler(t)
+	params := url.Values{"extensions": {`{"persistedQuery":{"version":1,"sha256Hash":"` + strings.Repeat("0", 64) + `"}}`}}
+	req := httptest.NewRequest(http.MethodGet, "/graphql?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if !strings.Contains(rec.Body.String(), `"message":"PersistedQueryNotFound"`) {
+		t.Errorf("got %d: %s, want PersistedQueryNotFound", rec.Code, rec.Body)
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", got)
+	}
+}
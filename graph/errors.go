@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// errorCodes maps api error kinds to extensions.code values, the message
+// clients see and whether the same request may succeed if retried later.
+// Checked in order, so a joined error from several providers reports its
+// most actionable kind.
+var errorCodes = []errorCodeEntry{
+	{auth.ErrUnauthenticated, "UNAUTHENTICATED", "A valid API key is required", false},
+	{auth.ErrForbidden, "FORBIDDEN", "This API key is not allowed to do that", false},
+	{auth.ErrKeyNotFound, "NOT_FOUND", "API key not found", false},
+	{api.ErrInvalidZipcode, "INVALID_ZIPCODE", "", false},
+	{api.ErrAuthMisconfigured, "AUTH_MISCONFIGURED", "A retailer integration is misconfigured", false},
+	{api.ErrRateLimited, "RATE_LIMITED", "A retailer is rate limiting requests, try again shortly", true},
+	{api.ErrUpstreamUnavailable, "UPSTREAM_UNAVAILABLE", "A retailer is temporarily unavailable", true},
+	{api.ErrNotFound, "NOT_FOUND", "No egg products were found", false},
+}
+
+// ErrorPresenter replaces resolver errors with a stable extensions.code and a
+// fixed message. Upstream details such as response bodies are only logged.
+func ErrorPresenter(ctx context.Context, err error) *gqlerror.Error {
+	gqlErr := graphql.DefaultErrorPresenter(ctx, err)
+	if _, ok := gqlErr.Extensions["code"]; ok {
+		// Parser and validation errors are already coded and safe to show
+		return gqlErr
+	}
+
+	extensions := map[string]interface{}{"code": "INTERNAL_SERVER_ERROR"}
+	message := "Internal server error"
+
+	var zipErr *api.InvalidZipcodeError
+	var inputErr *api.InputError
+	switch {
+	case isIntrospection(gqlErr.Path):
+		// The only way __schema and __type fail is introspection being off
+		extensions["code"] = "INTROSPECTION_DISABLED"
+		message = "Introspection is disabled"
+	case errors.As(err, &zipErr):
+		extensions["code"] = "INVALID_ZIPCODE"
+		extensions["zipcode"] = zipErr.Zipcode
+		message = zipErr.Reason
+	case errors.As(err, &inputErr):
+		extensions["code"] = "BAD_USER_INPUT"
+		extensions["field"] = inputErr.Field
+		message = inputErr.Reason
+	default:
+		kind := errorKind(err)
+		extensions["code"], message = errorCode(err)
+		extensions["retryable"] = kind != nil && kind.retryable
+		if kind != nil {
+			if provider := providerOf(err, kind.kind); provider != "" {
+				extensions["provider"] = provider
+			}
+		}
+		cause := err
+		if gqlErr.Err != nil {
+			cause = gqlErr.Err
+		}
+		level := slog.LevelWarn
+		if extensions["code"] == "INTERNAL_SERVER_ERROR" {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "graphql error", "code", extensions["code"], "path", gqlErr.Path.String(), "error", cause)
+	}
+
+	gqlErr.Message = message
+	gqlErr.Extensions = extensions
+	return gqlErr
+}
+
+type errorCodeEntry struct {
+	kind      error
+	code      string
+	message   string
+	retryable bool
+}
+
+// errorKind returns the first errorCodes entry err matches, or nil
+func errorKind(err error) *errorCodeEntry {
+	for i := range errorCodes {
+		if errors.Is(err, errorCodes[i].kind) {
+			return &errorCodes[i]
+		}
+	}
+	return nil
+}
+
+// errorCode returns the extensions.code and client message for err
+func errorCode(err error) (string, string) {
+	if kind := errorKind(err); kind != nil {
+		return kind.code, kind.message
+	}
+	return "INTERNAL_SERVER_ERROR", "Internal server error"
+}
+
+// providerOf returns the provider of the first ProviderError of kind in
+// err's tree, so a joined error names the provider behind its code
+func providerOf(err error, kind error) string {
+	switch e := err.(type) {
+	case *api.ProviderError:
+		if errors.Is(e.Kind, kind) {
+			return e.Provider
+		}
+		return providerOf(e.Err, kind)
+	case interface{ Unwrap() error }:
+		return providerOf(e.Unwrap(), kind)
+	case interface{ Unwrap() []error }:
+		for _, inner := range e.Unwrap() {
+			if provider := providerOf(inner, kind); provider != "" {
+				return provider
+			}
+		}
+	}
+	return ""
+}
+
+func isIntrospection(path ast.Path) bool {
+	if len(path) != 1 {
+		return false
+	}
+	name, ok := path[0].(ast.PathName)
+	return ok && (name == "__schema" || name == "__type")
+}
@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+)
+
+func TestErrorPresenterBadInput(t *testing.T) {
+	err := fmt.Errorf("failed to get Walmart price: %w", &api.InputError{Field: "X-Mock-Scenario", Reason: "unknown mock scenario"})
+	gqlErr := ErrorPresenter(context.Background(), err)
+	if gqlErr.Extensions["code"] != "BAD_USER_INPUT" || gqlErr.Extensions["field"] != "X-Mock-Scenario" {
+		t.Errorf("extensions = %v", gqlErr.Extensions)
+	}
+	if gqlErr.Message != "unknown mock scenario" {
+		t.Errorf("message = %q", gqlErr.Message)
+	}
+}
+
+// leakyBody is an upstream response that echoes the request URL, with its
+// API key, back in an error body
+const leakyBody = `{"error":"forbidden","request":"https://serpapi.com/search?api_key=sk-live-4f9a8b7c6d5e&q=eggs"}`
+
+func TestErrorPresenterCodes(t *testing.T) {
+	upstream := func(provider string, kind error, status int) error {
+		err := &api.ProviderError{Provider: provider, Kind: kind, StatusCode: status,
+			Err: fmt.Errorf("%s: API returned status %d: %s", provider, status, leakyBody)}
+		return fmt.Errorf("failed to get Walmart price: %w", err)
+	}
+	tests := []struct {
+		name      string
+		err       error
+		code      string
+		message   string
+		provider  string
+		retryable bool
+	}{
+		{"unavailable", upstream("walmart", api.ErrUpstreamUnavailable, http.StatusBadGateway),
+			"UPSTREAM_UNAVAILABLE", "A retailer is temporarily unavailable", "walmart", true},
+		{"rate limited", upstream("serpapi", api.ErrRateLimited, http.StatusTooManyRequests),
+			"RATE_LIMITED", "A retailer is rate limiting requests, try again shortly", "serpapi", true},
+		{"not found", upstream("searchapi", api.ErrNotFound, http.StatusNotFound),
+			"NOT_FOUND", "No egg products were found", "searchapi", false},
+		{"auth misconfigured", upstream("walgreens", api.ErrAuthMisconfigured, http.StatusForbidden),
+			"AUTH_MISCONFIGURED", "A retailer integration is misconfigured", "walgreens", false},
+		// Several providers failing report the most actionable kind
+		{"joined", errors.Join(upstream("walmart", api.ErrUpstreamUnavailable, 0), upstream("walgreens", api.ErrAuthMisconfigured, 401)),
+			"AUTH_MISCONFIGURED", "A retailer integration is misconfigured", "walgreens", false},
+		{"forbidden", auth.ErrForbidden, "FORBIDDEN", "This API key is not allowed to do that", "", false},
+		{"internal", errors.New("pq: connection refused to postgres://app:hunter2@db/prices"),
+			"INTERNAL_SERVER_ERROR", "Internal server error", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gqlErr := ErrorPresenter(context.Background(), tt.err)
+			if gqlErr.Extensions["code"] != tt.code || gqlErr.Message != tt.message {
+				t.Errorf("got %v %q, want %s %q", gqlErr.Extensions["code"], gqlErr.Message, tt.code, tt.message)
+			}
+			if gqlErr.Extensions["retryable"] != tt.retryable {
+				t.Errorf("retryable = %v, want %t", gqlErr.Extensions["retryable"], tt.retryable)
+			}
+			if provider, ok := gqlErr.Extensions["provider"]; tt.provider != "" && provider != tt.provider || tt.provider == "" && ok {
+				t.Errorf("provider = %v, want %q", provider, tt.provider)
+			}
+			for _, leak := range []string{"sk-live", "api_key", "status", "hunter2", "forbidden"} {
+				if strings.Contains(gqlErr.Message, leak) {
+					t.Errorf("message %q leaks %q from the upstream error", gqlErr.Message, leak)
+				}
+			}
+		})
+	}
+}
+
+func TestErrorPresenterInvalidZipcode(t *testing.T) {
+	r := &Resolver{zipcodeDB: embeddedZipcodes(t)}
+	gqlErr := ErrorPresenter(context.Background(), r.validateZipcode("9410"))
+	if gqlErr.Extensions["code"] != "INVALID_ZIPCODE" || gqlErr.Extensions["zipcode"] != "9410" {
+		t.Errorf("extensions = %v", gqlErr.Extensions)
+	}
+}
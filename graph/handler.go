@@ -0,0 +1,89 @@
+package graph
+
+import (
+	"net/http"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/logging"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/rs/cors"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// NewHandler builds the /graphql handler with the depth and complexity
+// limits in cfg, keeping persisted queries in queries. It matches
+// handler.NewDefaultServer except that there is no websocket transport,
+// since the schema has no subscriptions, and introspection is only enabled
+// outside production.
+func NewHandler(resolver *Resolver, cfg *config.Config, queries persisted.Store) *handler.Server {
+	srv := handler.New(NewExecutableSchema(Config{
+		Resolvers:  resolver,
+		Complexity: complexity(),
+	}))
+
+	srv.AddTransport(transport.Options{})
+	srv.AddTransport(persistedGET{})
+	srv.AddTransport(transport.POST{})
+	srv.SetQueryCache(lru.New[*ast.QueryDocument](1000))
+
+	if !cfg.Production() {
+		srv.Use(extension.Introspection{})
+	}
+	srv.Use(extension.AutomaticPersistedQuery{Cache: queries})
+	srv.Use(DepthLimit{Max: cfg.GraphQL.DepthLimit})
+	srv.Use(extension.FixedComplexityLimit(cfg.GraphQL.ComplexityLimit))
+	srv.Use(cacheControl{})
+
+	srv.SetErrorPresenter(ErrorPresenter)
+	return srv
+}
+
+// persistedGET only runs persisted queries over GET. A GET response can be
+// cached by a CDN, so a full query in the URL would let any client fill the
+// cache with arbitrary variants; clients register queries with POST first.
+type persistedGET struct {
+	transport.GET
+}
+
+func (t persistedGET) Do(w http.ResponseWriter, r *http.Request, exec graphql.GraphExecutor) {
+	if r.URL.Query().Has("query") {
+		w.Header().Set("Content-Type", "application/json")
+		transport.SendError(w, http.StatusBadRequest, &gqlerror.Error{
+			Message:    "GET requests must send a persisted query hash, not a query; use POST for full queries",
+			Extensions: map[string]interface{}{"code": "PERSISTED_QUERY_REQUIRED"},
+		})
+		return
+	}
+	t.GET.Do(w, r, exec)
+}
+
+// CORS applies the cross-origin policy for /graphql to next. Clients
+// authenticate with a header, not cookies, so browsers never need to send
+// credentials. With no allowed origins next is returned as is, since
+// cors.New would treat an empty list as "*".
+func CORS(allowedOrigins []string, next http.Handler) http.Handler {
+	if len(allowedOrigins) == 0 {
+		return next
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: allowedOrigins,
+		AllowedMethods: []string{http.MethodGet, http.MethodPost, http.MethodOptions},
+		AllowedHeaders: []string{
+			"Content-Type",
+			"Authorization",
+			auth.APIKeyHeader,
+			logging.RequestIDHeader,
+			api.MockScenarioHeader,
+			"traceparent",
+			"tracestate",
+		},
+	}).Handler(next)
+}
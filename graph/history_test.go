@@ -0,0 +1,42 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/history"
+)
+
+func TestPriceHistoryResolution(t *testing.T) {
+	cfg := config.Default().History
+	store := history.NewMemoryStore(cfg)
+	ctx := context.Background()
+	store.RecordComparison(ctx, history.Comparison{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: time.Now()})
+	q := &queryResolver{&Resolver{history: store, historyCfg: cfg}}
+
+	// Daily points stay the default
+	entries, err := q.PriceHistory(ctx, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Resolution != model.HistoryResolutionDaily {
+		t.Errorf("default priceHistory = %+v, want one DAILY entry", entries)
+	}
+
+	hourly := model.HistoryResolutionHourly
+	entries, err = q.PriceHistory(ctx, nil, nil, &hourly)
+	if err != nil || len(entries) != 1 || entries[0].Resolution != hourly {
+		t.Errorf("hourly priceHistory = %+v, %v; want one HOURLY entry", entries, err)
+	}
+
+	raw, days := model.HistoryResolutionRaw, 3
+	var inputErr *api.InputError
+	if _, err := q.PriceHistory(ctx, &days, nil, &raw); !errors.As(err, &inputErr) || inputErr.Field != "days" {
+		t.Errorf("3 days of RAW history = %v, want an input error on days", err)
+	}
+}
@@ -0,0 +1,123 @@
+package graph
+
+import (
+	"context"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/99designs/gqlgen/graphql/errcode"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/gqlerror"
+)
+
+// Every selected field costs 1 plus its children. These fields cost more
+// because of the work behind them.
+const (
+	// eggPricesCost covers a live lookup at every retailer, which can fall
+	// through to paid third-party sources
+	eggPricesCost = 200
+	// locationCost covers a nearest-neighbour search over the zipcode table
+	locationCost = 10
+	// historyDaysPerUnit is how many days of daily priceHistory are charged
+	// as one copy of the selected entry fields. A day of hourly or raw
+	// points is charged as one copy.
+	historyDaysPerUnit = 7
+)
+
+// complexity returns the per-field costs used by the complexity limit
+func complexity() ComplexityRoot {
+	var c ComplexityRoot
+	c.Query.EggPrices = func(childComplexity int, zipcode string, radiusMiles *float64) int {
+		return eggPricesCost + childComplexity
+	}
+	c.Query.Location = func(childComplexity int, zipcode string) int {
+		return locationCost + childComplexity
+	}
+	c.Query.PriceHistory = func(childComplexity int, days *int, zipcode *string, resolution *model.HistoryResolution) int {
+		numDays := 7
+		if days != nil && *days > 0 {
+			numDays = *days
+		}
+		units := numDays
+		if resolution == nil || *resolution == model.HistoryResolutionDaily {
+			units = (numDays + historyDaysPerUnit - 1) / historyDaysPerUnit
+		}
+		return 1 + childComplexity*units
+	}
+	return c
+}
+
+const (
+	errDepthLimit      = "DEPTH_LIMIT_EXCEEDED"
+	errComplexityLimit = "COMPLEXITY_LIMIT_EXCEEDED"
+)
+
+// Both limits refuse the operation before it runs, so they answer 422 like
+// a validation failure rather than 200 with a field error
+func init() {
+	errcode.RegisterErrorType(errDepthLimit, errcode.KindProtocol)
+	errcode.RegisterErrorType(errComplexityLimit, errcode.KindProtocol)
+}
+
+// DepthLimit rejects operations whose selections nest deeper than Max.
+// Fragments count as the selections they contain. Introspection fields are
+// skipped: the playground's schema query is deep but cheap, and production
+// turns introspection off entirely.
+type DepthLimit struct {
+	Max int
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.OperationContextMutator
+} = DepthLimit{}
+
+func (DepthLimit) ExtensionName() string {
+	return "DepthLimit"
+}
+
+func (DepthLimit) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (d DepthLimit) MutateOperationContext(ctx context.Context, opCtx *graphql.OperationContext) *gqlerror.Error {
+	op := opCtx.Doc.Operations.ForName(opCtx.OperationName)
+	if op == nil {
+		return nil
+	}
+	if depth := selectionDepth(op.SelectionSet, map[string]bool{}); depth > d.Max {
+		err := gqlerror.Errorf("operation has depth %d, which exceeds the limit of %d", depth, d.Max)
+		errcode.Set(err, errDepthLimit)
+		return err
+	}
+	return nil
+}
+
+// selectionDepth returns how many fields deep set nests. visiting guards
+// against fragment cycles, which validation rejects but costs nothing to
+// handle here.
+func selectionDepth(set ast.SelectionSet, visiting map[string]bool) int {
+	depth := 0
+	for _, sel := range set {
+		var d int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			if strings.HasPrefix(sel.Name, "__") {
+				continue
+			}
+			d = 1 + selectionDepth(sel.SelectionSet, visiting)
+		case *ast.InlineFragment:
+			d = selectionDepth(sel.SelectionSet, visiting)
+		case *ast.FragmentSpread:
+			if sel.Definition == nil || visiting[sel.Name] {
+				continue
+			}
+			visiting[sel.Name] = true
+			d = selectionDepth(sel.Definition.SelectionSet, visiting)
+			delete(visiting, sel.Name)
+		}
+		depth = max(depth, d)
+	}
+	return depth
+}
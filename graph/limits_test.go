@@ -0,0 +1,104 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/jkzilla/egg-price-compare/tracked"
+)
+
+// newLimitedHandler serves /graphql with cfg's limits and no retailers
+func newLimitedHandler(t *testing.T, cfg *config.Config) http.Handler {
+	t.Helper()
+	prices := cache.NewGroup("prices", cache.NewMemoryCache(), nil, cfg.Cache.LockTimeout)
+	resolver, err := NewResolver(cfg, history.NewMemoryStore(cfg.History), auth.NewMemoryStore(), tracked.NewMemoryStore(), prices, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewHandler(resolver, cfg, persisted.NewMemoryStore(10))
+}
+
+// postQuery POSTs query and returns the status and the error codes in the
+// response
+func postQuery(t *testing.T, h http.Handler, query string) (int, []string) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{"query":`+strconv.Quote(query)+`}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	var body struct {
+		Errors []struct {
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body %q: %v", rec.Body, err)
+	}
+	var codes []string
+	for _, e := range body.Errors {
+		code, _ := e.Extensions["code"].(string)
+		codes = append(codes, code)
+	}
+	return rec.Code, codes
+}
+
+func TestQueryLimits(t *testing.T) {
+	cfg := config.Default()
+	cfg.GraphQL.DepthLimit = 2
+	h := newLimitedHandler(t, cfg)
+
+	tests := []struct {
+		name   string
+		query  string
+		status int
+		code   string
+	}{
+		{"within limits", `{ location(zipcode: "94102") { city } }`, http.StatusOK, ""},
+		{"too deep", `{ priceHistory { walmart { open } } }`, http.StatusUnprocessableEntity, errDepthLimit},
+		{"too deep through a fragment", `{ ...H } fragment H on Query { priceHistory { ... on PriceHistoryEntry { walmart { open } } } }`, http.StatusUnprocessableEntity, errDepthLimit},
+		// The playground's schema query nests far deeper than this
+		{"introspection is not counted", `{ __schema { types { fields { type { ofType { name } } } } } }`, http.StatusOK, ""},
+		{"over budget", `{ a: eggPrices(zipcode: "94102") { lastUpdated } b: eggPrices(zipcode: "94103") { lastUpdated } c: eggPrices(zipcode: "94104") { lastUpdated } d: eggPrices(zipcode: "94105") { lastUpdated } e: eggPrices(zipcode: "94107") { lastUpdated } }`, http.StatusUnprocessableEntity, errComplexityLimit},
+		{"a year of daily history", `{ priceHistory(days: 365) { date } }`, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, codes := postQuery(t, h, tt.query)
+			if status != tt.status {
+				t.Errorf("status = %d, want %d", status, tt.status)
+			}
+			if tt.code == "" && len(codes) > 0 {
+				t.Errorf("errors %v, want none", codes)
+			}
+			if tt.code != "" && (len(codes) != 1 || codes[0] != tt.code) {
+				t.Errorf("errors %v, want %s", codes, tt.code)
+			}
+		})
+	}
+}
+
+// Introspection is on for the playground and codegen outside production,
+// where it's off and reported with a stable code
+func TestIntrospectionByEnvironment(t *testing.T) {
+	const query = `{ __schema { queryType { name } } }`
+
+	if status, codes := postQuery(t, newLimitedHandler(t, config.Default()), query); status != http.StatusOK || len(codes) > 0 {
+		t.Errorf("development: %d %v, want 200 and no errors", status, codes)
+	}
+
+	cfg := config.Default()
+	cfg.Server.Environment = "production"
+	if _, codes := postQuery(t, newLimitedHandler(t, cfg), query); len(codes) != 1 || codes[0] != "INTROSPECTION_DISABLED" {
+		t.Errorf("production: errors %v, want INTROSPECTION_DISABLED", codes)
+	}
+}
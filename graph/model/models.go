@@ -1,11 +1,19 @@
 package model
 
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
 type EggPriceComparison struct {
-	Walmart         *RetailerPrice `json:"walmart"`
-	Walgreens       *RetailerPrice `json:"walgreens"`
-	Cheapest        string         `json:"cheapest"`
-	PriceDifference float64        `json:"priceDifference"`
-	LastUpdated     string         `json:"lastUpdated"`
+	Walmart         *RetailerPrice    `json:"walmart"`
+	Walgreens       *RetailerPrice    `json:"walgreens"`
+	Stores          []*RetailerStores `json:"stores"`
+	Cheapest        string            `json:"cheapest"`
+	PriceDifference float64           `json:"priceDifference"`
+	LastUpdated     string            `json:"lastUpdated"`
 }
 
 type RetailerPrice struct {
@@ -22,7 +30,97 @@ type RetailerPrice struct {
 	InStock       bool            `json:"inStock"`
 	PickupEta     *string         `json:"pickupEta,omitempty"`
 	DigitalOffers []*DigitalOffer `json:"digitalOffers,omitempty"`
+	Availability  *Availability   `json:"availability,omitempty"`
+	Location      *StoreLocation  `json:"location,omitempty"`
 	LastUpdated   string          `json:"lastUpdated"`
+
+	// Provenance: which provider produced the price and when
+	Source            string  `json:"source"`
+	FetchedAt         string  `json:"fetchedAt"`
+	IsMock            bool    `json:"isMock"`
+	ProviderRequestID *string `json:"providerRequestId,omitempty"`
+}
+
+// AgeSeconds is how long ago the price was fetched from its source, so a
+// price served from a cache or history reports its real age
+func (p *RetailerPrice) AgeSeconds() int {
+	fetchedAt, err := time.Parse(time.RFC3339, p.FetchedAt)
+	if err != nil {
+		return 0
+	}
+	return int(time.Since(fetchedAt).Seconds())
+}
+
+// RetailerStores lists store-level offers for one retailer, cheapest first
+type RetailerStores struct {
+	Retailer string           `json:"retailer"`
+	Stores   []*RetailerPrice `json:"stores"`
+}
+
+type StoreLocation struct {
+	StoreID       string   `json:"storeId"`
+	Name          string   `json:"name"`
+	Address       string   `json:"address"`
+	City          *string  `json:"city,omitempty"`
+	State         *string  `json:"state,omitempty"`
+	Zipcode       *string  `json:"zipcode,omitempty"`
+	Latitude      *float64 `json:"latitude,omitempty"`
+	Longitude     *float64 `json:"longitude,omitempty"`
+	DistanceMiles *float64 `json:"distanceMiles,omitempty"`
+	Hours         *string  `json:"hours,omitempty"`
+	Phone         *string  `json:"phone,omitempty"`
+}
+
+// Availability describes how a product can be obtained at a retailer.
+// Retailers fill in whichever channels their APIs report.
+type Availability struct {
+	InStore     bool       `json:"inStore"`
+	Pickup      bool       `json:"pickup"`
+	PickupReady bool       `json:"pickupReady"`
+	Delivery    bool       `json:"delivery"`
+	Shipping    bool       `json:"shipping"`
+	StockLevel  StockLevel `json:"stockLevel"`
+	Quantity    *int       `json:"quantity,omitempty"`
+}
+
+// StockLevel is a coarse band of on-hand quantity
+type StockLevel string
+
+const (
+	StockLevelOutOfStock StockLevel = "OUT_OF_STOCK"
+	StockLevelLow        StockLevel = "LOW"
+	StockLevelMedium     StockLevel = "MEDIUM"
+	StockLevelHigh       StockLevel = "HIGH"
+	StockLevelUnknown    StockLevel = "UNKNOWN"
+)
+
+func (e StockLevel) IsValid() bool {
+	switch e {
+	case StockLevelOutOfStock, StockLevelLow, StockLevelMedium, StockLevelHigh, StockLevelUnknown:
+		return true
+	}
+	return false
+}
+
+func (e StockLevel) String() string {
+	return string(e)
+}
+
+func (e *StockLevel) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = StockLevel(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid StockLevel", str)
+	}
+	return nil
+}
+
+func (e StockLevel) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
 
 type DigitalOffer struct {
@@ -31,6 +129,18 @@ type DigitalOffer struct {
 	DiscountAmount  *float64 `json:"discountAmount,omitempty"`
 	DiscountPercent *float64 `json:"discountPercent,omitempty"`
 	ExpiresAt       *string  `json:"expiresAt,omitempty"`
+	Clippable       bool     `json:"clippable"`
+}
+
+type Location struct {
+	Zipcode        string   `json:"zipcode"`
+	City           string   `json:"city"`
+	State          string   `json:"state"`
+	County         string   `json:"county"`
+	Latitude       float64  `json:"latitude"`
+	Longitude      float64  `json:"longitude"`
+	Timezone       string   `json:"timezone"`
+	NearbyZipcodes []string `json:"nearbyZipcodes"`
 }
 
 // Legacy type - kept for backward compatibility
@@ -43,8 +153,271 @@ type StorePrice struct {
 	LastUpdated string  `json:"lastUpdated"`
 }
 
+// PriceHistoryEntry is one point of the price comparison series: a single
+// comparison at RAW resolution, or an hour or day of them. Prices, sources
+// and FetchedAt are those of the period's last comparison; Date is the UTC
+// day PeriodStart falls on. Zipcode is nil for daily points recorded across
+// every zipcode before history was kept per zipcode.
 type PriceHistoryEntry struct {
-	Date           string  `json:"date"`
-	WalmartPrice   float64 `json:"walmartPrice"`
-	WalgreensPrice float64 `json:"walgreensPrice"`
+	Zipcode         *string           `json:"zipcode,omitempty"`
+	Date            string            `json:"date"`
+	WalmartPrice    float64           `json:"walmartPrice"`
+	WalgreensPrice  float64           `json:"walgreensPrice"`
+	WalmartSource   string            `json:"walmartSource"`
+	WalgreensSource string            `json:"walgreensSource"`
+	FetchedAt       string            `json:"fetchedAt"`
+	IsMock          bool              `json:"isMock"`
+	Resolution      HistoryResolution `json:"resolution"`
+	PeriodStart     string            `json:"periodStart"`
+	Walmart         *PriceRange       `json:"walmart"`
+	Walgreens       *PriceRange       `json:"walgreens"`
+	Samples         int               `json:"samples"`
+}
+
+// PriceRange is the open, high, low and close of a retailer's best price
+// over a period
+type PriceRange struct {
+	Open  float64 `json:"open"`
+	High  float64 `json:"high"`
+	Low   float64 `json:"low"`
+	Close float64 `json:"close"`
+}
+
+// HistoryResolution is how finely a priceHistory series is sampled
+type HistoryResolution string
+
+const (
+	HistoryResolutionRaw    HistoryResolution = "RAW"
+	HistoryResolutionHourly HistoryResolution = "HOURLY"
+	HistoryResolutionDaily  HistoryResolution = "DAILY"
+)
+
+func (e HistoryResolution) IsValid() bool {
+	switch e {
+	case HistoryResolutionRaw, HistoryResolutionHourly, HistoryResolutionDaily:
+		return true
+	}
+	return false
+}
+
+func (e HistoryResolution) String() string {
+	return string(e)
+}
+
+func (e *HistoryResolution) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = HistoryResolution(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid HistoryResolution", str)
+	}
+	return nil
+}
+
+func (e HistoryResolution) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// ProviderStatus reports how a retailer adapter is configured and how its
+// recent upstream calls went. Times are RFC3339 and nil until the first call.
+type ProviderStatus struct {
+	Provider      string       `json:"provider"`
+	Mode          ProviderMode `json:"mode"`
+	Configured    bool         `json:"configured"`
+	ConfigError   *string      `json:"configError,omitempty"`
+	LastSuccess   *string      `json:"lastSuccess,omitempty"`
+	LastError     *string      `json:"lastError,omitempty"`
+	LastErrorAt   *string      `json:"lastErrorAt,omitempty"`
+	LastErrorCode *string      `json:"lastErrorCode,omitempty"`
+	BreakerState  BreakerState `json:"breakerState"`
+}
+
+// ProviderMode is whether an adapter serves fixtures or live provider data
+type ProviderMode string
+
+const (
+	ProviderModeMock ProviderMode = "MOCK"
+	ProviderModeLive ProviderMode = "LIVE"
+)
+
+func (e ProviderMode) IsValid() bool {
+	switch e {
+	case ProviderModeMock, ProviderModeLive:
+		return true
+	}
+	return false
+}
+
+func (e ProviderMode) String() string {
+	return string(e)
+}
+
+func (e *ProviderMode) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = ProviderMode(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ProviderMode", str)
+	}
+	return nil
+}
+
+func (e ProviderMode) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// BreakerState is where an adapter's circuit breaker stands
+type BreakerState string
+
+const (
+	BreakerStateClosed   BreakerState = "CLOSED"
+	BreakerStateOpen     BreakerState = "OPEN"
+	BreakerStateHalfOpen BreakerState = "HALF_OPEN"
+)
+
+func (e BreakerState) IsValid() bool {
+	switch e {
+	case BreakerStateClosed, BreakerStateOpen, BreakerStateHalfOpen:
+		return true
+	}
+	return false
+}
+
+func (e BreakerState) String() string {
+	return string(e)
+}
+
+func (e *BreakerState) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = BreakerState(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid BreakerState", str)
+	}
+	return nil
+}
+
+func (e BreakerState) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// Leadership reports whether this replica runs the scheduled jobs. Since is
+// RFC3339 and nil until the first election attempt.
+type Leadership struct {
+	Backend  LeaderBackend `json:"backend"`
+	Identity string        `json:"identity"`
+	IsLeader bool          `json:"isLeader"`
+	Since    *string       `json:"since,omitempty"`
+}
+
+// LeaderBackend is what replicas compete for to run scheduled jobs
+type LeaderBackend string
+
+const (
+	LeaderBackendNone     LeaderBackend = "NONE"
+	LeaderBackendRedis    LeaderBackend = "REDIS"
+	LeaderBackendPostgres LeaderBackend = "POSTGRES"
+)
+
+func (e LeaderBackend) IsValid() bool {
+	switch e {
+	case LeaderBackendNone, LeaderBackendRedis, LeaderBackendPostgres:
+		return true
+	}
+	return false
+}
+
+func (e LeaderBackend) String() string {
+	return string(e)
+}
+
+func (e *LeaderBackend) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = LeaderBackend(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid LeaderBackend", str)
+	}
+	return nil
+}
+
+func (e LeaderBackend) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
+}
+
+// APIKey describes an issued key. The key itself is only returned once, in
+// CreatedAPIKey.
+type APIKey struct {
+	ID                 string        `json:"id"`
+	Name               string        `json:"name"`
+	Prefix             string        `json:"prefix"`
+	Scopes             []APIKeyScope `json:"scopes"`
+	RateLimitPerMinute int           `json:"rateLimitPerMinute"`
+	DailyQuota         int           `json:"dailyQuota"`
+	CreatedAt          string        `json:"createdAt"`
+	RevokedAt          *string       `json:"revokedAt,omitempty"`
+	ExpiresAt          *string       `json:"expiresAt,omitempty"`
+}
+
+type CreatedAPIKey struct {
+	Key    string  `json:"key"`
+	APIKey *APIKey `json:"apiKey"`
+}
+
+// CreateAPIKeyInput issues a key. Unset limits take the configured defaults;
+// 0 means unlimited.
+type CreateAPIKeyInput struct {
+	Name               string        `json:"name"`
+	Scopes             []APIKeyScope `json:"scopes"`
+	RateLimitPerMinute *int          `json:"rateLimitPerMinute,omitempty"`
+	DailyQuota         *int          `json:"dailyQuota,omitempty"`
+	ExpiresInDays      *int          `json:"expiresInDays,omitempty"`
+}
+
+type APIKeyScope string
+
+const (
+	APIKeyScopeRead  APIKeyScope = "READ"
+	APIKeyScopeAdmin APIKeyScope = "ADMIN"
+)
+
+func (e APIKeyScope) IsValid() bool {
+	switch e {
+	case APIKeyScopeRead, APIKeyScopeAdmin:
+		return true
+	}
+	return false
+}
+
+func (e APIKeyScope) String() string {
+	return string(e)
+}
+
+func (e *APIKeyScope) UnmarshalGQL(v interface{}) error {
+	str, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("enums must be strings")
+	}
+
+	*e = APIKeyScope(str)
+	if !e.IsValid() {
+		return fmt.Errorf("%s is not a valid ApiKeyScope", str)
+	}
+	return nil
+}
+
+func (e APIKeyScope) MarshalGQL(w io.Writer) {
+	fmt.Fprint(w, strconv.Quote(e.String()))
 }
@@ -7,6 +7,8 @@ package model
 // This file is intentionally minimal to avoid duplicate type
 // definitions when generating code.
 
-// Query is kept as a stub so gqlgen can compile its
+// Query and Mutation are kept as stubs so gqlgen can compile its
 // generated resolver signatures.
 type Query struct{}
+
+type Mutation struct{}
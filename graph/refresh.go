@@ -0,0 +1,24 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// RefreshPrices fetches prices for each zipcode exactly as an eggPrices query
+// would, recording them in the history store
+func (r *Resolver) RefreshPrices(ctx context.Context, zipcodes []string) error {
+	q := &queryResolver{r}
+
+	var errs []error
+	for _, zipcode := range zipcodes {
+		if _, err := q.EggPrices(ctx, zipcode, nil); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", zipcode, err))
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
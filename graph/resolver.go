@@ -1,8 +1,17 @@
 package graph
 
 import (
+	"log/slog"
+	"time"
+
 	"github.com/jkzilla/egg-price-compare/api"
-	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/geo"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/leader"
+	"github.com/jkzilla/egg-price-compare/tracked"
 )
 
 // This file will not be regenerated automatically.
@@ -10,15 +19,67 @@ import (
 // It serves as dependency injection for your app, add any dependencies you require here.
 
 type Resolver struct {
-	walmartAPI   *api.WalmartAPI
-	walgreensAPI *api.WalgreensAPI
-	priceHistory []model.PriceHistoryEntry
+	walmartAPI   api.Retailer
+	walgreensAPI api.Retailer
+	history      history.Store
+	providers    []*api.StatusRetailer
+	keys         auth.Store
+	tracked      tracked.Store
+	authConfig   config.Auth
+	historyCfg   config.History
+	priceMaxAge  time.Duration
+	elector      *leader.Elector
+	zipcodeDB    *geo.ZipcodeDB
 }
 
-func NewResolver() *Resolver {
-	return &Resolver{
-		walmartAPI:   api.NewWalmartAPI(),
-		walgreensAPI: api.NewWalgreensAPI(),
-		priceHistory: []model.PriceHistoryEntry{},
+// NewResolver builds the resolver and its retailer adapters from cfg. Prices
+// served are recorded in store, which the caller can share with other
+// consumers such as the price exporter. API keys are issued into keys, and
+// admins manage the tracked zipcodes in zipcodes.
+// Retailer prices are cached through prices for cfg.Cache.PriceTTL. elector
+// is the scheduled jobs' election, or nil where no jobs run. It fails when
+// the zipcode data or mock scenarios configured can't be loaded.
+func NewResolver(cfg *config.Config, store history.Store, keys auth.Store, zipcodes tracked.Store, prices *cache.Group, elector *leader.Elector) (*Resolver, error) {
+	zipcodeDB, err := geo.Open(cfg.Geo.ZipcodeDataFile)
+	if err != nil {
+		return nil, err
+	}
+	mock, err := api.NewMockProvider(cfg.Mock)
+	if err != nil {
+		return nil, err
+	}
+
+	// CHAOS_CONFIG injects upstream faults for staging and tests
+	chaos := api.NewChaos(cfg.Chaos)
+	if chaos != nil {
+		slog.Warn("fault injection is on", "file", cfg.Chaos.File)
 	}
+
+	client := api.NewHTTPClient(cfg.Cassette, chaos, cfg.Secrets()...)
+	walmart := api.NewWalmartAPI(cfg.Walmart, cfg.ThirdParty, client, chaos, mock, zipcodeDB)
+	walgreens := api.NewWalgreensAPI(cfg.Walgreens, cfg.ThirdParty, client, chaos, mock)
+	walmartStatus := api.NewStatusRetailer("walmart", chaos.WrapRetailer("walmart", walmart), walmart, cfg.Breaker)
+	walgreensStatus := api.NewStatusRetailer("walgreens", chaos.WrapRetailer("walgreens", walgreens), walgreens, cfg.Breaker)
+	// The cache sits outside the status tracker, so provider status only
+	// reflects real upstream calls
+	ttl := cfg.Cache.PriceTTL
+
+	return &Resolver{
+		walmartAPI:   api.TraceRetailer("walmart", api.CacheRetailer("walmart", walmartStatus, walmart, prices, ttl)),
+		walgreensAPI: api.TraceRetailer("walgreens", api.CacheRetailer("walgreens", walgreensStatus, walgreens, prices, ttl)),
+		history:      store,
+		providers:    []*api.StatusRetailer{walmartStatus, walgreensStatus},
+		keys:         keys,
+		tracked:      zipcodes,
+		authConfig:   cfg.Auth,
+		historyCfg:   cfg.History,
+		priceMaxAge:  cfg.Prices.CacheMaxAge,
+		elector:      elector,
+		zipcodeDB:    zipcodeDB,
+	}, nil
+}
+
+// Providers returns the retailer adapters' status trackers, in schema order
+func (r *Resolver) Providers() []*api.StatusRetailer {
+	return r.providers
 }
@@ -7,24 +7,116 @@ package graph
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"math"
+	"slices"
 	"time"
 
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
 	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/history"
 )
 
+// CreateAPIKey is the resolver for the createApiKey field.
+func (r *mutationResolver) CreateAPIKey(ctx context.Context, input model.CreateAPIKeyInput) (*model.CreatedAPIKey, error) {
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	key, plaintext, err := r.newAPIKey(input)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.keys.Create(ctx, key); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "api key created", "key_id", key.ID, "name", key.Name, "by", auth.FromContext(ctx).ID)
+	return &model.CreatedAPIKey{Key: plaintext, APIKey: apiKeyModel(key)}, nil
+}
+
+// RevokeAPIKey is the resolver for the revokeApiKey field.
+func (r *mutationResolver) RevokeAPIKey(ctx context.Context, id string) (*model.APIKey, error) {
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	key, err := r.keys.Revoke(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "api key revoked", "key_id", key.ID, "by", auth.FromContext(ctx).ID)
+	return apiKeyModel(key), nil
+}
+
+// TrackZipcode is the resolver for the trackZipcode field.
+func (r *mutationResolver) TrackZipcode(ctx context.Context, zipcode string) ([]string, error) {
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+	if err := r.validateZipcode(zipcode); err != nil {
+		return nil, err
+	}
+
+	if err := r.tracked.Add(ctx, zipcode); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "zipcode tracked", "zipcode", zipcode, "by", auth.FromContext(ctx).ID)
+	return r.tracked.List(ctx)
+}
+
+// UntrackZipcode is the resolver for the untrackZipcode field.
+func (r *mutationResolver) UntrackZipcode(ctx context.Context, zipcode string) ([]string, error) {
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	if err := r.tracked.Remove(ctx, zipcode); err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "zipcode untracked", "zipcode", zipcode, "by", auth.FromContext(ctx).ID)
+	return r.tracked.List(ctx)
+}
+
 // EggPrices is the resolver for the eggPrices field.
-func (r *queryResolver) EggPrices(ctx context.Context, zipcode string) (*model.EggPriceComparison, error) {
-	walmartPrice, err := r.Resolver.walmartAPI.GetEggPrice(zipcode)
+func (r *queryResolver) EggPrices(ctx context.Context, zipcode string, radiusMiles *float64) (*model.EggPriceComparison, error) {
+	if err := r.requireRead(ctx); err != nil {
+		return nil, err
+	}
+	if err := r.validateZipcode(zipcode); err != nil {
+		return nil, err
+	}
+
+	radius := api.DefaultRadiusMiles
+	if radiusMiles != nil {
+		radius = *radiusMiles
+	}
+	if !(radius > 0) {
+		return nil, badInput("radiusMiles must be greater than 0")
+	}
+
+	walmartStores, err := r.Resolver.walmartAPI.GetStorePrices(ctx, zipcode, radius)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Walmart price: %w", err)
 	}
 
-	walgreensPrice, err := r.Resolver.walgreensAPI.GetEggPrice(zipcode)
+	walgreensStores, err := r.Resolver.walgreensAPI.GetStorePrices(ctx, zipcode, radius)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get Walgreens price: %w", err)
 	}
 
+	// Store lists are ranked by price, so the first entry is the best store.
+	// The adapters fall back to the zip-level price, so an empty list means a
+	// decorator or mock dropped it.
+	if len(walmartStores) == 0 {
+		return nil, fmt.Errorf("failed to get Walmart price: no stores returned: %w", api.ErrNotFound)
+	}
+	if len(walgreensStores) == 0 {
+		return nil, fmt.Errorf("failed to get Walgreens price: no stores returned: %w", api.ErrNotFound)
+	}
+	walmartPrice := walmartStores[0]
+	walgreensPrice := walgreensStores[0]
+
 	cheapest := "Walmart"
 	if walgreensPrice.FinalPrice < walmartPrice.FinalPrice {
 		cheapest = "Walgreens"
@@ -32,21 +124,44 @@ func (r *queryResolver) EggPrices(ctx context.Context, zipcode string) (*model.E
 
 	priceDiff := math.Abs(walmartPrice.FinalPrice - walgreensPrice.FinalPrice)
 
+	// History records the older of the two fetch times
+	fetchedAt := walmartPrice.FetchedAt
+	if walgreensPrice.FetchedAt < fetchedAt {
+		fetchedAt = walgreensPrice.FetchedAt
+	}
+	comparedAt, err := time.Parse(time.RFC3339, fetchedAt)
+	if err != nil {
+		comparedAt = time.Now()
+	}
+
 	// Store in history
-	historyEntry := model.PriceHistoryEntry{
-		Date:           time.Now().Format("2006-01-02"),
-		WalmartPrice:   walmartPrice.FinalPrice,
-		WalgreensPrice: walgreensPrice.FinalPrice,
+	comparison := history.Comparison{
+		Zipcode:         zipcode,
+		WalmartPrice:    walmartPrice.FinalPrice,
+		WalgreensPrice:  walgreensPrice.FinalPrice,
+		WalmartSource:   walmartPrice.Source,
+		WalgreensSource: walgreensPrice.Source,
+		IsMock:          walmartPrice.IsMock || walgreensPrice.IsMock,
+		FetchedAt:       comparedAt,
 	}
 
-	// Add to history if it's a new day
-	if len(r.Resolver.priceHistory) == 0 || r.Resolver.priceHistory[len(r.Resolver.priceHistory)-1].Date != historyEntry.Date {
-		r.Resolver.priceHistory = append(r.Resolver.priceHistory, historyEntry)
+	// Add the comparison to the series and keep every store's latest price.
+	// The prices are served even if history can't be written.
+	if err := r.Resolver.history.RecordComparison(ctx, comparison); err != nil {
+		slog.WarnContext(ctx, "history: comparison not recorded", "zipcode", zipcode, "error", err)
+	}
+	if err := r.Resolver.history.RecordPrices(ctx, slices.Concat(walmartStores, walgreensStores)); err != nil {
+		slog.WarnContext(ctx, "history: prices not recorded", "zipcode", zipcode, "error", err)
 	}
+	cacheFreshPrices(ctx, r.Resolver.priceMaxAge, walmartStores, walgreensStores)
 
 	return &model.EggPriceComparison{
-		Walmart:         walmartPrice,
-		Walgreens:       walgreensPrice,
+		Walmart:   walmartPrice,
+		Walgreens: walgreensPrice,
+		Stores: []*model.RetailerStores{
+			{Retailer: "Walmart", Stores: walmartStores},
+			{Retailer: "Walgreens", Stores: walgreensStores},
+		},
 		Cheapest:        cheapest,
 		PriceDifference: priceDiff,
 		LastUpdated:     time.Now().Format(time.RFC3339),
@@ -54,49 +169,130 @@ func (r *queryResolver) EggPrices(ctx context.Context, zipcode string) (*model.E
 }
 
 // PriceHistory is the resolver for the priceHistory field.
-func (r *queryResolver) PriceHistory(ctx context.Context, days *int) ([]*model.PriceHistoryEntry, error) {
+func (r *queryResolver) PriceHistory(ctx context.Context, days *int, zipcode *string, resolution *model.HistoryResolution) ([]*model.PriceHistoryEntry, error) {
+	if err := r.requireRead(ctx); err != nil {
+		return nil, err
+	}
 	numDays := 7
 	if days != nil {
 		numDays = *days
 	}
+	var zip string
+	if zipcode != nil {
+		if err := r.validateZipcode(*zipcode); err != nil {
+			return nil, err
+		}
+		zip = *zipcode
+	}
 
-	// Return last N days
-	start := len(r.Resolver.priceHistory) - numDays
-	if start < 0 {
-		start = 0
+	res := model.HistoryResolutionDaily
+	if resolution != nil {
+		res = *resolution
+	}
+	if maxSpan := history.MaxSpan(res, r.Resolver.historyCfg); maxSpan > 0 && time.Duration(numDays)*24*time.Hour > maxSpan {
+		return nil, &api.InputError{
+			Field:  "days",
+			Reason: fmt.Sprintf("%s history covers at most %d days", res, int(maxSpan/(24*time.Hour))),
+		}
 	}
 
-	history := make([]*model.PriceHistoryEntry, 0)
-	for i := start; i < len(r.Resolver.priceHistory); i++ {
-		entry := r.Resolver.priceHistory[i]
-		history = append(history, &entry)
+	// History changes at most once per refresh of a price
+	cacheFor(ctx, r.Resolver.priceMaxAge)
+	if numDays < 1 {
+		return []*model.PriceHistoryEntry{}, nil
 	}
 
-	return history, nil
+	// The last N days are today and the N-1 UTC days before it
+	since := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1-numDays)
+	return r.Resolver.history.Series(ctx, zip, since, res)
 }
 
-// Query returns QueryResolver implementation.
-func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+// Location is the resolver for the location field.
+func (r *queryResolver) Location(ctx context.Context, zipcode string) (*model.Location, error) {
+	if err := r.requireRead(ctx); err != nil {
+		return nil, err
+	}
+	z, err := r.lookupZipcode(zipcode)
+	if err != nil {
+		return nil, err
+	}
 
-type queryResolver struct{ *Resolver }
+	// The closest match is the zipcode itself, so ask for one extra
+	nearby := make([]string, 0, nearbyZipcodeCount)
+	for _, n := range r.zipcodeDB.Nearest(z.Latitude, z.Longitude, nearbyZipcodeCount+1) {
+		if n.Zipcode != z.Zipcode {
+			nearby = append(nearby, n.Zipcode)
+		}
+	}
+	if len(nearby) > nearbyZipcodeCount {
+		nearby = nearby[:nearbyZipcodeCount]
+	}
 
-// !!! WARNING !!!
-// The code below was going to be deleted when updating resolvers. It has been copied here so you have
-// one last chance to move it out of harms way if you want. There are two reasons this happens:
-//  - When renaming or deleting a resolver the old code will be put in here. You can safely delete
-//    it when you're done.
-//  - You have helper methods in this file. Move them out to keep these resolver files clean.
-/*
-	type Resolver struct {
-	walmartAPI   *api.WalmartAPI
-	walgreensAPI *api.WalgreensAPI
-	priceHistory []model.PriceHistoryEntry
+	cacheFor(ctx, locationMaxAge)
+	return &model.Location{
+		Zipcode:        z.Zipcode,
+		City:           z.City,
+		State:          z.State,
+		County:         z.County,
+		Latitude:       z.Latitude,
+		Longitude:      z.Longitude,
+		Timezone:       z.Timezone,
+		NearbyZipcodes: nearby,
+	}, nil
 }
-func NewResolver() *Resolver {
-	return &Resolver{
-		walmartAPI:   api.NewWalmartAPI(),
-		walgreensAPI: api.NewWalgreensAPI(),
-		priceHistory: []model.PriceHistoryEntry{},
+
+// ProviderStatus is the resolver for the providerStatus field.
+func (r *queryResolver) ProviderStatus(ctx context.Context) ([]*model.ProviderStatus, error) {
+	noStore(ctx)
+	statuses := make([]*model.ProviderStatus, 0, len(r.Resolver.providers))
+	for _, p := range r.Resolver.providers {
+		statuses = append(statuses, providerStatus(p.Status()))
+	}
+	return statuses, nil
+}
+
+// Leadership is the resolver for the leadership field.
+func (r *queryResolver) Leadership(ctx context.Context) (*model.Leadership, error) {
+	noStore(ctx)
+	if r.Resolver.elector == nil {
+		return nil, nil
 	}
+	return leadership(r.Resolver.elector.Status()), nil
 }
-*/
+
+// APIKeys is the resolver for the apiKeys field.
+func (r *queryResolver) APIKeys(ctx context.Context) ([]*model.APIKey, error) {
+	noStore(ctx)
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	keys, err := r.Resolver.keys.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	models := make([]*model.APIKey, 0, len(keys))
+	for _, k := range keys {
+		models = append(models, apiKeyModel(k))
+	}
+	return models, nil
+}
+
+// TrackedZipcodes is the resolver for the trackedZipcodes field.
+func (r *queryResolver) TrackedZipcodes(ctx context.Context) ([]string, error) {
+	noStore(ctx)
+	if err := auth.RequireScope(ctx, auth.ScopeAdmin); err != nil {
+		return nil, err
+	}
+
+	return r.Resolver.tracked.List(ctx)
+}
+
+// Mutation returns MutationResolver implementation.
+func (r *Resolver) Mutation() MutationResolver { return &mutationResolver{r} }
+
+// Query returns QueryResolver implementation.
+func (r *Resolver) Query() QueryResolver { return &queryResolver{r} }
+
+type mutationResolver struct{ *Resolver }
+type queryResolver struct{ *Resolver }
@@ -0,0 +1,58 @@
+package graph
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/leader"
+)
+
+// providerStatus converts an adapter status for the providerStatus query.
+// Errors are reported by code and client message only, as in ErrorPresenter.
+func providerStatus(s api.ProviderStatus) *model.ProviderStatus {
+	status := &model.ProviderStatus{
+		Provider:     s.Provider,
+		Mode:         model.ProviderModeLive,
+		Configured:   s.ConfigError == nil,
+		LastSuccess:  formatTime(s.LastSuccess),
+		LastErrorAt:  formatTime(s.LastFailure),
+		BreakerState: model.BreakerState(s.Breaker),
+	}
+	if s.Mock {
+		status.Mode = model.ProviderModeMock
+	}
+	if s.ConfigError != nil {
+		message := s.ConfigError.Error()
+		status.ConfigError = &message
+	}
+	if s.LastError != nil {
+		code, message := errorCode(s.LastError)
+		status.LastErrorCode = &code
+		status.LastError = &message
+	}
+	return status
+}
+
+// leadership converts this replica's view of the scheduler election
+func leadership(s leader.Status) *model.Leadership {
+	backend := model.LeaderBackend(strings.ToUpper(s.Backend))
+	if !backend.IsValid() {
+		backend = model.LeaderBackendNone
+	}
+	return &model.Leadership{
+		Backend:  backend,
+		Identity: s.Identity,
+		IsLeader: s.Leader,
+		Since:    formatTime(s.Since),
+	}
+}
+
+func formatTime(t time.Time) *string {
+	if t.IsZero() {
+		return nil
+	}
+	formatted := t.UTC().Format(time.RFC3339)
+	return &formatted
+}
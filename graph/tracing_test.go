@@ -0,0 +1,92 @@
+package graph
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/jkzilla/egg-price-compare/tracing"
+	"github.com/jkzilla/egg-price-compare/tracing/tracingtest"
+	"github.com/jkzilla/egg-price-compare/tracked"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Walmart calls are replayed from a cassette so they make real HTTP
+// requests; Walgreens has no credentials and serves mock prices
+func TestSpansNest(t *testing.T) {
+	spans := tracingtest.InMemory()
+	cfg := config.Default()
+	cfg.Cassette.Path = "../api/testdata/cassettes/walmart-affiliates.yaml"
+	cfg.Walmart.APIKey.Set("replay-key")
+
+	prices := cache.NewGroup("prices", cache.NewMemoryCache(), nil, cfg.Cache.LockTimeout)
+	resolver, err := NewResolver(cfg, history.NewMemoryStore(cfg.History), auth.NewMemoryStore(), tracked.NewMemoryStore(), prices, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewHandler(resolver, cfg, persisted.NewMemoryStore(10))
+	srv.Use(tracing.Extension{})
+
+	body := `{"query":"query Compare { eggPrices(zipcode: \"94102\") { cheapest } }"}`
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || strings.Contains(rec.Body.String(), `"errors"`) {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+
+	recorded := spans.GetSpans()
+	operation := findSpan(t, recorded, "graphql.query Compare")
+	retailer := findSpan(t, recorded, "walmart.GetStorePrices")
+	request := findSpan(t, recorded, "HTTP GET")
+	for _, s := range recorded {
+		if s.SpanContext.TraceID() != operation.SpanContext.TraceID() {
+			t.Errorf("span %q is in another trace", s.Name)
+		}
+	}
+	if !descends(recorded, retailer, operation) {
+		t.Error("the retailer span doesn't descend from the operation span")
+	}
+	if !descends(recorded, request, retailer) {
+		t.Error("the HTTP span doesn't descend from the retailer span")
+	}
+}
+
+func findSpan(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	var names []string
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+		names = append(names, s.Name)
+	}
+	t.Fatalf("no %q span in %q", name, names)
+	return tracetest.SpanStub{}
+}
+
+// descends reports whether span has ancestor among its parents in spans
+func descends(spans tracetest.SpanStubs, span, ancestor tracetest.SpanStub) bool {
+	byID := map[string]tracetest.SpanStub{}
+	for _, s := range spans {
+		byID[s.SpanContext.SpanID().String()] = s
+	}
+	for parent := span.Parent; parent.IsValid(); {
+		if parent.SpanID() == ancestor.SpanContext.SpanID() {
+			return true
+		}
+		next, ok := byID[parent.SpanID().String()]
+		if !ok {
+			return false
+		}
+		parent = next.Parent
+	}
+	return false
+}
@@ -0,0 +1,39 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/tracked"
+)
+
+func TestTrackZipcode(t *testing.T) {
+	r := &Resolver{tracked: tracked.NewMemoryStore(), zipcodeDB: embeddedZipcodes(t)}
+	m, q := &mutationResolver{r}, &queryResolver{r}
+	admin := auth.WithKey(context.Background(), &auth.Key{ID: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	reader := auth.WithKey(context.Background(), &auth.Key{ID: "reader", Scopes: []auth.Scope{auth.ScopeRead}})
+
+	if _, err := m.TrackZipcode(reader, "94102"); !errors.Is(err, auth.ErrForbidden) {
+		t.Errorf("TrackZipcode with a READ key = %v, want ErrForbidden", err)
+	}
+	if _, err := m.TrackZipcode(admin, "9410"); !errors.Is(err, api.ErrInvalidZipcode) {
+		t.Errorf("TrackZipcode(9410) = %v, want ErrInvalidZipcode", err)
+	}
+
+	m.TrackZipcode(admin, "94102")
+	zipcodes, err := m.TrackZipcode(admin, "10001")
+	if err != nil || !reflect.DeepEqual(zipcodes, []string{"10001", "94102"}) {
+		t.Fatalf("TrackZipcode = %v, %v", zipcodes, err)
+	}
+	zipcodes, err = m.UntrackZipcode(admin, "94102")
+	if err != nil || !reflect.DeepEqual(zipcodes, []string{"10001"}) {
+		t.Fatalf("UntrackZipcode = %v, %v", zipcodes, err)
+	}
+	if zipcodes, err := q.TrackedZipcodes(admin); err != nil || !reflect.DeepEqual(zipcodes, []string{"10001"}) {
+		t.Errorf("TrackedZipcodes = %v, %v", zipcodes, err)
+	}
+}
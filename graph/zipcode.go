@@ -0,0 +1,32 @@
+package graph
+
+import (
+	"fmt"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/geo"
+)
+
+// nearbyZipcodeCount is how many neighbouring zipcodes the location query returns
+const nearbyZipcodeCount = 5
+
+// validateZipcode rejects zipcodes that are malformed or missing from the
+// geodatabase with an INVALID_ZIPCODE error, before they are priced,
+// recorded in history or tracked
+func (r *Resolver) validateZipcode(zipcode string) error {
+	_, err := r.lookupZipcode(zipcode)
+	return err
+}
+
+// lookupZipcode validates a zipcode and returns its geodatabase entry
+func (r *Resolver) lookupZipcode(zipcode string) (*geo.Zipcode, error) {
+	if !geo.ValidFormat(zipcode) {
+		return nil, &api.InvalidZipcodeError{Zipcode: zipcode, Reason: fmt.Sprintf("%q is not a 5-digit US zipcode", zipcode)}
+	}
+
+	z, ok := r.zipcodeDB.Lookup(zipcode)
+	if !ok {
+		return nil, &api.InvalidZipcodeError{Zipcode: zipcode, Reason: fmt.Sprintf("%s is not a known US zipcode", zipcode)}
+	}
+	return z, nil
+}
@@ -0,0 +1,80 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/geo"
+	"github.com/jkzilla/egg-price-compare/tracked"
+)
+
+// embeddedZipcodes returns the geodatabase bundled with the binary
+func embeddedZipcodes(t *testing.T) *geo.ZipcodeDB {
+	t.Helper()
+	db, err := geo.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestValidateZipcode(t *testing.T) {
+	r := &Resolver{zipcodeDB: embeddedZipcodes(t)}
+	for _, zipcode := range []string{"94102", "02108", "10001"} {
+		if err := r.validateZipcode(zipcode); err != nil {
+			t.Errorf("validateZipcode(%q) = %v", zipcode, err)
+		}
+	}
+	// 00000 and 99999 are well-formed but aren't zipcodes
+	for _, zipcode := range []string{"", "9410", "941022", "94I02", " 94102", "00000", "99999"} {
+		if err := r.validateZipcode(zipcode); !errors.Is(err, api.ErrInvalidZipcode) {
+			t.Errorf("validateZipcode(%q) = %v, want ErrInvalidZipcode", zipcode, err)
+		}
+	}
+}
+
+// Unknown zipcodes are refused before they are priced, read from history or
+// tracked
+func TestUnknownZipcodeRejected(t *testing.T) {
+	r := &Resolver{tracked: tracked.NewMemoryStore(), zipcodeDB: embeddedZipcodes(t)}
+	q, m := &queryResolver{r}, &mutationResolver{r}
+	admin := auth.WithKey(context.Background(), &auth.Key{ID: "admin", Scopes: []auth.Scope{auth.ScopeAdmin}})
+	zipcode := "99999"
+
+	calls := map[string]func() error{
+		"eggPrices": func() error {
+			_, err := q.EggPrices(admin, zipcode, nil)
+			return err
+		},
+		"priceHistory": func() error {
+			_, err := q.PriceHistory(admin, nil, &zipcode, nil)
+			return err
+		},
+		"trackZipcode": func() error {
+			_, err := m.TrackZipcode(admin, zipcode)
+			return err
+		},
+		"location": func() error {
+			_, err := q.Location(admin, zipcode)
+			return err
+		},
+	}
+	for field, call := range calls {
+		err := call()
+		if !errors.Is(err, api.ErrInvalidZipcode) {
+			t.Errorf("%s(%s) = %v, want ErrInvalidZipcode", field, zipcode, err)
+			continue
+		}
+		gqlErr := ErrorPresenter(context.Background(), err)
+		if gqlErr.Extensions["code"] != "INVALID_ZIPCODE" || gqlErr.Extensions["zipcode"] != zipcode {
+			t.Errorf("%s(%s) extensions = %v, want INVALID_ZIPCODE for %s", field, zipcode, gqlErr.Extensions, zipcode)
+		}
+	}
+
+	if list, _ := r.tracked.List(context.Background()); len(list) != 0 {
+		t.Errorf("tracked zipcodes = %v, want none", list)
+	}
+}
@@ -0,0 +1,81 @@
+// Package health serves liveness and readiness probes. Liveness only says the
+// process is serving HTTP; readiness runs dependency checks so a load balancer
+// or Kubernetes stops routing to an instance that can't answer queries.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds each readiness check so a hung dependency fails the
+// probe instead of stalling it
+const checkTimeout = 2 * time.Second
+
+// Check is one named readiness dependency. Check returns nil when healthy.
+type Check struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type checkResult struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type response struct {
+	Status string        `json:"status"`
+	Checks []checkResult `json:"checks,omitempty"`
+}
+
+// Liveness always answers 200 while the server is up
+func Liveness() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, response{Status: "ok"})
+	})
+}
+
+// Readiness runs every check concurrently and answers 200 if all pass,
+// 503 otherwise. The body lists each check's result.
+func Readiness(checks ...Check) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), checkTimeout)
+		defer cancel()
+
+		results := make([]checkResult, len(checks))
+		var wg sync.WaitGroup
+		for i, c := range checks {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i] = checkResult{Name: c.Name, Status: "ok"}
+				if err := c.Check(ctx); err != nil {
+					results[i].Status = "fail"
+					results[i].Error = err.Error()
+				}
+			}()
+		}
+		wg.Wait()
+
+		status, code := "ok", http.StatusOK
+		for _, result := range results {
+			if result.Status != "ok" {
+				status, code = "unavailable", http.StatusServiceUnavailable
+				slog.WarnContext(r.Context(), "readiness check failed", "check", result.Name, "error", result.Error)
+			}
+		}
+		writeJSON(w, code, response{Status: status, Checks: results})
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
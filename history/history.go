@@ -0,0 +1,216 @@
+// Package history keeps the prices the API has served: every comparison,
+// rolled up into hourly and daily series for the priceHistory query, and the
+// latest observation for every retailer store, which the price exporter
+// publishes without refetching.
+package history
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// Comparison is the best Walmart and Walgreens price served for a zipcode
+type Comparison struct {
+	Zipcode         string
+	WalmartPrice    float64
+	WalgreensPrice  float64
+	WalmartSource   string
+	WalgreensSource string
+	IsMock          bool
+	// FetchedAt is the older of the two prices' fetch times
+	FetchedAt time.Time
+}
+
+// Observation is the latest known price at one retailer store. StoreID is
+// empty for zip-level prices that aren't tied to a store.
+type Observation struct {
+	Retailer   string
+	Zipcode    string
+	StoreID    string
+	StoreName  string
+	FinalPrice float64
+	BasePrice  float64
+	InStock    bool
+	OfferCount int
+	Source     string
+	IsMock     bool
+	FetchedAt  time.Time
+}
+
+type comparisonKey struct {
+	zipcode   string
+	fetchedAt int64 // Unix nanoseconds
+}
+
+type observationKey struct {
+	retailer, zipcode, storeID string
+}
+
+// Store keeps served prices. Implementations are safe for concurrent use.
+type Store interface {
+	// RecordComparison adds a comparison to the raw series
+	RecordComparison(ctx context.Context, c Comparison) error
+	// RecordPrices records each price as its store's latest observation
+	RecordPrices(ctx context.Context, prices []*model.RetailerPrice) error
+	// Series returns the comparisons for zipcode from since until now at
+	// resolution, oldest first. When zipcode is empty it returns every
+	// zipcode's series, one after another, ordered by zipcode.
+	Series(ctx context.Context, zipcode string, since time.Time, resolution model.HistoryResolution) ([]*model.PriceHistoryEntry, error)
+	// Latest returns the latest observation for every store, ordered by
+	// retailer, zipcode and store
+	Latest(ctx context.Context) ([]Observation, error)
+	// Ping reports whether the store can be reached
+	Ping(ctx context.Context) error
+	// Flush writes out buffered observations before shutdown
+	Flush(ctx context.Context) error
+	// Compact rolls up comparisons and deletes history past its retention.
+	// It is run on one replica, so it must cover every replica's writes.
+	Compact(ctx context.Context) error
+}
+
+// MemoryStore keeps history in memory. It is lost on restart and each
+// replica has its own. Only raw comparisons are kept, for cfg.RawRetention,
+// and rolled up when read, so the series goes no further back than that.
+type MemoryStore struct {
+	cfg config.History
+
+	mu          sync.RWMutex
+	comparisons []Comparison // ordered by FetchedAt
+	recorded    map[comparisonKey]bool
+	latest      map[observationKey]Observation
+}
+
+func NewMemoryStore(cfg config.History) *MemoryStore {
+	return &MemoryStore{cfg: cfg, recorded: map[comparisonKey]bool{}, latest: map[observationKey]Observation{}}
+}
+
+// Ping always succeeds while ctx is live; memory can't be unreachable
+func (s *MemoryStore) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Flush has nothing to do: the memory store writes synchronously
+func (s *MemoryStore) Flush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// RecordComparison also drops expired comparisons. Compaction only runs on
+// the leader, so the other replicas' stores have to prune themselves.
+// A comparison served from cache repeats one already recorded, with the
+// same zipcode and FetchedAt, and is skipped.
+func (s *MemoryStore) RecordComparison(ctx context.Context, c Comparison) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := comparisonKey{c.Zipcode, c.FetchedAt.UnixNano()}
+	if s.recorded[key] || c.FetchedAt.Before(time.Now().Add(-s.cfg.RawRetention)) {
+		return nil
+	}
+	s.recorded[key] = true
+
+	// Cached prices can arrive out of order. Most comparisons are the
+	// newest and are appended without moving the others.
+	i := sort.Search(len(s.comparisons), func(i int) bool { return s.comparisons[i].FetchedAt.After(c.FetchedAt) })
+	s.comparisons = append(s.comparisons, Comparison{})
+	copy(s.comparisons[i+1:], s.comparisons[i:])
+	s.comparisons[i] = c
+	s.prune(time.Now())
+	return nil
+}
+
+func (s *MemoryStore) Compact(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	return ctx.Err()
+}
+
+// prune drops comparisons older than the raw retention. Callers hold s.mu.
+func (s *MemoryStore) prune(now time.Time) {
+	cutoff := now.Add(-s.cfg.RawRetention)
+	i := sort.Search(len(s.comparisons), func(i int) bool { return !s.comparisons[i].FetchedAt.Before(cutoff) })
+	if i > 0 {
+		for _, c := range s.comparisons[:i] {
+			delete(s.recorded, comparisonKey{c.Zipcode, c.FetchedAt.UnixNano()})
+		}
+		s.comparisons = append(s.comparisons[:0], s.comparisons[i:]...)
+	}
+}
+
+// RecordPrices keeps only each store's latest observation
+func (s *MemoryStore) RecordPrices(ctx context.Context, prices []*model.RetailerPrice) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range prices {
+		o := observationFromPrice(p)
+		s.latest[observationKey{o.Retailer, o.Zipcode, o.StoreID}] = o
+	}
+	return nil
+}
+
+func (s *MemoryStore) Series(ctx context.Context, zipcode string, since time.Time, resolution model.HistoryResolution) ([]*model.PriceHistoryEntry, error) {
+	since = periodStart(since, resolution)
+
+	s.mu.RLock()
+	start := sort.Search(len(s.comparisons), func(i int) bool { return !s.comparisons[i].FetchedAt.Before(since) })
+	var comparisons []Comparison
+	for _, c := range s.comparisons[start:] {
+		if zipcode == "" || c.Zipcode == zipcode {
+			comparisons = append(comparisons, c)
+		}
+	}
+	s.mu.RUnlock()
+
+	return rollUp(comparisons, resolution), nil
+}
+
+func (s *MemoryStore) Latest(ctx context.Context) ([]Observation, error) {
+	s.mu.RLock()
+	observations := make([]Observation, 0, len(s.latest))
+	for _, o := range s.latest {
+		observations = append(observations, o)
+	}
+	s.mu.RUnlock()
+
+	sort.Slice(observations, func(i, j int) bool {
+		a, b := observations[i], observations[j]
+		if a.Retailer != b.Retailer {
+			return a.Retailer < b.Retailer
+		}
+		if a.Zipcode != b.Zipcode {
+			return a.Zipcode < b.Zipcode
+		}
+		return a.StoreID < b.StoreID
+	})
+	return observations, nil
+}
+
+func observationFromPrice(p *model.RetailerPrice) Observation {
+	o := Observation{
+		Retailer:   p.Store,
+		Zipcode:    p.Zipcode,
+		FinalPrice: p.FinalPrice,
+		BasePrice:  p.BasePrice,
+		InStock:    p.InStock,
+		OfferCount: len(p.DigitalOffers),
+		Source:     p.Source,
+		IsMock:     p.IsMock,
+	}
+	if p.StoreID != nil {
+		o.StoreID = *p.StoreID
+	}
+	if p.Location != nil {
+		o.StoreName = p.Location.Name
+	}
+	if t, err := time.Parse(time.RFC3339, p.FetchedAt); err == nil {
+		o.FetchedAt = t
+	}
+	return o
+}
@@ -0,0 +1,77 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// A comparison served from cache is recorded once
+func TestMemoryStoreSkipsRepeats(t *testing.T) {
+	store := NewMemoryStore(config.Default().History)
+	ctx := context.Background()
+
+	c := Comparison{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: time.Now().Add(-time.Minute)}
+	for range 3 {
+		store.RecordComparison(ctx, c)
+	}
+	c.Zipcode = "10001"
+	store.RecordComparison(ctx, c)
+
+	if got := len(store.comparisons); got != 2 {
+		t.Errorf("%d comparisons kept, want one per zipcode", got)
+	}
+}
+
+// Without a zipcode every zipcode has its own series rather than one that
+// mixes their prices
+func TestMemoryStoreSeriesPerZipcode(t *testing.T) {
+	store := NewMemoryStore(config.Default().History)
+	ctx := context.Background()
+
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	for _, c := range []Comparison{
+		{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: hour.Add(5 * time.Minute)},
+		{Zipcode: "10001", WalmartPrice: 5.10, WalgreensPrice: 5.49, FetchedAt: hour.Add(10 * time.Minute)},
+		{Zipcode: "94102", WalmartPrice: 4.08, WalgreensPrice: 4.19, FetchedAt: hour.Add(15 * time.Minute)},
+	} {
+		store.RecordComparison(ctx, c)
+	}
+
+	entries, err := store.Series(ctx, "", hour, model.HistoryResolutionHourly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want an hour for each zipcode", len(entries))
+	}
+	first, second := entries[0], entries[1]
+	if first.Zipcode == nil || *first.Zipcode != "10001" || first.Samples != 1 {
+		t.Errorf("first entry = %v with %d samples, want 10001's hour of 1", first.Zipcode, first.Samples)
+	}
+	if second.Zipcode == nil || *second.Zipcode != "94102" || second.Samples != 2 {
+		t.Errorf("second entry = %v with %d samples, want 94102's hour of 2", second.Zipcode, second.Samples)
+	}
+	if second.Walmart.High != 4.08 || second.Walmart.Low != 3.88 {
+		t.Errorf("94102 Walmart high/low = %.2f/%.2f, want 4.08/3.88", second.Walmart.High, second.Walmart.Low)
+	}
+}
+
+func TestMaxSpan(t *testing.T) {
+	cfg := config.Default().History
+	cfg.RawRetention = 24 * time.Hour
+	cfg.HourlyRetention = 0
+
+	for resolution, want := range map[model.HistoryResolution]time.Duration{
+		model.HistoryResolutionRaw:    24 * time.Hour,
+		model.HistoryResolutionHourly: hourlyMaxSpan,
+		model.HistoryResolutionDaily:  0,
+	} {
+		if got := MaxSpan(resolution, cfg); got != want {
+			t.Errorf("MaxSpan(%s) = %s, want %s", resolution, got, want)
+		}
+	}
+}
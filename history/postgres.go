@@ -0,0 +1,279 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+// PostgresStore keeps history in Postgres, shared by every replica. Unlike
+// the memory store it keeps every store-level observation, not just each
+// store's latest, and rollups past the raw retention. The pool is shared;
+// close it separately.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+	cfg  config.History
+}
+
+func NewPostgresStore(pool *pgxpool.Pool, cfg config.History) *PostgresStore {
+	return &PostgresStore{pool: pool, cfg: cfg}
+}
+
+func (s *PostgresStore) Ping(ctx context.Context) error {
+	return s.pool.Ping(ctx)
+}
+
+// Flush has nothing to do: every write is committed before it returns
+func (s *PostgresStore) Flush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// RecordComparison skips a comparison served from cache, which repeats the
+// one recorded with the same zipcode and fetched_at
+func (s *PostgresStore) RecordComparison(ctx context.Context, c Comparison) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO comparisons
+		(zipcode, walmart_price, walgreens_price, walmart_source, walgreens_source, is_mock, fetched_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (zipcode, fetched_at) DO NOTHING`,
+		c.Zipcode, c.WalmartPrice, c.WalgreensPrice, c.WalmartSource, c.WalgreensSource, c.IsMock, c.FetchedAt)
+	if err != nil {
+		return fmt.Errorf("history: failed to record comparison: %w", err)
+	}
+	return nil
+}
+
+// RecordPrices appends every price to price_observations and moves each
+// store's latest price forward, in one transaction
+func (s *PostgresStore) RecordPrices(ctx context.Context, prices []*model.RetailerPrice) error {
+	if len(prices) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	for _, p := range prices {
+		o := observationFromPrice(p)
+		if o.FetchedAt.IsZero() {
+			o.FetchedAt = time.Now()
+		}
+		args := []any{o.Retailer, o.Zipcode, o.StoreID, o.StoreName, o.FinalPrice, o.BasePrice,
+			o.InStock, o.OfferCount, o.Source, o.IsMock, o.FetchedAt}
+		batch.Queue(`INSERT INTO price_observations
+			(retailer, zipcode, store_id, store_name, final_price, base_price, in_stock, offer_count, source, is_mock, fetched_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`, args...)
+		// A cached price replayed by another replica mustn't overwrite a
+		// newer one
+		batch.Queue(`INSERT INTO latest_prices
+			(retailer, zipcode, store_id, store_name, final_price, base_price, in_stock, offer_count, source, is_mock, fetched_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (retailer, zipcode, store_id) DO UPDATE SET
+				store_name = excluded.store_name, final_price = excluded.final_price,
+				base_price = excluded.base_price, in_stock = excluded.in_stock,
+				offer_count = excluded.offer_count, source = excluded.source,
+				is_mock = excluded.is_mock, fetched_at = excluded.fetched_at
+			WHERE excluded.fetched_at >= latest_prices.fetched_at`, args...)
+	}
+
+	err := pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return fmt.Errorf("history: failed to record prices: %w", err)
+	}
+	return nil
+}
+
+// Series reads periods that still have raw comparisons from those, so the
+// latest hour and day don't wait for compaction, and older periods from the
+// rollups
+func (s *PostgresStore) Series(ctx context.Context, zipcode string, since time.Time, resolution model.HistoryResolution) ([]*model.PriceHistoryEntry, error) {
+	now := time.Now()
+	since = periodStart(since, resolution)
+	if resolution == model.HistoryResolutionRaw {
+		comparisons, err := s.comparisons(ctx, zipcode, since)
+		if err != nil {
+			return nil, err
+		}
+		return rollUp(comparisons, resolution), nil
+	}
+
+	boundary := rawBoundary(now, s.cfg.RawRetention, resolution)
+	entries := []*model.PriceHistoryEntry{}
+	if since.Before(boundary) {
+		rows, err := s.pool.Query(ctx, `SELECT zipcode, period_start,
+				walmart_open, walmart_high, walmart_low, walmart_close,
+				walgreens_open, walgreens_high, walgreens_low, walgreens_close,
+				walmart_source, walgreens_source, is_mock, fetched_at, samples
+			FROM comparison_rollups
+			WHERE resolution = $1 AND ($2 = '' OR zipcode = $2) AND period_start >= $3 AND period_start < $4
+			ORDER BY zipcode, period_start`, string(resolution), zipcode, since, boundary)
+		if err != nil {
+			return nil, fmt.Errorf("history: failed to read rollups: %w", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var (
+				p   period
+				zip string
+			)
+			err := rows.Scan(&zip, &p.start,
+				&p.walmart.Open, &p.walmart.High, &p.walmart.Low, &p.walmart.Close,
+				&p.walgreens.Open, &p.walgreens.High, &p.walgreens.Low, &p.walgreens.Close,
+				&p.last.WalmartSource, &p.last.WalgreensSource, &p.isMock, &p.last.FetchedAt, &p.samples)
+			if err != nil {
+				return nil, fmt.Errorf("history: failed to read rollups: %w", err)
+			}
+			entries = append(entries, p.entry(zip, resolution))
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("history: failed to read rollups: %w", err)
+		}
+		rows.Close()
+	}
+
+	comparisons, err := s.comparisons(ctx, zipcode, maxTime(since, boundary))
+	if err != nil {
+		return nil, err
+	}
+	entries = append(entries, rollUp(comparisons, resolution)...)
+	sortSeries(entries)
+	return entries, nil
+}
+
+// Compact rolls up every period that still has all its raw comparisons,
+// then deletes comparisons, store prices and rollups past their retention,
+// in one transaction so nothing is deleted before it is rolled up
+func (s *PostgresStore) Compact(ctx context.Context) error {
+	now := time.Now()
+	hourBoundary := rawBoundary(now, s.cfg.RawRetention, model.HistoryResolutionHourly)
+	dayBoundary := rawBoundary(now, s.cfg.RawRetention, model.HistoryResolutionDaily)
+
+	comparisons, err := s.comparisons(ctx, "", hourBoundary)
+	if err != nil {
+		return err
+	}
+	byZipcode := map[string][]Comparison{}
+	for _, c := range comparisons {
+		byZipcode[c.Zipcode] = append(byZipcode[c.Zipcode], c)
+	}
+
+	batch := &pgx.Batch{}
+	for zipcode, zipComparisons := range byZipcode {
+		for _, resolution := range []model.HistoryResolution{model.HistoryResolutionHourly, model.HistoryResolutionDaily} {
+			for _, p := range periods(zipComparisons, resolution) {
+				if resolution == model.HistoryResolutionDaily && p.start.Before(dayBoundary) {
+					// Some of the day's comparisons have already expired
+					continue
+				}
+				batch.Queue(`INSERT INTO comparison_rollups (resolution, zipcode, period_start,
+						walmart_open, walmart_high, walmart_low, walmart_close,
+						walgreens_open, walgreens_high, walgreens_low, walgreens_close,
+						walmart_source, walgreens_source, is_mock, fetched_at, samples)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+					ON CONFLICT (resolution, zipcode, period_start) DO UPDATE SET
+						walmart_open = excluded.walmart_open, walmart_high = excluded.walmart_high,
+						walmart_low = excluded.walmart_low, walmart_close = excluded.walmart_close,
+						walgreens_open = excluded.walgreens_open, walgreens_high = excluded.walgreens_high,
+						walgreens_low = excluded.walgreens_low, walgreens_close = excluded.walgreens_close,
+						walmart_source = excluded.walmart_source, walgreens_source = excluded.walgreens_source,
+						is_mock = excluded.is_mock, fetched_at = excluded.fetched_at, samples = excluded.samples`,
+					string(resolution), zipcode, p.start,
+					p.walmart.Open, p.walmart.High, p.walmart.Low, p.walmart.Close,
+					p.walgreens.Open, p.walgreens.High, p.walgreens.Low, p.walgreens.Close,
+					p.last.WalmartSource, p.last.WalgreensSource, p.isMock, p.last.FetchedAt, p.samples)
+			}
+		}
+	}
+
+	rawCutoff := now.Add(-s.cfg.RawRetention)
+	batch.Queue(`DELETE FROM comparisons WHERE fetched_at < $1`, rawCutoff)
+	batch.Queue(`DELETE FROM price_observations WHERE fetched_at < $1`, rawCutoff)
+	for resolution, retention := range map[model.HistoryResolution]time.Duration{
+		model.HistoryResolutionHourly: s.cfg.HourlyRetention,
+		model.HistoryResolutionDaily:  s.cfg.DailyRetention,
+	} {
+		if retention > 0 {
+			batch.Queue(`DELETE FROM comparison_rollups WHERE resolution = $1 AND period_start < $2`,
+				string(resolution), now.Add(-retention))
+		}
+	}
+
+	err = pgx.BeginFunc(ctx, s.pool, func(tx pgx.Tx) error {
+		return tx.SendBatch(ctx, batch).Close()
+	})
+	if err != nil {
+		return fmt.Errorf("history: compaction failed: %w", err)
+	}
+	return nil
+}
+
+// comparisons returns the raw comparisons for zipcode, or every zipcode
+// when it is empty, fetched at or after since, oldest first
+func (s *PostgresStore) comparisons(ctx context.Context, zipcode string, since time.Time) ([]Comparison, error) {
+	rows, err := s.pool.Query(ctx, `SELECT zipcode, walmart_price, walgreens_price, walmart_source, walgreens_source, is_mock, fetched_at
+		FROM comparisons
+		WHERE fetched_at >= $1 AND ($2 = '' OR zipcode = $2)
+		ORDER BY fetched_at, id`, since, zipcode)
+	if err != nil {
+		return nil, fmt.Errorf("history: failed to read comparisons: %w", err)
+	}
+	defer rows.Close()
+
+	var comparisons []Comparison
+	for rows.Next() {
+		var c Comparison
+		err := rows.Scan(&c.Zipcode, &c.WalmartPrice, &c.WalgreensPrice, &c.WalmartSource, &c.WalgreensSource, &c.IsMock, &c.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("history: failed to read comparisons: %w", err)
+		}
+		comparisons = append(comparisons, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("history: failed to read comparisons: %w", err)
+	}
+	return comparisons, nil
+}
+
+// rawBoundary is the start of the oldest period at resolution whose raw
+// comparisons are all still kept
+func rawBoundary(now time.Time, rawRetention time.Duration, resolution model.HistoryResolution) time.Time {
+	return periodStart(now.Add(-rawRetention), resolution).Add(periodLength(resolution))
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func (s *PostgresStore) Latest(ctx context.Context) ([]Observation, error) {
+	rows, err := s.pool.Query(ctx, `SELECT retailer, zipcode, store_id, store_name, final_price, base_price,
+			in_stock, offer_count, source, is_mock, fetched_at
+		FROM latest_prices
+		ORDER BY retailer, zipcode, store_id`)
+	if err != nil {
+		return nil, fmt.Errorf("history: failed to read latest prices: %w", err)
+	}
+	defer rows.Close()
+
+	var observations []Observation
+	for rows.Next() {
+		var o Observation
+		err := rows.Scan(&o.Retailer, &o.Zipcode, &o.StoreID, &o.StoreName, &o.FinalPrice, &o.BasePrice,
+			&o.InStock, &o.OfferCount, &o.Source, &o.IsMock, &o.FetchedAt)
+		if err != nil {
+			return nil, fmt.Errorf("history: failed to read latest prices: %w", err)
+		}
+		observations = append(observations, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("history: failed to read latest prices: %w", err)
+	}
+	return observations, nil
+}
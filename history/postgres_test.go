@@ -0,0 +1,169 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+func TestPostgresSeries(t *testing.T) {
+	store := NewPostgresStore(dbtest.Open(t), config.Default().History)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i, c := range []Comparison{
+		{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: now.Add(-30 * time.Minute)},
+		{Zipcode: "10001", WalmartPrice: 5.10, WalgreensPrice: 5.49, FetchedAt: now.Add(-20 * time.Minute)},
+		// Cached prices can be recorded out of order
+		{Zipcode: "94102", WalmartPrice: 3.98, WalgreensPrice: 4.19, FetchedAt: now.Add(-40 * time.Minute)},
+	} {
+		c.WalmartSource, c.WalgreensSource = "walmart-affiliates", "walgreens"
+		if err := store.RecordComparison(ctx, c); err != nil {
+			t.Fatalf("comparison %d: %v", i, err)
+		}
+	}
+
+	entries, err := store.Series(ctx, "94102", now.Add(-time.Hour), model.HistoryResolutionRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want the 2 for 94102", len(entries))
+	}
+	if entries[0].WalmartPrice != 3.98 || entries[1].WalmartPrice != 3.88 {
+		t.Errorf("Walmart prices = %.2f, %.2f; want oldest first", entries[0].WalmartPrice, entries[1].WalmartPrice)
+	}
+
+	// Without a zipcode each zipcode has its own series
+	entries, err = store.Series(ctx, "", now.Add(-time.Hour), model.HistoryResolutionHourly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var zipcodes []string
+	for _, e := range entries {
+		if e.Zipcode == nil {
+			t.Fatal("entry without a zipcode")
+		}
+		zipcodes = append(zipcodes, *e.Zipcode)
+	}
+	if len(zipcodes) < 2 || zipcodes[0] != "10001" || zipcodes[len(zipcodes)-1] != "94102" {
+		t.Errorf("hourly series zipcodes = %v, want 10001's then 94102's", zipcodes)
+	}
+}
+
+// A comparison served from cache is recorded once
+func TestPostgresRecordComparisonSkipsRepeats(t *testing.T) {
+	pool := dbtest.Open(t)
+	store := NewPostgresStore(pool, config.Default().History)
+	ctx := context.Background()
+
+	c := Comparison{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: time.Now().Truncate(time.Microsecond)}
+	for range 3 {
+		if err := store.RecordComparison(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var count int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM comparisons").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d comparisons stored, want 1", count)
+	}
+}
+
+// Compaction rolls up what it can still see and only then deletes
+// comparisons past the raw retention
+func TestPostgresCompact(t *testing.T) {
+	cfg := config.Default().History
+	cfg.RawRetention = 24 * time.Hour
+	pool := dbtest.Open(t)
+	store := NewPostgresStore(pool, cfg)
+	ctx := context.Background()
+
+	hour := time.Now().UTC().Truncate(time.Hour).Add(-2 * time.Hour)
+	for _, c := range []Comparison{
+		{Zipcode: "94102", WalmartPrice: 3.88, WalgreensPrice: 4.29, FetchedAt: hour.Add(5 * time.Minute)},
+		{Zipcode: "94102", WalmartPrice: 4.08, WalgreensPrice: 4.19, FetchedAt: hour.Add(35 * time.Minute)},
+		{Zipcode: "94102", WalmartPrice: 2.99, WalgreensPrice: 3.99, FetchedAt: time.Now().Add(-30 * time.Hour)},
+	} {
+		if err := store.RecordComparison(ctx, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Compact(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var expired int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM comparisons WHERE fetched_at < $1", time.Now().Add(-cfg.RawRetention)).Scan(&expired); err != nil {
+		t.Fatal(err)
+	}
+	if expired != 0 {
+		t.Errorf("%d comparisons past the raw retention survived compaction", expired)
+	}
+
+	var open, high, low, close float64
+	var samples int
+	err := pool.QueryRow(ctx, `SELECT walmart_open, walmart_high, walmart_low, walmart_close, samples
+		FROM comparison_rollups WHERE resolution = 'HOURLY' AND zipcode = '94102' AND period_start = $1`, hour).
+		Scan(&open, &high, &low, &close, &samples)
+	if err != nil {
+		t.Fatalf("hourly rollup: %v", err)
+	}
+
+	var mixed int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM comparison_rollups WHERE zipcode = ''").Scan(&mixed); err != nil {
+		t.Fatal(err)
+	}
+	if mixed != 0 {
+		t.Errorf("compaction wrote %d rollups across every zipcode", mixed)
+	}
+	if open != 3.88 || high != 4.08 || low != 3.88 || close != 4.08 || samples != 2 {
+		t.Errorf("Walmart OHLC = %.2f/%.2f/%.2f/%.2f from %d samples, want 3.88/4.08/3.88/4.08 from 2",
+			open, high, low, close, samples)
+	}
+}
+
+// A cached price recorded late mustn't replace a newer latest price
+func TestPostgresLatest(t *testing.T) {
+	pool := dbtest.Open(t)
+	store := NewPostgresStore(pool, config.Default().History)
+	ctx := context.Background()
+
+	storeID := "2486"
+	price := func(final float64, fetchedAt time.Time) *model.RetailerPrice {
+		return &model.RetailerPrice{
+			Store: "Walmart", StoreID: &storeID, Zipcode: "94102", FinalPrice: final, BasePrice: 4.27,
+			InStock: true, Source: "walmart-affiliates", FetchedAt: fetchedAt.Format(time.RFC3339),
+		}
+	}
+	now := time.Now()
+	if err := store.RecordPrices(ctx, []*model.RetailerPrice{price(3.88, now)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RecordPrices(ctx, []*model.RetailerPrice{price(4.27, now.Add(-time.Hour))}); err != nil {
+		t.Fatal(err)
+	}
+
+	latest, err := store.Latest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(latest) != 1 || latest[0].FinalPrice != 3.88 || latest[0].StoreID != storeID {
+		t.Errorf("Latest = %+v, want the newer $3.88 at store 2486", latest)
+	}
+
+	var observations int
+	if err := pool.QueryRow(ctx, "SELECT count(*) FROM price_observations").Scan(&observations); err != nil {
+		t.Fatal(err)
+	}
+	if observations != 2 {
+		t.Errorf("%d observations stored, want both", observations)
+	}
+}
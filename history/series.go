@@ -0,0 +1,154 @@
+package history
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+)
+
+const (
+	// rawMaxSpan and hourlyMaxSpan are the longest spans served at raw and
+	// hourly resolution: a few hundred points per zipcode at the default
+	// refresh interval
+	rawMaxSpan    = 2 * 24 * time.Hour
+	hourlyMaxSpan = 31 * 24 * time.Hour
+)
+
+// MaxSpan is the longest span served at resolution, or 0 for no limit.
+// Raw and hourly series are capped to keep them to a few hundred points per
+// zipcode, and to what their retention keeps.
+func MaxSpan(resolution model.HistoryResolution, cfg config.History) time.Duration {
+	switch resolution {
+	case model.HistoryResolutionRaw:
+		return min(rawMaxSpan, cfg.RawRetention)
+	case model.HistoryResolutionHourly:
+		if cfg.HourlyRetention > 0 {
+			return min(hourlyMaxSpan, cfg.HourlyRetention)
+		}
+		return hourlyMaxSpan
+	default:
+		return 0
+	}
+}
+
+// periodLength is how much time one point of a series covers, or 0 for raw
+func periodLength(resolution model.HistoryResolution) time.Duration {
+	switch resolution {
+	case model.HistoryResolutionHourly:
+		return time.Hour
+	case model.HistoryResolutionDaily:
+		return 24 * time.Hour
+	default:
+		return 0
+	}
+}
+
+// periodStart returns the start of the UTC hour or day t falls in, or t
+// itself at raw resolution
+func periodStart(t time.Time, resolution model.HistoryResolution) time.Time {
+	if length := periodLength(resolution); length > 0 {
+		return t.UTC().Truncate(length)
+	}
+	return t.UTC()
+}
+
+// period summarises the comparisons in one hour or day, or a single one
+type period struct {
+	start     time.Time
+	walmart   model.PriceRange
+	walgreens model.PriceRange
+	last      Comparison // the close
+	isMock    bool
+	samples   int
+}
+
+// add folds in c, which must not be older than the comparisons already added
+func (p *period) add(c Comparison) {
+	if p.samples == 0 {
+		p.walmart = model.PriceRange{Open: c.WalmartPrice, High: c.WalmartPrice, Low: c.WalmartPrice}
+		p.walgreens = model.PriceRange{Open: c.WalgreensPrice, High: c.WalgreensPrice, Low: c.WalgreensPrice}
+	}
+	p.walmart.High = max(p.walmart.High, c.WalmartPrice)
+	p.walmart.Low = min(p.walmart.Low, c.WalmartPrice)
+	p.walmart.Close = c.WalmartPrice
+	p.walgreens.High = max(p.walgreens.High, c.WalgreensPrice)
+	p.walgreens.Low = min(p.walgreens.Low, c.WalgreensPrice)
+	p.walgreens.Close = c.WalgreensPrice
+	p.last = c
+	p.isMock = p.isMock || c.IsMock
+	p.samples++
+}
+
+// entry returns p as an entry of zipcode's series. Zipcode is nil for the
+// legacy daily points stored across every zipcode with an empty zipcode.
+func (p *period) entry(zipcode string, resolution model.HistoryResolution) *model.PriceHistoryEntry {
+	walmart, walgreens := p.walmart, p.walgreens
+	var zip *string
+	if zipcode != "" {
+		zip = &zipcode
+	}
+	return &model.PriceHistoryEntry{
+		Zipcode:         zip,
+		Date:            p.start.UTC().Format("2006-01-02"),
+		WalmartPrice:    walmart.Close,
+		WalgreensPrice:  walgreens.Close,
+		WalmartSource:   p.last.WalmartSource,
+		WalgreensSource: p.last.WalgreensSource,
+		FetchedAt:       p.last.FetchedAt.UTC().Format(time.RFC3339),
+		IsMock:          p.isMock,
+		Resolution:      resolution,
+		PeriodStart:     p.start.UTC().Format(time.RFC3339),
+		Walmart:         &walmart,
+		Walgreens:       &walgreens,
+		Samples:         p.samples,
+	}
+}
+
+// rollUp summarises comparisons, ordered by FetchedAt, into one series per
+// zipcode, ordered by zipcode, with one entry per period at resolution or
+// one per comparison at raw resolution
+func rollUp(comparisons []Comparison, resolution model.HistoryResolution) []*model.PriceHistoryEntry {
+	byZipcode := map[string][]Comparison{}
+	for _, c := range comparisons {
+		byZipcode[c.Zipcode] = append(byZipcode[c.Zipcode], c)
+	}
+	entries := []*model.PriceHistoryEntry{}
+	for _, zipcode := range slices.Sorted(maps.Keys(byZipcode)) {
+		for _, p := range periods(byZipcode[zipcode], resolution) {
+			entries = append(entries, p.entry(zipcode, resolution))
+		}
+	}
+	return entries
+}
+
+// sortSeries orders entries by zipcode, then period, with the legacy
+// all-zipcode series first. The sort is stable, so entries already in
+// period order stay that way.
+func sortSeries(entries []*model.PriceHistoryEntry) {
+	slices.SortStableFunc(entries, func(a, b *model.PriceHistoryEntry) int {
+		return cmp.Compare(seriesZipcode(a), seriesZipcode(b))
+	})
+}
+
+func seriesZipcode(e *model.PriceHistoryEntry) string {
+	if e.Zipcode == nil {
+		return ""
+	}
+	return *e.Zipcode
+}
+
+func periods(comparisons []Comparison, resolution model.HistoryResolution) []*period {
+	var periods []*period
+	for _, c := range comparisons {
+		start := periodStart(c.FetchedAt, resolution)
+		if len(periods) == 0 || resolution == model.HistoryResolutionRaw || !start.Equal(periods[len(periods)-1].start) {
+			periods = append(periods, &period{start: start})
+		}
+		periods[len(periods)-1].add(c)
+	}
+	return periods
+}
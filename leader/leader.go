@@ -0,0 +1,202 @@
+// Package leader elects one replica to run scheduled jobs, so background
+// refreshes call providers once rather than once per replica. Leadership is
+// a Redis lease or a Postgres advisory lock that the leader keeps renewing;
+// when it stops, another replica takes over.
+package leader
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// Lock is what replicas compete for
+type Lock interface {
+	// TryAcquire takes the lock, or renews it if this replica holds it, and
+	// reports whether this replica holds it now
+	TryAcquire(ctx context.Context) (bool, error)
+	// Release gives the lock up so another replica can take it at once
+	Release(ctx context.Context) error
+}
+
+// Status is this replica's view of the election
+type Status struct {
+	Backend  string // none, redis or postgres
+	Identity string
+	Leader   bool
+	// Since is when this replica last became leader or follower, zero until
+	// the first attempt
+	Since time.Time
+}
+
+// Elector campaigns for a Lock in the background
+type Elector struct {
+	backend  string
+	identity string
+	lock     Lock
+	interval time.Duration
+
+	mu     sync.RWMutex
+	leader bool
+	since  time.Time
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// Open returns an elector for the scheduled jobs using the backend cfg
+// names. rdb and pool are the shared Redis client and Postgres pool; only
+// the one the backend needs is used, and must be non-nil.
+func Open(cfg config.Leader, rdb *redis.Client, pool *pgxpool.Pool) (*Elector, error) {
+	identity := Identity()
+	backend := strings.ToLower(cfg.Backend)
+	switch backend {
+	case "none":
+		return NewElector(backend, identity, nil, cfg.LeaseTTL), nil
+	case "redis":
+		if rdb == nil {
+			return nil, fmt.Errorf("leader: the redis backend needs a redis client")
+		}
+		return NewElector(backend, identity, NewRedisLease(rdb, schedulerLock, identity, cfg.LeaseTTL), cfg.LeaseTTL), nil
+	case "postgres":
+		if pool == nil {
+			return nil, fmt.Errorf("leader: the postgres backend needs a postgres pool")
+		}
+		return NewElector(backend, identity, NewPostgresLock(pool, schedulerLock), cfg.LeaseTTL), nil
+	default:
+		return nil, fmt.Errorf("leader: unknown backend %q", cfg.Backend)
+	}
+}
+
+// schedulerLock names the lock the scheduled jobs' leader holds
+const schedulerLock = "scheduler"
+
+// NewElector campaigns for lock as identity, retrying and renewing three
+// times per leaseTTL so a leader renews well before its lease runs out. A
+// nil lock makes this replica leader unconditionally, for single-replica
+// deployments.
+func NewElector(backend, identity string, lock Lock, leaseTTL time.Duration) *Elector {
+	return &Elector{backend: backend, identity: identity, lock: lock, interval: leaseTTL / 3}
+}
+
+// Identity names this replica: its hostname, which is the pod name under
+// Kubernetes, plus a random suffix in case two processes share a host
+func Identity() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return host + "-" + hex.EncodeToString(suffix)
+}
+
+// Start makes a first attempt before returning, so jobs started right after
+// already know whether to run, then keeps campaigning until ctx is done or
+// Stop is called
+func (e *Elector) Start(ctx context.Context) {
+	if e.lock == nil {
+		e.mu.Lock()
+		e.leader, e.since = true, time.Now()
+		e.mu.Unlock()
+		return
+	}
+
+	ctx, e.cancel = context.WithCancel(ctx)
+	e.campaign(ctx)
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				e.campaign(ctx)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops campaigning and releases leadership so a follower can take
+// over without waiting for the lease to expire
+func (e *Elector) Stop(ctx context.Context) error {
+	if e.cancel == nil {
+		return nil
+	}
+	e.cancel()
+	e.wg.Wait()
+
+	e.mu.Lock()
+	wasLeader := e.leader
+	e.leader, e.since = false, time.Now()
+	e.mu.Unlock()
+	if !wasLeader {
+		return nil
+	}
+	slog.Info("releasing leadership", "backend", e.backend, "identity", e.identity)
+	return e.lock.Release(ctx)
+}
+
+// IsLeader reports whether this replica should run scheduled jobs
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+func (e *Elector) Status() Status {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return Status{Backend: e.backend, Identity: e.identity, Leader: e.leader, Since: e.since}
+}
+
+// campaign makes one attempt. On an error the replica steps down: another
+// may take the lock once this one's lease lapses, and two leaders would
+// repeat every job.
+func (e *Elector) campaign(ctx context.Context) {
+	// An attempt that outlasts the interval would let the lease lapse anyway
+	attemptCtx, cancel := context.WithTimeout(ctx, e.interval)
+	defer cancel()
+
+	leader, err := e.lock.TryAcquire(attemptCtx)
+	if err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+		slog.WarnContext(ctx, "leader election failed", "backend", e.backend, "error", err)
+		leader = false
+	}
+	e.set(leader)
+}
+
+func (e *Elector) set(leader bool) {
+	e.mu.Lock()
+	changed := e.since.IsZero() || e.leader != leader
+	if changed {
+		e.leader = leader
+		e.since = time.Now()
+	}
+	e.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if leader {
+		slog.Info("became leader", "backend", e.backend, "identity", e.identity)
+	} else {
+		slog.Info("following", "backend", e.backend, "identity", e.identity)
+	}
+}
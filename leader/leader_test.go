@@ -0,0 +1,35 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+)
+
+// A single replica needs no lock and always leads
+func TestOpenNone(t *testing.T) {
+	e, err := Open(config.Leader{Backend: "none", LeaseTTL: 15 * time.Second}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.IsLeader() {
+		t.Error("leader before Start")
+	}
+	e.Start(context.Background())
+	if s := e.Status(); !s.Leader || s.Backend != "none" || s.Since.IsZero() {
+		t.Errorf("status = %+v, want leader", s)
+	}
+	if err := e.Stop(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenNeedsClient(t *testing.T) {
+	for _, backend := range []string{"redis", "postgres", "etcd"} {
+		if _, err := Open(config.Leader{Backend: backend, LeaseTTL: 15 * time.Second}, nil, nil); err == nil {
+			t.Errorf("%s without a client: no error", backend)
+		}
+	}
+}
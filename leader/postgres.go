@@ -0,0 +1,83 @@
+package leader
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresLock is a Lock held as a session-level advisory lock. The lock
+// belongs to one pooled connection that is kept while leading; if the
+// connection or the leader dies, Postgres releases the lock itself.
+type PostgresLock struct {
+	pool *pgxpool.Pool
+	key  int64
+
+	mu   sync.Mutex
+	conn *pgxpool.Conn // held only while leading
+}
+
+// NewPostgresLock competes for the advisory lock derived from name. The
+// pool is shared; close it separately.
+func NewPostgresLock(pool *pgxpool.Pool, name string) *PostgresLock {
+	h := fnv.New64a()
+	h.Write([]byte("egg-price-compare:" + name))
+	return &PostgresLock{pool: pool, key: int64(h.Sum64())}
+}
+
+func (l *PostgresLock) TryAcquire(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn != nil {
+		// The lock lives as long as the session, so a live connection
+		// means it is still held
+		if _, err := l.conn.Exec(ctx, "SELECT 1"); err != nil {
+			l.dropConn(ctx)
+			return false, err
+		}
+		return true, nil
+	}
+
+	conn, err := l.pool.Acquire(ctx)
+	if err != nil {
+		return false, err
+	}
+	var acquired bool
+	if err := conn.QueryRow(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired); err != nil {
+		conn.Release()
+		return false, err
+	}
+	if !acquired {
+		conn.Release()
+		return false, nil
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *PostgresLock) Release(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.Exec(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	if err != nil {
+		l.dropConn(ctx)
+		return err
+	}
+	l.conn.Release()
+	l.conn = nil
+	return nil
+}
+
+// dropConn closes the lock's connection rather than returning it to the
+// pool, which ends the session and with it any lock it still holds
+func (l *PostgresLock) dropConn(ctx context.Context) {
+	l.conn.Hijack().Close(context.WithoutCancel(ctx))
+	l.conn = nil
+}
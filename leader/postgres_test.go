@@ -0,0 +1,75 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+)
+
+// Advisory locks are server-wide rather than per schema, so each test locks
+// a name of its own
+func TestPostgresLock(t *testing.T) {
+	pool := dbtest.Open(t)
+	ctx := context.Background()
+	name := Identity()
+	a, b := NewPostgresLock(pool, name), NewPostgresLock(pool, name)
+
+	if held, err := a.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("a acquiring a free lock = %t, %v", held, err)
+	}
+	if held, err := b.TryAcquire(ctx); err != nil || held {
+		t.Fatalf("b acquiring a's lock = %t, %v", held, err)
+	}
+	if held, err := a.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("a renewing = %t, %v", held, err)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := b.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("b acquiring the lock a released = %t, %v", held, err)
+	}
+
+	// Killing the session holding the lock, as a dropped connection or a
+	// dead leader does, frees it for the other replica
+	// The timeout makes pg_terminate_backend wait for the session to end
+	pid := b.conn.Conn().PgConn().PID()
+	if _, err := pool.Exec(ctx, "SELECT pg_terminate_backend($1, 5000)", int64(pid)); err != nil {
+		t.Fatal(err)
+	}
+	if held, err := b.TryAcquire(ctx); err == nil || held {
+		t.Fatalf("b renewing on a dead connection = %t, %v; want an error", held, err)
+	}
+	if b.conn != nil {
+		t.Error("b kept its dead connection")
+	}
+	if held, err := a.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("a acquiring after b's session ended = %t, %v", held, err)
+	}
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPostgresElectorFailover(t *testing.T) {
+	pool := dbtest.Open(t)
+	name := Identity()
+	const ttl = 300 * time.Millisecond
+	a := NewElector("postgres", "a", NewPostgresLock(pool, name), ttl)
+	b := NewElector("postgres", "b", NewPostgresLock(pool, name), ttl)
+
+	a.Start(context.Background())
+	b.Start(context.Background())
+	defer b.Stop(context.Background())
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("a leads: %t, b leads: %t; want a, which started first", a.IsLeader(), b.IsLeader())
+	}
+
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	waitLeader(t, b, true)
+}
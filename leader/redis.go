@@ -0,0 +1,56 @@
+package leader
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisLeasePrefix namespaces leases in a shared Redis
+const redisLeasePrefix = "leader:"
+
+// acquireScript renews the lease if this replica holds it, or takes it if
+// nobody does. Returns 1 if this replica holds it afterwards.
+var acquireScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	return 1
+end
+if redis.call("SET", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return 1
+end
+return 0`)
+
+// releaseScript deletes the lease only if this replica still holds it
+var releaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+// RedisLease is a Lock held as a Redis key that expires unless renewed
+type RedisLease struct {
+	client   *redis.Client
+	key      string
+	identity string
+	ttl      time.Duration
+}
+
+// NewRedisLease competes for the lease called name, holding it as identity
+// for ttl per renewal. The client is shared; close it separately.
+func NewRedisLease(client *redis.Client, name, identity string, ttl time.Duration) *RedisLease {
+	return &RedisLease{client: client, key: redisLeasePrefix + name, identity: identity, ttl: ttl}
+}
+
+func (l *RedisLease) TryAcquire(ctx context.Context) (bool, error) {
+	held, err := acquireScript.Run(ctx, l.client, []string{l.key}, l.identity, l.ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, err
+	}
+	return held == 1, nil
+}
+
+func (l *RedisLease) Release(ctx context.Context) error {
+	return releaseScript.Run(ctx, l.client, []string{l.key}, l.identity).Err()
+}
@@ -0,0 +1,149 @@
+package leader
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, mr
+}
+
+// waitLeader polls until e's leadership is want
+func waitLeader(t *testing.T, e *Elector, want bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for e.IsLeader() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("%s: IsLeader = %t, want %t", e.identity, !want, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRedisLease(t *testing.T) {
+	client, mr := newRedis(t)
+	ctx := context.Background()
+	a := NewRedisLease(client, "scheduler", "a", time.Minute)
+	b := NewRedisLease(client, "scheduler", "b", time.Minute)
+
+	if held, err := a.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("a acquiring a free lease = %t, %v", held, err)
+	}
+	if held, err := b.TryAcquire(ctx); err != nil || held {
+		t.Fatalf("b acquiring a's lease = %t, %v", held, err)
+	}
+
+	// Renewing restarts the TTL, so a leader that keeps renewing keeps it
+	mr.FastForward(40 * time.Second)
+	if held, err := a.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("a renewing = %t, %v", held, err)
+	}
+	if ttl := mr.TTL("leader:scheduler"); ttl != time.Minute {
+		t.Errorf("TTL after renewing = %s, want 1m", ttl)
+	}
+	mr.FastForward(40 * time.Second)
+	if held, _ := b.TryAcquire(ctx); held {
+		t.Fatal("b took a lease a renewed 40s ago")
+	}
+
+	// Once a stops renewing the lease expires and b takes it
+	mr.FastForward(time.Minute)
+	if held, err := b.TryAcquire(ctx); err != nil || !held {
+		t.Fatalf("b acquiring an expired lease = %t, %v", held, err)
+	}
+	if held, _ := a.TryAcquire(ctx); held {
+		t.Fatal("a renewed a lease b holds")
+	}
+
+	// A late release from the old leader leaves the new one's lease alone
+	if err := a.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mr.Get("leader:scheduler"); got != "b" {
+		t.Fatalf("lease held by %q after a released, want b", got)
+	}
+	if err := b.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if held, _ := a.TryAcquire(ctx); !held {
+		t.Error("a couldn't take the lease b released")
+	}
+}
+
+// Two replicas elect one leader. When it dies without releasing, the other
+// takes over once the lease expires; when it stops cleanly, at once.
+func TestRedisElectorFailover(t *testing.T) {
+	client, mr := newRedis(t)
+	const ttl = 300 * time.Millisecond
+	a := NewElector("redis", "a", NewRedisLease(client, schedulerLock, "a", ttl), ttl)
+	b := NewElector("redis", "b", NewRedisLease(client, schedulerLock, "b", ttl), ttl)
+
+	crash, cancel := context.WithCancel(context.Background())
+	a.Start(crash)
+	b.Start(context.Background())
+	defer b.Stop(context.Background())
+	if !a.IsLeader() || b.IsLeader() {
+		t.Fatalf("a leads: %t, b leads: %t; want a, which started first", a.IsLeader(), b.IsLeader())
+	}
+	if s := b.Status(); s.Backend != "redis" || s.Identity != "b" || s.Since.IsZero() {
+		t.Errorf("b's status = %+v", s)
+	}
+
+	// a stops renewing without releasing, as if its pod were killed. miniredis
+	// only expires keys when told to.
+	cancel()
+	a.wg.Wait()
+	time.Sleep(2 * ttl)
+	if b.IsLeader() {
+		t.Fatal("b took over before a's lease expired")
+	}
+	mr.FastForward(ttl)
+	waitLeader(t, b, true)
+
+	// The old leader's release on shutdown doesn't cut b's lease
+	if err := a.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := mr.Get("leader:" + schedulerLock); got != "b" {
+		t.Errorf("lease held by %q after a stopped, want b", got)
+	}
+
+	// b hands over on a clean stop, without waiting for the TTL
+	c := NewElector("redis", "c", NewRedisLease(client, schedulerLock, "c", ttl), ttl)
+	c.Start(context.Background())
+	defer c.Stop(context.Background())
+	if c.IsLeader() {
+		t.Fatal("c leads while b does")
+	}
+	if err := b.Stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if b.IsLeader() {
+		t.Error("b still leads after stopping")
+	}
+	waitLeader(t, c, true)
+}
+
+// On a Redis error the leader steps down rather than risk a second leader
+func TestRedisElectorStepsDownOnError(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	const ttl = 300 * time.Millisecond
+	e := NewElector("redis", "a", NewRedisLease(client, schedulerLock, "a", ttl), ttl)
+	e.Start(context.Background())
+	defer e.Stop(context.Background())
+	waitLeader(t, e, true)
+
+	mr.Close()
+	waitLeader(t, e, false)
+}
@@ -0,0 +1,140 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddlewareRequestID(t *testing.T) {
+	var seen string
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r.Context())
+	}))
+
+	tests := []struct {
+		name, inbound string
+		kept          bool
+	}{
+		{"valid", "req-7f3a_9.b", true},
+		{"missing", "", false},
+		{"header injection", "req-1\r\nX-Admin: true", false},
+		{"spaces", "req 1", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/graphql", nil)
+			if tt.inbound != "" {
+				req.Header[RequestIDHeader] = []string{tt.inbound}
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			echoed := rec.Header().Get(RequestIDHeader)
+			if echoed != seen {
+				t.Errorf("response ID %q, handler saw %q; want the same", echoed, seen)
+			}
+			if tt.kept && seen != tt.inbound {
+				t.Errorf("ID = %q, want the caller's %q", seen, tt.inbound)
+			}
+			if !tt.kept && (seen == tt.inbound || !validRequestID(seen) || len(seen) != 32) {
+				t.Errorf("ID = %q, want a generated one", seen)
+			}
+		})
+	}
+}
+
+func TestTransportForwardsRequestID(t *testing.T) {
+	var upstream string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r.Header.Get(RequestIDHeader)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+
+	req, _ := http.NewRequestWithContext(WithRequestID(context.Background(), "req-42"), "GET", srv.URL, nil)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if upstream != "req-42" {
+		t.Errorf("upstream got %q, want req-42", upstream)
+	}
+	if req.Header.Get(RequestIDHeader) != "" {
+		t.Error("Transport modified the caller's request")
+	}
+
+	// A provider's own ID header is left alone
+	req, _ = http.NewRequestWithContext(WithRequestID(context.Background(), "req-42"), "GET", srv.URL, nil)
+	req.Header.Set(RequestIDHeader, "caller-set")
+	resp, err = client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if upstream != "caller-set" {
+		t.Errorf("upstream got %q, want the ID already on the request", upstream)
+	}
+}
+
+func TestContextHandlerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", "info")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestID(context.Background(), "req-42")
+	logger.With("component", "test").InfoContext(ctx, "with request")
+	logger.Info("without request")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d log lines, want 2:\n%s", len(lines), buf.String())
+	}
+	var withID, withoutID map[string]any
+	json.Unmarshal([]byte(lines[0]), &withID)
+	json.Unmarshal([]byte(lines[1]), &withoutID)
+	if withID["request_id"] != "req-42" || withID["component"] != "test" {
+		t.Errorf("record = %v, want request_id req-42 alongside its attributes", withID)
+	}
+	if _, ok := withoutID["request_id"]; ok {
+		t.Errorf("record = %v, want no request_id outside a request", withoutID)
+	}
+}
+
+// The access log line carries the request ID it echoes
+func TestMiddlewareLogsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, _ := New(&buf, "json", "info")
+	defer slog.SetDefault(slog.Default())
+	slog.SetDefault(logger)
+
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	req.Header.Set(RequestIDHeader, "req-42")
+	Middleware(http.NotFoundHandler()).ServeHTTP(httptest.NewRecorder(), req)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+	if record["request_id"] != "req-42" || record["status"] != float64(http.StatusNotFound) {
+		t.Errorf("access log = %v, want request_id req-42 and status 404", record)
+	}
+}
+
+func TestNewRejectsBadSettings(t *testing.T) {
+	if _, err := New(&bytes.Buffer{}, "xml", "info"); err == nil {
+		t.Error("format xml accepted")
+	}
+	if _, err := New(&bytes.Buffer{}, "json", "loud"); err == nil {
+		t.Error("level loud accepted")
+	}
+}
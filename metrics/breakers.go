@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// breakerStates are the states eggprice_circuit_breaker_state reports
+var breakerStates = []string{"CLOSED", "OPEN", "HALF_OPEN"}
+
+var breakerStateDesc = prometheus.NewDesc(namespace+"_circuit_breaker_state",
+	"1 for the current state of each provider's circuit breaker, 0 for the others.",
+	[]string{"provider", "state"}, nil)
+
+// RegisterBreakers exports circuit breaker states on /metrics. states is
+// called at scrape time and returns each provider's current state.
+func RegisterBreakers(states func() map[string]string) {
+	prometheus.MustRegister(breakerCollector(states))
+}
+
+type breakerCollector func() map[string]string
+
+func (c breakerCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- breakerStateDesc
+}
+
+func (c breakerCollector) Collect(ch chan<- prometheus.Metric) {
+	for provider, current := range c() {
+		for _, state := range breakerStates {
+			value := 0.0
+			if state == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(breakerStateDesc, prometheus.GaugeValue, value, provider, state)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+// Package metrics exposes Prometheus metrics for GraphQL operations, upstream
+// provider calls and caches on /metrics.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "eggprice"
+
+var (
+	operationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_operations_total",
+		Help:      "GraphQL operations executed, by operation name, type and outcome.",
+	}, []string{"operation", "type", "status"})
+
+	operationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "graphql_operation_duration_seconds",
+		Help:      "GraphQL operation latency from parse to response.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "type"})
+
+	errorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "graphql_errors_total",
+		Help:      "GraphQL errors returned to clients, by extensions.code.",
+	}, []string{"code"})
+
+	upstreamDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Upstream provider request latency, by provider and HTTP status (\"error\" when no response).",
+		Buckets:   []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 15},
+	}, []string{"provider", "status"})
+
+	cacheRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_requests_total",
+		Help:      "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// Handler serves the default registry, including Go runtime and process metrics
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveCache records a cache lookup. The hit ratio is
+// sum(rate(eggprice_cache_requests_total{result="hit"}[5m])) / sum(rate(eggprice_cache_requests_total[5m])).
+func ObserveCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequestsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// Transport records the latency and status of every upstream request.
+// provider names the provider a request is for, e.g. from its host.
+func Transport(next http.RoundTripper, provider func(*http.Request) string) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+
+		status := "error"
+		if err == nil {
+			status = strconv.Itoa(resp.StatusCode)
+		}
+		upstreamDuration.WithLabelValues(provider(req), status).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// Extension is a gqlgen handler extension that counts and times operations
+type Extension struct {
+	operations map[string]bool
+}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+} = Extension{}
+
+// NewExtension labels operations named in operations by their name and every
+// other named operation as "other"
+func NewExtension(operations []string) Extension {
+	e := Extension{operations: map[string]bool{}}
+	for _, name := range operations {
+		e.operations[name] = true
+	}
+	return e
+}
+
+func (Extension) ExtensionName() string {
+	return "PrometheusMetrics"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+// operationLabel keeps the operation label to the configured names
+func (e Extension) operationLabel(name string) string {
+	switch {
+	case name == "":
+		return "anonymous"
+	case e.operations[name]:
+		return name
+	default:
+		return "other"
+	}
+}
+
+func (e Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	resp := next(ctx)
+	if !graphql.HasOperationContext(ctx) {
+		return resp
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	name, opType := oc.OperationName, "unknown"
+	if oc.Operation != nil {
+		name = oc.Operation.Name
+		opType = string(oc.Operation.Operation)
+	}
+	name = e.operationLabel(name)
+
+	status := "ok"
+	if resp != nil && len(resp.Errors) > 0 {
+		status = "error"
+		for _, err := range resp.Errors {
+			code, _ := err.Extensions["code"].(string)
+			if code == "" {
+				code = "UNKNOWN"
+			}
+			errorsTotal.WithLabelValues(code).Inc()
+		}
+	}
+
+	operationsTotal.WithLabelValues(name, opType, status).Inc()
+	operationDuration.WithLabelValues(name, opType).Observe(time.Since(oc.Stats.OperationStart).Seconds())
+	return resp
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestOperationLabel(t *testing.T) {
+	e := NewExtension([]string{"EggPrices"})
+	tests := map[string]string{
+		"EggPrices":     "EggPrices",
+		"":              "anonymous",
+		"eggprices":     "other",
+		"RandomName123": "other",
+	}
+	for name, want := range tests {
+		if got := e.operationLabel(name); got != want {
+			t.Errorf("operationLabel(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestBreakerCollector(t *testing.T) {
+	c := breakerCollector(func() map[string]string {
+		return map[string]string{"walmart": "OPEN"}
+	})
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(c)
+
+	want := `
+# HELP eggprice_circuit_breaker_state 1 for the current state of each provider's circuit breaker, 0 for the others.
+# TYPE eggprice_circuit_breaker_state gauge
+eggprice_circuit_breaker_state{provider="walmart",state="CLOSED"} 0
+eggprice_circuit_breaker_state{provider="walmart",state="HALF_OPEN"} 0
+eggprice_circuit_breaker_state{provider="walmart",state="OPEN"} 1
+`
+	if err := testutil.GatherAndCompare(registry, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
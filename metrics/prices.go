@@ -0,0 +1,92 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/tracked"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var priceLabels = []string{"retailer", "zipcode", "store", "store_name", "source"}
+
+var (
+	finalPriceDesc = prometheus.NewDesc(namespace+"_final_price_dollars",
+		"Latest price after promotions and digital offers.", priceLabels, nil)
+	basePriceDesc = prometheus.NewDesc(namespace+"_base_price_dollars",
+		"Latest regular shelf price.", priceLabels, nil)
+	inStockDesc = prometheus.NewDesc(namespace+"_in_stock",
+		"1 if eggs were in stock at the latest observation, 0 otherwise.", priceLabels, nil)
+	offerCountDesc = prometheus.NewDesc(namespace+"_digital_offers",
+		"Number of digital offers at the latest observation.", priceLabels, nil)
+	fetchedAtDesc = prometheus.NewDesc(namespace+"_price_fetched_timestamp_seconds",
+		"Unix time the latest price was fetched from its source.", priceLabels, nil)
+)
+
+// PricesHandler serves the latest observed egg prices as gauges. Values come
+// from store at scrape time; scraping never triggers a provider call.
+// Only zipcodes tracked in zipcodes are exported, so the series are bounded
+// by what admins track rather than by what clients query; when none are
+// tracked nothing is exported.
+func PricesHandler(store history.Store, zipcodes tracked.Store) http.Handler {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(&priceCollector{store: store, zipcodes: zipcodes})
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+type priceCollector struct {
+	store    history.Store
+	zipcodes tracked.Store
+}
+
+func (c *priceCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- finalPriceDesc
+	ch <- basePriceDesc
+	ch <- inStockDesc
+	ch <- offerCountDesc
+	ch <- fetchedAtDesc
+}
+
+func (c *priceCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	observations, err := c.store.Latest(ctx)
+	if err != nil {
+		// Fails the scrape rather than exporting no prices
+		ch <- prometheus.NewInvalidMetric(finalPriceDesc, err)
+		return
+	}
+	zipcodes, err := c.zipcodes.List(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(finalPriceDesc, err)
+		return
+	}
+	tracked := make(map[string]bool, len(zipcodes))
+	for _, z := range zipcodes {
+		tracked[z] = true
+	}
+
+	for _, o := range observations {
+		if !tracked[o.Zipcode] {
+			continue
+		}
+
+		labels := []string{strings.ToLower(o.Retailer), o.Zipcode, o.StoreID, o.StoreName, o.Source}
+		inStock := 0.0
+		if o.InStock {
+			inStock = 1
+		}
+
+		ch <- prometheus.MustNewConstMetric(finalPriceDesc, prometheus.GaugeValue, o.FinalPrice, labels...)
+		ch <- prometheus.MustNewConstMetric(basePriceDesc, prometheus.GaugeValue, o.BasePrice, labels...)
+		ch <- prometheus.MustNewConstMetric(inStockDesc, prometheus.GaugeValue, inStock, labels...)
+		ch <- prometheus.MustNewConstMetric(offerCountDesc, prometheus.GaugeValue, float64(o.OfferCount), labels...)
+		if !o.FetchedAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(fetchedAtDesc, prometheus.GaugeValue, float64(o.FetchedAt.Unix()), labels...)
+		}
+	}
+}
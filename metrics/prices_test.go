@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/graph/model"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/tracked"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPriceCollectorExportsTrackedZipcodes(t *testing.T) {
+	ctx := context.Background()
+	store := history.NewMemoryStore(config.Default().History)
+	storeID := "2486"
+	fetchedAt := time.Unix(1760000000, 0).UTC().Format(time.RFC3339)
+	store.RecordPrices(ctx, []*model.RetailerPrice{
+		{Store: "Walmart", StoreID: &storeID, Zipcode: "94102", FinalPrice: 3.88, BasePrice: 4.27, InStock: true,
+			Location: &model.StoreLocation{Name: "San Francisco Store"}, Source: "walmart-affiliates", FetchedAt: fetchedAt},
+		// Queried by a client but not tracked
+		{Store: "Walmart", Zipcode: "10001", FinalPrice: 4.48, BasePrice: 4.98, Source: "walmart-affiliates", FetchedAt: fetchedAt},
+	})
+	zipcodes := tracked.NewMemoryStore()
+	c := &priceCollector{store: store, zipcodes: zipcodes}
+
+	// Nothing is tracked, so nothing is exported
+	if n := testutil.CollectAndCount(c); n != 0 {
+		t.Errorf("collected %d metrics with no zipcodes tracked, want 0", n)
+	}
+
+	zipcodes.Add(ctx, "94102")
+	want := `
+# HELP eggprice_final_price_dollars Latest price after promotions and digital offers.
+# TYPE eggprice_final_price_dollars gauge
+eggprice_final_price_dollars{retailer="walmart",source="walmart-affiliates",store="2486",store_name="San Francisco Store",zipcode="94102"} 3.88
+# HELP eggprice_in_stock 1 if eggs were in stock at the latest observation, 0 otherwise.
+# TYPE eggprice_in_stock gauge
+eggprice_in_stock{retailer="walmart",source="walmart-affiliates",store="2486",store_name="San Francisco Store",zipcode="94102"} 1
+# HELP eggprice_price_fetched_timestamp_seconds Unix time the latest price was fetched from its source.
+# TYPE eggprice_price_fetched_timestamp_seconds gauge
+eggprice_price_fetched_timestamp_seconds{retailer="walmart",source="walmart-affiliates",store="2486",store_name="San Francisco Store",zipcode="94102"} 1.76e+09
+`
+	err := testutil.CollectAndCompare(c, strings.NewReader(want),
+		"eggprice_final_price_dollars", "eggprice_in_stock", "eggprice_price_fetched_timestamp_seconds")
+	if err != nil {
+		t.Error(err)
+	}
+	if n := testutil.CollectAndCount(c); n != 5 {
+		t.Errorf("collected %d metrics, want the 5 gauges for 94102's store only", n)
+	}
+}
@@ -6,6 +6,9 @@ echo "🔨 Building for Netlify..."
 # Install Go dependencies
 go mod download
 
+# Replace the checked-in metro subset of zipcodes with every USPS zipcode
+go generate ./geo
+
 # Build frontend (React + Vite) into public/
 echo "📦 Installing frontend dependencies..."
 cd frontend
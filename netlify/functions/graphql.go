@@ -2,20 +2,124 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
 
-	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/awslabs/aws-lambda-go-api-proxy/httpadapter"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/db"
 	"github.com/jkzilla/egg-price-compare/graph"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/logging"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/jkzilla/egg-price-compare/tracked"
+	"github.com/redis/go-redis/v9"
 )
 
 var graphqlHandler *httpadapter.HandlerAdapter
 
 func init() {
-	resolver := graph.NewResolver()
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
-	graphqlHandler = httpadapter.New(srv)
+	cfg, err := config.Load()
+	if err != nil {
+		// Fail closed: the defaults would drop the auth, CORS and
+		// environment settings the deployment asked for
+		slog.Error("invalid config, failing every request", "error", err)
+		graphqlHandler = httpadapter.New(http.HandlerFunc(misconfigured))
+		return
+	}
+	if err := logging.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		slog.Warn("invalid logging config, using defaults", "error", err)
+	}
+
+	// Function instances don't share memory, so Redis is what lets them
+	// share cached prices, rate-limit counters and persisted queries. If it
+	// can't be reached, each instance keeps its own rather than failing
+	// every request.
+	var rdb *redis.Client
+	if cfg.UsesRedis() {
+		if rdb, err = cache.OpenRedis(context.Background(), cfg.Redis); err != nil {
+			slog.Error("redis unavailable, keeping state in memory", "error", err)
+			cfg.Cache.Backend = "memory"
+			cfg.PersistedQueries = config.Default().PersistedQueries
+		}
+	}
+	queries, err := persisted.Open(cfg.PersistedQueries, rdb)
+	if err != nil {
+		slog.Error("persisted query store unavailable, keeping queries in memory", "error", err)
+		queries = persisted.NewMemoryStore(config.Default().PersistedQueries.CacheSize)
+	}
+	priceCache, locks, err := cache.Open(cfg.Cache, rdb)
+	if err != nil {
+		slog.Error("price cache unavailable, caching in memory", "error", err)
+		priceCache, locks = cache.NewMemoryCache(), nil
+	}
+	var limiter auth.Limiter = auth.NewMemoryLimiter()
+	if strings.EqualFold(cfg.Cache.Backend, "redis") {
+		limiter = auth.NewRedisLimiter(rdb)
+	}
+
+	// Without Postgres, keys are held per instance, so only ADMIN_TOKEN is
+	// reliably accepted
+	var prices history.Store = history.NewMemoryStore(cfg.History)
+	var keys auth.Store = auth.NewMemoryStore()
+	var zipcodes tracked.Store = tracked.NewMemoryStore()
+	if strings.EqualFold(cfg.Storage.Backend, "postgres") {
+		if pool, err := openStorage(cfg.Postgres); err != nil {
+			slog.Error("postgres unavailable, keeping history, keys and tracked zipcodes in memory", "error", err)
+		} else {
+			prices = history.NewPostgresStore(pool, cfg.History)
+			keys = auth.NewPostgresStore(pool)
+			zipcodes = tracked.NewPostgresStore(pool)
+		}
+	}
+	if err := tracked.Seed(context.Background(), zipcodes, cfg.Prices.TrackedZipcodes); err != nil {
+		slog.Error("failed to seed tracked zipcodes", "error", err)
+	}
+	authn := auth.NewAuthenticator(cfg.Auth, keys, limiter)
+
+	resolver, err := graph.NewResolver(cfg, prices, keys, zipcodes, cache.NewGroup("prices", priceCache, locks, cfg.Cache.LockTimeout), nil)
+	if err != nil {
+		slog.Error("failed to load zipcode data or mock scenarios, failing every request", "error", err)
+		graphqlHandler = httpadapter.New(http.HandlerFunc(misconfigured))
+		return
+	}
+	srv := graph.NewHandler(resolver, cfg, queries)
+	graphqlHandler = httpadapter.New(logging.Middleware(graph.CORS(cfg.Server.CORSAllowedOrigins, authn.Middleware(api.MockScenarioMiddleware(graph.CacheHeaders(srv))))))
+}
+
+// misconfigured answers in the GraphQL error shape; the config error itself
+// is only logged, since it may name settings or values
+func misconfigured(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusInternalServerError)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"errors": []map[string]interface{}{{
+			"message":    "The server is misconfigured",
+			"extensions": map[string]string{"code": "INTERNAL_SERVER_ERROR"},
+		}},
+	})
+}
+
+// openStorage connects to Postgres and brings its schema up to date. The
+// pool lives as long as the function instance.
+func openStorage(cfg config.Postgres) (*pgxpool.Pool, error) {
+	pool, err := db.Open(context.Background(), cfg)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Migrate(context.Background(), pool); err != nil {
+		pool.Close()
+		return nil, err
+	}
+	return pool, nil
 }
 
 func Handler(ctx context.Context, req events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
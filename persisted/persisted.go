@@ -0,0 +1,92 @@
+// Package persisted stores the query strings behind automatic persisted
+// query (APQ) hashes, so clients can send a short SHA-256 hash instead of
+// the full query and run it with a cacheable GET request.
+package persisted
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler/lru"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxQueryLength is the longest query that is stored. APQ lets any client
+// register a query, so this bounds how much one registration can store.
+const MaxQueryLength = 32 << 10
+
+// Store maps query hashes to queries. It satisfies graphql.Cache[string] so
+// it plugs into gqlgen's APQ extension, which verifies the hash before
+// calling Add. A backend error is logged and treated as a miss; the client
+// then resends the full query.
+type Store interface {
+	Get(ctx context.Context, hash string) (string, bool)
+	Add(ctx context.Context, hash, query string)
+	Close() error
+}
+
+// Open returns the store cfg names, counting its lookups in the
+// persisted_queries cache metrics. rdb is the shared Redis client, which is
+// only used, and must only be non-nil, for the redis store.
+func Open(cfg config.PersistedQueries, rdb *redis.Client) (Store, error) {
+	store, err := open(cfg, rdb)
+	if err != nil {
+		return nil, err
+	}
+	return observedStore{store}, nil
+}
+
+func open(cfg config.PersistedQueries, rdb *redis.Client) (Store, error) {
+	switch strings.ToLower(cfg.Store) {
+	case "memory":
+		return NewMemoryStore(cfg.CacheSize), nil
+	case "sqlite":
+		return NewSQLiteStore(cfg.SQLitePath)
+	case "redis":
+		if rdb == nil {
+			return nil, fmt.Errorf("persisted: the redis store needs a redis client")
+		}
+		return NewRedisStore(rdb, cfg.TTL), nil
+	default:
+		return nil, fmt.Errorf("persisted: unknown store %q", cfg.Store)
+	}
+}
+
+// observedStore records the hit or miss of every lookup
+type observedStore struct {
+	Store
+}
+
+func (s observedStore) Get(ctx context.Context, hash string) (string, bool) {
+	query, ok := s.Store.Get(ctx, hash)
+	metrics.ObserveCache("persisted_queries", ok)
+	return query, ok
+}
+
+// MemoryStore keeps the most recently used queries in process memory, so
+// each replica learns every query separately and forgets them on restart
+type MemoryStore struct {
+	queries *lru.LRU[string]
+}
+
+func NewMemoryStore(size int) *MemoryStore {
+	return &MemoryStore{queries: lru.New[string](size)}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, hash string) (string, bool) {
+	return s.queries.Get(ctx, hash)
+}
+
+func (s *MemoryStore) Add(ctx context.Context, hash, query string) {
+	if len(query) > MaxQueryLength {
+		return
+	}
+	s.queries.Add(ctx, hash, query)
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,148 @@
+package persisted
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newRedis(t *testing.T) (*redis.Client, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client, mr
+}
+
+// stores returns one of each backend, empty
+func stores(t *testing.T) map[string]Store {
+	t.Helper()
+	sqlite, err := NewSQLiteStore(filepath.Join(t.TempDir(), "queries.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlite.Close() })
+	client, _ := newRedis(t)
+	return map[string]Store{
+		"memory": NewMemoryStore(10),
+		"sqlite": sqlite,
+		"redis":  NewRedisStore(client, time.Hour),
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	const hash, query = "c0ffee", `{ location(zipcode: "94102") { city } }`
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			if got, ok := store.Get(ctx, hash); ok {
+				t.Fatalf("Get before Add = %q, want a miss", got)
+			}
+			store.Add(ctx, hash, query)
+			if got, ok := store.Get(ctx, hash); !ok || got != query {
+				t.Errorf("Get = %q, %t; want %q", got, ok, query)
+			}
+			if got, ok := store.Get(ctx, "other"); ok {
+				t.Errorf("Get of another hash = %q, want a miss", got)
+			}
+		})
+	}
+}
+
+// APQ lets any client register a query, so oversized ones aren't stored
+func TestStoreRejectsLongQueries(t *testing.T) {
+	ctx := context.Background()
+	long := "{ " + strings.Repeat("location(zipcode: \"94102\") { city } ", MaxQueryLength/30) + "}"
+	for name, store := range stores(t) {
+		t.Run(name, func(t *testing.T) {
+			store.Add(ctx, "long", long)
+			if _, ok := store.Get(ctx, "long"); ok {
+				t.Errorf("a %d byte query was stored, over the %d byte limit", len(long), MaxQueryLength)
+			}
+			store.Add(ctx, "limit", long[:MaxQueryLength])
+			if _, ok := store.Get(ctx, "limit"); !ok {
+				t.Errorf("a query of exactly %d bytes was not stored", MaxQueryLength)
+			}
+		})
+	}
+}
+
+// A SQLite store keeps its queries across a restart
+func TestSQLiteStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "queries.db")
+	store, err := NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Add(ctx, "c0ffee", "{ trackedZipcodes }")
+	// A second Add of the same hash is ignored rather than failing
+	store.Add(ctx, "c0ffee", "{ trackedZipcodes }")
+	store.Close()
+
+	store, err = NewSQLiteStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if got, ok := store.Get(ctx, "c0ffee"); !ok || got != "{ trackedZipcodes }" {
+		t.Errorf("Get after reopening = %q, %t", got, ok)
+	}
+}
+
+// Each lookup restarts the TTL, so a query in use never expires
+func TestRedisStoreRefreshesTTL(t *testing.T) {
+	client, mr := newRedis(t)
+	store := NewRedisStore(client, time.Hour)
+	ctx := context.Background()
+	store.Add(ctx, "used", "{ trackedZipcodes }")
+	store.Add(ctx, "unused", "{ trackedZipcodes }")
+	if ttl := mr.TTL(redisKeyPrefix + "used"); ttl != time.Hour {
+		t.Fatalf("TTL after Add = %s, want 1h", ttl)
+	}
+
+	for i := 0; i < 3; i++ {
+		mr.FastForward(40 * time.Minute)
+		if _, ok := store.Get(ctx, "used"); !ok {
+			t.Fatalf("lookup %d missed, want the TTL restarted by the last one", i+1)
+		}
+		if ttl := mr.TTL(redisKeyPrefix + "used"); ttl != time.Hour {
+			t.Errorf("TTL after lookup %d = %s, want 1h", i+1, ttl)
+		}
+	}
+	if _, ok := store.Get(ctx, "unused"); ok {
+		t.Error("a query nobody ran outlived its TTL")
+	}
+}
+
+// With no TTL queries are kept until Redis evicts them
+func TestRedisStoreWithoutTTL(t *testing.T) {
+	client, mr := newRedis(t)
+	store := NewRedisStore(client, 0)
+	ctx := context.Background()
+	store.Add(ctx, "c0ffee", "{ trackedZipcodes }")
+	if _, ok := store.Get(ctx, "c0ffee"); !ok || mr.TTL(redisKeyPrefix+"c0ffee") != 0 {
+		t.Errorf("got %t with TTL %s, want a hit and no TTL", ok, mr.TTL(redisKeyPrefix+"c0ffee"))
+	}
+}
+
+// A Redis outage is a miss, so clients resend the full query
+func TestRedisStoreDown(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr(), MaxRetries: -1})
+	t.Cleanup(func() { client.Close() })
+	store := NewRedisStore(client, time.Hour)
+	ctx := context.Background()
+	store.Add(ctx, "c0ffee", "{ trackedZipcodes }")
+	mr.Close()
+
+	store.Add(ctx, "other", "{ trackedZipcodes }")
+	if _, ok := store.Get(ctx, "c0ffee"); ok {
+		t.Error("Get with Redis down = hit, want a miss")
+	}
+}
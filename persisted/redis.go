@@ -0,0 +1,57 @@
+package persisted
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces persisted queries in a Redis shared with other
+// components
+const redisKeyPrefix = "apq:"
+
+// RedisStore keeps queries in Redis, shared by every replica. Each lookup
+// restarts a query's TTL, so only queries nobody runs expire.
+type RedisStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+// NewRedisStore stores queries in client. The client is shared and is not
+// closed by Close.
+func NewRedisStore(client *redis.Client, ttl time.Duration) *RedisStore {
+	return &RedisStore{client: client, ttl: ttl}
+}
+
+func (s *RedisStore) Get(ctx context.Context, hash string) (string, bool) {
+	var query string
+	var err error
+	if s.ttl > 0 {
+		query, err = s.client.GetEx(ctx, redisKeyPrefix+hash, s.ttl).Result()
+	} else {
+		query, err = s.client.Get(ctx, redisKeyPrefix+hash).Result()
+	}
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.WarnContext(ctx, "persisted: redis lookup failed", "hash", hash, "error", err)
+		}
+		return "", false
+	}
+	return query, true
+}
+
+func (s *RedisStore) Add(ctx context.Context, hash, query string) {
+	if len(query) > MaxQueryLength {
+		return
+	}
+	if err := s.client.Set(ctx, redisKeyPrefix+hash, query, s.ttl).Err(); err != nil {
+		slog.WarnContext(ctx, "persisted: redis store failed", "hash", hash, "error", err)
+	}
+}
+
+func (s *RedisStore) Close() error {
+	return nil
+}
@@ -0,0 +1,65 @@
+package persisted
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	// Registers the sqlite3 driver; it needs cgo
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// SQLiteStore keeps queries in a SQLite file, so they survive restarts of a
+// single instance. Replicas only share it if they share the file.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore opens or creates the database at path
+func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("persisted: failed to open %s: %w", path, err)
+	}
+	_, err = db.Exec(`CREATE TABLE IF NOT EXISTS persisted_queries (
+		hash       TEXT PRIMARY KEY,
+		query      TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL
+	)`)
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("persisted: failed to create table in %s: %w", path, err)
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, hash string) (string, bool) {
+	var query string
+	err := s.db.QueryRowContext(ctx, `SELECT query FROM persisted_queries WHERE hash = ?`, hash).Scan(&query)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			slog.WarnContext(ctx, "persisted: sqlite lookup failed", "hash", hash, "error", err)
+		}
+		return "", false
+	}
+	return query, true
+}
+
+func (s *SQLiteStore) Add(ctx context.Context, hash, query string) {
+	if len(query) > MaxQueryLength {
+		return
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO persisted_queries (hash, query, created_at) VALUES (?, ?, ?)`,
+		hash, query, time.Now().UTC())
+	if err != nil {
+		slog.WarnContext(ctx, "persisted: sqlite insert failed", "hash", hash, "error", err)
+	}
+}
+
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
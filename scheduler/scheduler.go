@@ -0,0 +1,99 @@
+// Package scheduler runs background jobs, such as refreshing prices for
+// tracked zipcodes, on fixed intervals.
+package scheduler
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job is a named task run every Interval
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+// Leader reports whether this replica is the one that should run jobs
+type Leader interface {
+	IsLeader() bool
+}
+
+// Scheduler runs jobs until stopped. Each job runs once at start, then on
+// its interval; a run that overlaps the next tick delays it rather than
+// running concurrently. Ticks on a replica that isn't the leader are
+// skipped, so after a failover the new leader picks the jobs up on their
+// next tick.
+type Scheduler struct {
+	jobs   []Job
+	leader Leader
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// New returns a scheduler that runs jobs only while leader says so. A nil
+// leader runs them on every replica.
+func New(leader Leader) *Scheduler {
+	return &Scheduler{leader: leader}
+}
+
+// Add registers a job. Jobs added after Start are not run.
+func (s *Scheduler) Add(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every job in its own goroutine until ctx is done or Stop is called
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, s.cancel = context.WithCancel(ctx)
+	for _, job := range s.jobs {
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.loop(ctx, job)
+		}()
+	}
+}
+
+// Stop cancels running jobs and waits for them to return
+func (s *Scheduler) Stop() {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(ctx, job)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job Job) {
+	if s.leader != nil && !s.leader.IsLeader() {
+		slog.DebugContext(ctx, "scheduled job skipped, not the leader", "job", job.Name)
+		return
+	}
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		slog.WarnContext(ctx, "scheduled job failed", "job", job.Name, "error", err, "duration_ms", time.Since(start).Milliseconds())
+		return
+	}
+	slog.DebugContext(ctx, "scheduled job finished", "job", job.Name, "duration_ms", time.Since(start).Milliseconds())
+}
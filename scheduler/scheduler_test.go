@@ -0,0 +1,105 @@
+package scheduler
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeLeader's leadership is set by the test
+type fakeLeader struct {
+	leader atomic.Bool
+}
+
+func (l *fakeLeader) IsLeader() bool {
+	return l.leader.Load()
+}
+
+// counting returns a job that counts its runs
+func counting(runs *atomic.Int32) Job {
+	return Job{Name: "refresh", Interval: 10 * time.Millisecond, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}}
+}
+
+// waitRuns polls until runs passes n
+func waitRuns(t *testing.T, runs *atomic.Int32, n int32) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runs.Load() <= n {
+		if time.Now().After(deadline) {
+			t.Fatalf("runs = %d, want more than %d", runs.Load(), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// Of two replicas only the leader runs jobs, and after a failover the new
+// leader picks them up on their next tick
+func TestJobsRunOnlyOnLeader(t *testing.T) {
+	var leaderA, leaderB fakeLeader
+	var runsA, runsB atomic.Int32
+	a, b := New(&leaderA), New(&leaderB)
+	a.Add(counting(&runsA))
+	b.Add(counting(&runsB))
+
+	leaderA.leader.Store(true)
+	a.Start(context.Background())
+	b.Start(context.Background())
+	defer a.Stop()
+	defer b.Stop()
+
+	waitRuns(t, &runsA, 3)
+	if n := runsB.Load(); n != 0 {
+		t.Fatalf("the follower ran %d times", n)
+	}
+
+	leaderA.leader.Store(false)
+	leaderB.leader.Store(true)
+	waitRuns(t, &runsB, 0)
+	// A run that had already passed the leader check may still finish
+	time.Sleep(20 * time.Millisecond)
+	stopped := runsA.Load()
+	waitRuns(t, &runsB, 3)
+	if n := runsA.Load(); n != stopped {
+		t.Errorf("the old leader ran %d more times after stepping down", n-stopped)
+	}
+}
+
+// Without a leader every replica runs the jobs, starting right away
+func TestJobsRunWithoutLeader(t *testing.T) {
+	var runs atomic.Int32
+	s := New(nil)
+	s.Add(Job{Name: "refresh", Interval: time.Hour, Run: func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	s.Start(context.Background())
+	waitRuns(t, &runs, 0)
+	s.Stop()
+	if n := runs.Load(); n != 1 {
+		t.Errorf("runs = %d, want 1 before the first tick", n)
+	}
+}
+
+// Stop cancels the context a running job sees and waits for it to return
+func TestStopCancelsJobs(t *testing.T) {
+	started, returned := make(chan struct{}), make(chan struct{})
+	s := New(nil)
+	s.Add(Job{Name: "slow", Interval: time.Hour, Run: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		close(returned)
+		return ctx.Err()
+	}})
+	s.Start(context.Background())
+	<-started
+	s.Stop()
+	select {
+	case <-returned:
+	default:
+		t.Error("Stop returned before the job did")
+	}
+}
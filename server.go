@@ -1,40 +1,235 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/99designs/gqlgen/graphql/handler"
 	"github.com/99designs/gqlgen/graphql/playground"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jkzilla/egg-price-compare/api"
+	"github.com/jkzilla/egg-price-compare/auth"
+	"github.com/jkzilla/egg-price-compare/cache"
+	"github.com/jkzilla/egg-price-compare/config"
+	"github.com/jkzilla/egg-price-compare/db"
 	"github.com/jkzilla/egg-price-compare/graph"
-	"github.com/rs/cors"
+	"github.com/jkzilla/egg-price-compare/health"
+	"github.com/jkzilla/egg-price-compare/history"
+	"github.com/jkzilla/egg-price-compare/leader"
+	"github.com/jkzilla/egg-price-compare/logging"
+	"github.com/jkzilla/egg-price-compare/metrics"
+	"github.com/jkzilla/egg-price-compare/persisted"
+	"github.com/jkzilla/egg-price-compare/scheduler"
+	"github.com/jkzilla/egg-price-compare/tracing"
+	"github.com/jkzilla/egg-price-compare/tracked"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 )
 
-const defaultPort = "8080"
-
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = defaultPort
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("invalid config", "error", err)
+		os.Exit(1)
+	}
+	if err := logging.Setup(cfg.Log.Format, cfg.Log.Level); err != nil {
+		slog.Error("invalid logging config", "error", err)
+		os.Exit(1)
+	}
+	if err := run(cfg); err != nil {
+		slog.Error("server stopped", "error", err)
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("invalid tracing config: %w", err)
+	}
+
+	// Swap rotated credentials from mounted secret files into the adapters
+	secretWatcher, err := cfg.WatchSecrets()
+	if err != nil {
+		return err
+	}
+	defer secretWatcher.Close()
+
+	port := cfg.Server.Port
+	httpServer := &http.Server{
+		Addr:              ":" + port,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	shutdownTimeout := cfg.Server.ShutdownTimeout
+
+	// Redis is only connected when a component is configured to use it
+	var rdb *redis.Client
+	if cfg.UsesRedis() {
+		if rdb, err = cache.OpenRedis(ctx, cfg.Redis); err != nil {
+			return err
+		}
+		defer rdb.Close()
+	}
+	queries, err := persisted.Open(cfg.PersistedQueries, rdb)
+	if err != nil {
+		return err
+	}
+	defer queries.Close()
+	var pool *pgxpool.Pool
+	if cfg.UsesPostgres() {
+		if pool, err = db.Open(ctx, cfg.Postgres); err != nil {
+			return err
+		}
+		defer pool.Close()
+	}
+	elector, err := leader.Open(cfg.Leader, rdb, pool)
+	if err != nil {
+		return err
+	}
+	priceCache, locks, err := cache.Open(cfg.Cache, rdb)
+	if err != nil {
+		return err
+	}
+	var limiter auth.Limiter = auth.NewMemoryLimiter()
+	if strings.EqualFold(cfg.Cache.Backend, "redis") {
+		limiter = auth.NewRedisLimiter(rdb)
 	}
 
-	resolver := graph.NewResolver()
-	srv := handler.NewDefaultServer(graph.NewExecutableSchema(graph.Config{Resolvers: resolver}))
+	// In memory, history, keys issued and zipcodes tracked at runtime are
+	// lost on restart
+	var prices history.Store = history.NewMemoryStore(cfg.History)
+	var keys auth.Store = auth.NewMemoryStore()
+	var zipcodes tracked.Store = tracked.NewMemoryStore()
+	if strings.EqualFold(cfg.Storage.Backend, "postgres") {
+		if err := db.Migrate(ctx, pool); err != nil {
+			return err
+		}
+		prices = history.NewPostgresStore(pool, cfg.History)
+		keys = auth.NewPostgresStore(pool)
+		zipcodes = tracked.NewPostgresStore(pool)
+	}
+	if err := tracked.Seed(ctx, zipcodes, cfg.Prices.TrackedZipcodes); err != nil {
+		return err
+	}
+	authn := auth.NewAuthenticator(cfg.Auth, keys, limiter)
+	if !cfg.Auth.Required {
+		slog.Warn("API keys are not required; anyone can query /graphql and spend provider credits")
+	}
+	resolver, err := graph.NewResolver(cfg, prices, keys, zipcodes, cache.NewGroup("prices", priceCache, locks, cfg.Cache.LockTimeout), elector)
+	if err != nil {
+		return err
+	}
+	metrics.RegisterBreakers(func() map[string]string {
+		states := map[string]string{}
+		for _, p := range resolver.Providers() {
+			status := p.Status()
+			states[status.Provider] = string(status.Breaker)
+		}
+		return states
+	})
 
-	// CORS middleware
-	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"*"},
-		AllowedMethods:   []string{"GET", "POST", "OPTIONS"},
-		AllowedHeaders:   []string{"*"},
-		AllowCredentials: true,
+	// Keep prices for tracked zipcodes fresh for the price exporter, and roll
+	// up and expire history. Only the elected replica runs the jobs.
+	jobs := scheduler.New(elector)
+	jobs.Add(scheduler.Job{
+		Name:     "compact-price-history",
+		Interval: cfg.History.CompactInterval,
+		Run:      prices.Compact,
 	})
+	jobs.Add(scheduler.Job{
+		Name:     "refresh-tracked-prices",
+		Interval: cfg.Prices.RefreshInterval,
+		Run: func(ctx context.Context) error {
+			// Read each run, so zipcodes tracked since start are refreshed
+			list, err := zipcodes.List(ctx)
+			if err != nil {
+				return err
+			}
+			return resolver.RefreshPrices(ctx, list)
+		},
+	})
+	elector.Start(ctx)
+	jobs.Start(context.Background())
+
+	srv := graph.NewHandler(resolver, cfg, queries)
+	srv.Use(metrics.NewExtension(cfg.GraphQL.MetricOperations))
+	srv.Use(tracing.Extension{})
+
+	mux := http.NewServeMux()
+	if !cfg.Production() {
+		mux.Handle("/", playground.Handler("Egg Price Comparison", "/graphql"))
+	}
+	mux.Handle("/graphql", otelhttp.NewHandler(logging.Middleware(graph.CORS(cfg.Server.CORSAllowedOrigins, authn.Middleware(api.MockScenarioMiddleware(graph.CacheHeaders(srv))))), "graphql"))
+	mux.Handle("/metrics", metrics.Handler())
+	mux.Handle("/metrics/prices", metrics.PricesHandler(prices, zipcodes))
+	mux.Handle("/healthz", health.Liveness())
+	mux.Handle("/readyz", health.Readiness(
+		health.Check{Name: "storage", Check: prices.Ping},
+		health.Check{Name: "providers", Check: func(context.Context) error {
+			var errs []error
+			for _, p := range resolver.Providers() {
+				errs = append(errs, p.Status().ConfigError)
+			}
+			return errors.Join(errs...)
+		}},
+	))
+	mux.Handle("/admin/config", authn.RequireScope(auth.ScopeAdmin, config.Handler(cfg)))
+	httpServer.Handler = mux
+	if !cfg.Production() {
+		slog.Info("playground enabled", "url", "http://localhost:"+port+"/")
+	}
+
+	slog.Info("🥚 Egg Price Comparison API",
+		"environment", cfg.Server.Environment,
+		"endpoint", "http://localhost:"+port+"/graphql",
+		"metrics", "http://localhost:"+port+"/metrics",
+	)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err = <-serveErr:
+		// The listener failed before any signal, e.g. the port is taken
+	case <-ctx.Done():
+		slog.Info("shutting down", "timeout", shutdownTimeout)
+	}
+	stop()
 
-	http.Handle("/", playground.Handler("Egg Price Comparison", "/graphql"))
-	http.Handle("/graphql", c.Handler(srv))
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-	log.Printf("🥚 Egg Price Comparison API")
-	log.Printf("GraphQL playground: http://localhost:%s/", port)
-	log.Printf("GraphQL endpoint: http://localhost:%s/graphql", port)
-	log.Fatal(http.ListenAndServe(":"+port, nil))
+	// Drain in-flight requests, then stop background jobs before flushing
+	// so no refresh writes land after the flush
+	var errs []error
+	if err != nil {
+		errs = append(errs, err)
+	} else if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("http shutdown: %w", err))
+	}
+	jobs.Stop()
+	if err := elector.Stop(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("leadership release: %w", err))
+	}
+	if err := prices.Flush(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("history flush: %w", err))
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		errs = append(errs, fmt.Errorf("tracing shutdown: %w", err))
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,165 @@
+// Package tracing sets up OpenTelemetry tracing. Spans cover GraphQL
+// operations and resolvers (via a gqlgen extension), retailer adapter and
+// price source calls, and every outbound HTTP request.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql"
+	"github.com/jkzilla/egg-price-compare/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.41.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/jkzilla/egg-price-compare/tracing"
+
+// Setup exports spans over OTLP/HTTP when cfg has an endpoint. Without one
+// tracing stays disabled. The headers are read once, so rotating them needs
+// a restart. Call the returned function on shutdown to flush buffered spans.
+func Setup(ctx context.Context, cfg config.Tracing) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	// Like OTEL_EXPORTER_OTLP_ENDPOINT, the base endpoint gets the signal path
+	endpoint := cfg.TracesEndpoint
+	if endpoint == "" {
+		endpoint = strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces"
+	}
+	headers, err := keyValues(strings.Split(cfg.Headers.Get(), ","))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: headers: %w", err)
+	}
+	exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(endpoint), otlptracehttp.WithHeaders(headers))
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to create OTLP exporter: %w", err)
+	}
+
+	attrs, err := keyValues(cfg.ResourceAttributes)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: resource attributes: %w", err)
+	}
+	resAttrs := []attribute.KeyValue{semconv.ServiceName(cfg.ServiceName)}
+	for k, v := range attrs {
+		resAttrs = append(resAttrs, attribute.String(k, v))
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(resAttrs...),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("tracing: failed to build resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// keyValues parses key=value pairs, whose values may be URL-encoded as in
+// the OTEL_* variables. Blank pairs are skipped.
+func keyValues(pairs []string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range pairs {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("a pair is not key=value")
+		}
+		unescaped, err := url.PathUnescape(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.TrimSpace(key), err)
+		}
+		m[strings.TrimSpace(key)] = unescaped
+	}
+	return m, nil
+}
+
+// Extension is a gqlgen handler extension that opens a span per operation
+// and a child span per resolver call
+type Extension struct{}
+
+var _ interface {
+	graphql.HandlerExtension
+	graphql.ResponseInterceptor
+	graphql.FieldInterceptor
+} = Extension{}
+
+func (Extension) ExtensionName() string {
+	return "OpenTelemetryTracing"
+}
+
+func (Extension) Validate(graphql.ExecutableSchema) error {
+	return nil
+}
+
+func (Extension) InterceptResponse(ctx context.Context, next graphql.ResponseHandler) *graphql.Response {
+	if !graphql.HasOperationContext(ctx) {
+		return next(ctx)
+	}
+
+	oc := graphql.GetOperationContext(ctx)
+	name, opType := oc.OperationName, "unknown"
+	if oc.Operation != nil {
+		name = oc.Operation.Name
+		opType = string(oc.Operation.Operation)
+	}
+	spanName := "graphql." + opType
+	if name != "" {
+		spanName += " " + name
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, spanName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("graphql.operation.name", name),
+			attribute.String("graphql.operation.type", opType),
+		),
+	)
+	defer span.End()
+
+	resp := next(ctx)
+	if resp != nil && len(resp.Errors) > 0 {
+		span.SetStatus(codes.Error, resp.Errors.Error())
+	}
+	return resp
+}
+
+// InterceptField traces resolver calls only; plain struct fields are too
+// cheap and numerous to be worth a span
+func (Extension) InterceptField(ctx context.Context, next graphql.Resolver) (interface{}, error) {
+	fc := graphql.GetFieldContext(ctx)
+	if fc == nil || !fc.IsResolver {
+		return next(ctx)
+	}
+
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, "graphql.resolve "+fc.Object+"."+fc.Field.Name,
+		trace.WithAttributes(attribute.String("graphql.field.path", fc.Path().String())),
+	)
+	defer span.End()
+
+	res, err := next(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return res, err
+}
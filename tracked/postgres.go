@@ -0,0 +1,47 @@
+package tracked
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PostgresStore keeps the zipcodes in the tracked_zipcodes table, shared by
+// every replica. The pool is shared; close it separately.
+type PostgresStore struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresStore(pool *pgxpool.Pool) *PostgresStore {
+	return &PostgresStore{pool: pool}
+}
+
+func (s *PostgresStore) List(ctx context.Context) ([]string, error) {
+	rows, err := s.pool.Query(ctx, `SELECT zipcode FROM tracked_zipcodes ORDER BY zipcode`)
+	if err != nil {
+		return nil, fmt.Errorf("tracked: failed to list zipcodes: %w", err)
+	}
+	zipcodes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("tracked: failed to list zipcodes: %w", err)
+	}
+	return zipcodes, nil
+}
+
+func (s *PostgresStore) Add(ctx context.Context, zipcode string) error {
+	_, err := s.pool.Exec(ctx, `INSERT INTO tracked_zipcodes (zipcode) VALUES ($1) ON CONFLICT DO NOTHING`, zipcode)
+	if err != nil {
+		return fmt.Errorf("tracked: failed to add %s: %w", zipcode, err)
+	}
+	return nil
+}
+
+func (s *PostgresStore) Remove(ctx context.Context, zipcode string) error {
+	_, err := s.pool.Exec(ctx, `DELETE FROM tracked_zipcodes WHERE zipcode = $1`, zipcode)
+	if err != nil {
+		return fmt.Errorf("tracked: failed to remove %s: %w", zipcode, err)
+	}
+	return nil
+}
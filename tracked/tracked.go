@@ -0,0 +1,70 @@
+// Package tracked keeps the zipcodes whose prices are refreshed in the
+// background and published by the price exporter. TRACKED_ZIPCODES seeds
+// the list at startup; admins add and remove zipcodes through the API.
+package tracked
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Store keeps the tracked zipcodes. Implementations are safe for concurrent
+// use.
+type Store interface {
+	// List returns the tracked zipcodes in ascending order
+	List(ctx context.Context) ([]string, error)
+	// Add tracks zipcode; adding a tracked zipcode does nothing
+	Add(ctx context.Context, zipcode string) error
+	// Remove stops tracking zipcode; removing an untracked zipcode does
+	// nothing
+	Remove(ctx context.Context, zipcode string) error
+}
+
+// Seed tracks each of zipcodes in store
+func Seed(ctx context.Context, store Store, zipcodes []string) error {
+	for _, zipcode := range zipcodes {
+		if err := store.Add(ctx, zipcode); err != nil {
+			return fmt.Errorf("tracked: failed to seed %s: %w", zipcode, err)
+		}
+	}
+	return nil
+}
+
+// MemoryStore keeps the zipcodes in memory, so zipcodes added at runtime are
+// lost on restart and each replica has its own
+type MemoryStore struct {
+	mu       sync.RWMutex
+	zipcodes map[string]bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{zipcodes: map[string]bool{}}
+}
+
+func (s *MemoryStore) List(ctx context.Context) ([]string, error) {
+	s.mu.RLock()
+	zipcodes := make([]string, 0, len(s.zipcodes))
+	for zipcode := range s.zipcodes {
+		zipcodes = append(zipcodes, zipcode)
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(zipcodes)
+	return zipcodes, nil
+}
+
+func (s *MemoryStore) Add(ctx context.Context, zipcode string) error {
+	s.mu.Lock()
+	s.zipcodes[zipcode] = true
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *MemoryStore) Remove(ctx context.Context, zipcode string) error {
+	s.mu.Lock()
+	delete(s.zipcodes, zipcode)
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,53 @@
+package tracked
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/jkzilla/egg-price-compare/db/dbtest"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestPostgresStore(t *testing.T) {
+	testStore(t, NewPostgresStore(dbtest.Open(t)))
+}
+
+func testStore(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := Seed(ctx, store, []string{"94102", "10001"}); err != nil {
+		t.Fatal(err)
+	}
+	// Seeding again, as every start does, keeps one of each
+	if err := Seed(ctx, store, []string{"94102"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Add(ctx, "60601"); err != nil {
+		t.Fatal(err)
+	}
+	checkList(t, store, "10001", "60601", "94102")
+
+	if err := store.Remove(ctx, "10001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Remove(ctx, "99999"); err != nil {
+		t.Fatalf("removing an untracked zipcode: %v", err)
+	}
+	checkList(t, store, "60601", "94102")
+}
+
+func checkList(t *testing.T, store Store, want ...string) {
+	t.Helper()
+	got, err := store.List(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("List = %v, want %v", got, want)
+	}
+}